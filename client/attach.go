@@ -31,22 +31,10 @@ func (cli *HyperClient) HyperCmdAttach(args ...string) error {
 		containerId = podName
 	)
 
+	// the daemon resolves the container, or the first container of the pod
 	v := url.Values{}
-	if strings.Contains(podName, "pod-") {
-		_, err = cli.GetPodInfo(podName)
-		if err != nil {
-			return err
-		}
-		containerId, err = cli.GetContainerByPod(podName)
-		if err != nil {
-			return err
-		}
-		v.Set("type", "container")
-		v.Set("value", containerId)
-	} else {
-		v.Set("type", "container")
-		v.Set("value", containerId)
-	}
+	v.Set("type", "container")
+	v.Set("value", containerId)
 	v.Set("tag", tag)
 
 	tty := true //TODO: get the correct tty value of the pod/container from hyperd
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/hyperhq/hyper/engine"
+	"github.com/hyperhq/hyper/types"
 
 	gflag "github.com/jessevdk/go-flags"
 )
@@ -17,6 +18,7 @@ import (
 	-c, --change=[]     Apply Dockerfile instruction to the created image
 	-m, --message=      Commit message
 	-p, --pause         Pause container during Commit
+	    --pod           Commit the containers of a pod
 	-h, --help          Print usage
 */
 func (cli *HyperClient) HyperCmdCommit(args ...string) error {
@@ -25,10 +27,11 @@ func (cli *HyperClient) HyperCmdCommit(args ...string) error {
 		Change  []string `short:"c" long:"change" default:"" value-name:"[]" description:"Apply Dockerfile instruction to the created image"`
 		Message string   `short:"m" long:"message" default:"" value-name:"\"\"" description:"Commit message"`
 		Pause   bool     `short:"p" long:"pause" default:"false" description:"Pause container during Commit"`
+		Pod     bool     `long:"pod" default:"false" default-mask:"-" description:"Commit the containers of the pod to the images PREFIX-CONTAINER[:TAG], and print the pod spec with the images"`
 	}
 	var parser = gflag.NewParser(&opts, gflag.Default)
 
-	parser.Usage = "commit [OPTIONS] CONTAINER [REPOSITORY[:TAG]]\n\nCreate a new image from a container's changes"
+	parser.Usage = "commit [OPTIONS] CONTAINER [REPOSITORY[:TAG]]\n       commit [OPTIONS] --pod POD PREFIX[:TAG]\n\nCreate a new image from a container's changes, or the images of the containers in a pod"
 	args, err := parser.ParseArgs(args)
 	if err != nil {
 		if !strings.Contains(err.Error(), "Usage") {
@@ -50,6 +53,12 @@ func (cli *HyperClient) HyperCmdCommit(args ...string) error {
 	if len(args) > 1 {
 		repo = args[1]
 	}
+	if opts.Pod {
+		if len(args) < 2 {
+			return fmt.Errorf("%s: \"commit --pod\" requires a pod and a prefix, See 'hyper commit --help'.", os.Args[0])
+		}
+		return cli.commitPod(containerId, repo, opts.Author, opts.Message, opts.Pause)
+	}
 	v := url.Values{}
 	v.Set("author", opts.Author)
 	changeJson, err := json.Marshal(opts.Change)
@@ -82,3 +91,35 @@ func (cli *HyperClient) HyperCmdCommit(args ...string) error {
 	fmt.Fprintf(cli.out, "%s\n", remoteInfo.Get("ID"))
 	return nil
 }
+
+// commitPod commits the containers of the pod, the committed images are
+// printed to stderr and the pod spec to stdout, so that it could be saved
+// to create the pod again.
+func (cli *HyperClient) commitPod(podId, prefix, author, message string, pause bool) error {
+	v := url.Values{}
+	if i := strings.LastIndex(prefix, ":"); i > strings.LastIndex(prefix, "/") {
+		v.Set("tag", prefix[i+1:])
+		prefix = prefix[:i]
+	}
+	v.Set("podId", podId)
+	v.Set("prefix", prefix)
+	v.Set("author", author)
+	v.Set("comment", message)
+	if pause {
+		v.Set("pause", "yes")
+	}
+	body, _, err := readBody(cli.call("POST", "/pod/commit?"+v.Encode(), nil, nil))
+	if err != nil {
+		return err
+	}
+
+	var result types.PodCommitResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return err
+	}
+	for _, img := range result.Images {
+		fmt.Fprintf(cli.err, "%s: %s %s\n", img.Container, img.Image, img.ID)
+	}
+	fmt.Fprintf(cli.out, "%s\n", result.Spec)
+	return nil
+}
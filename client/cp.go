@@ -0,0 +1,119 @@
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/pkg/archive"
+
+	gflag "github.com/jessevdk/go-flags"
+)
+
+func (cli *HyperClient) HyperCmdCp(args ...string) error {
+	var opts struct {
+		FollowLink bool `short:"L" long:"follow-link" default:"false" default-mask:"-" description:"Always follow the symbol link in SRC_PATH"`
+	}
+	var parser = gflag.NewParser(&opts, gflag.Default)
+	parser.Usage = "cp [OPTIONS] CONTAINER:SRC_PATH DEST_PATH|-\n  cp [OPTIONS] SRC_PATH|- CONTAINER:DEST_PATH\n\nCopy files or directories between a running container and the host, the content is a tar archive if '-' is given.\nA file or directory is copied into DEST_PATH if it is a directory, otherwise it is copied as DEST_PATH"
+	args, err := parser.ParseArgs(args)
+	if err != nil {
+		if !strings.Contains(err.Error(), "Usage") {
+			return err
+		} else {
+			return nil
+		}
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("\"cp\" requires exactly 2 arguments, the source and the destination.\n")
+	}
+
+	srcContainer, srcPath := splitCpArg(args[0])
+	dstContainer, dstPath := splitCpArg(args[1])
+	switch {
+	case srcContainer != "" && dstContainer != "":
+		return fmt.Errorf("Copying between containers is not supported")
+	case srcContainer != "":
+		return cli.copyFromContainer(srcContainer, srcPath, dstPath)
+	case dstContainer != "":
+		return cli.copyToContainer(srcPath, dstContainer, dstPath, opts.FollowLink)
+	}
+	return fmt.Errorf("Either the source or the destination should be in a container, in the form of CONTAINER:PATH")
+}
+
+// splitCpArg splits the argument of cp in the form of CONTAINER:PATH, a
+// local path is absolute or begins with "." in case it contains a colon.
+func splitCpArg(arg string) (container, path string) {
+	if filepath.IsAbs(arg) || strings.HasPrefix(arg, ".") {
+		return "", arg
+	}
+
+	parts := strings.SplitN(arg, ":", 2)
+	if len(parts) == 1 {
+		return "", arg
+	}
+	return parts[0], parts[1]
+}
+
+func (cli *HyperClient) copyFromContainer(container, srcPath, dstPath string) error {
+	v := url.Values{}
+	v.Set("container", container)
+	v.Set("path", srcPath)
+	body, _, err := cli.call("GET", "/container/archive?"+v.Encode(), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if dstPath == "-" {
+		_, err = io.Copy(cli.out, body)
+		return err
+	}
+
+	// read the root entry to tell whether a directory is copied, the
+	// header is replayed before the rest of the archive
+	var head bytes.Buffer
+	hdr, err := tar.NewReader(io.TeeReader(body, &head)).Next()
+	if err != nil {
+		return fmt.Errorf("Invalid archive of %s: %s", srcPath, err.Error())
+	}
+	srcInfo := archive.CopyInfo{
+		Path:   srcPath,
+		Exists: true,
+		IsDir:  hdr.Typeflag == tar.TypeDir,
+	}
+	return archive.CopyTo(io.MultiReader(&head, body), srcInfo, dstPath)
+}
+
+func (cli *HyperClient) copyToContainer(srcPath, container, dstPath string, followLink bool) error {
+	var content io.Reader
+	if srcPath == "-" {
+		content = cli.in
+	} else {
+		srcInfo, err := archive.CopyInfoSourcePath(srcPath, followLink)
+		if err != nil {
+			return err
+		}
+		archived, err := archive.TarResource(srcInfo)
+		if err != nil {
+			return err
+		}
+		defer archived.Close()
+		content = archived
+	}
+
+	v := url.Values{}
+	v.Set("container", container)
+	v.Set("path", dstPath)
+	headers := map[string][]string{"Content-Type": {"application/x-tar"}}
+	body, _, _, err := cli.clientRequest("PUT", "/container/archive?"+v.Encode(), content, headers)
+	if err != nil {
+		return err
+	}
+	body.Close()
+	return nil
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/docker/docker/pkg/archive"
+
+	gflag "github.com/jessevdk/go-flags"
+)
+
+func (cli *HyperClient) HyperCmdDiff(args ...string) error {
+	var parser = gflag.NewParser(nil, gflag.Default)
+	parser.Usage = "diff CONTAINER\n\nList the files added (A), changed (C) and deleted (D) in a container against its image"
+	args, err := parser.ParseArgs(args)
+	if err != nil {
+		if !strings.Contains(err.Error(), "Usage") {
+			return err
+		} else {
+			return nil
+		}
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("\"diff\" requires a minimum of 1 argument, please provide the container.\n")
+	}
+
+	v := url.Values{}
+	v.Set("container", args[0])
+	body, _, err := readBody(cli.call("GET", "/container/changes?"+v.Encode(), nil, nil))
+	if err != nil {
+		return err
+	}
+
+	var changes []archive.Change
+	if err := json.Unmarshal(body, &changes); err != nil {
+		return err
+	}
+	for _, change := range changes {
+		fmt.Fprintln(cli.out, change.String())
+	}
+	return nil
+}
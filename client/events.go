@@ -0,0 +1,123 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/engine-api/types/filters"
+	timetypes "github.com/docker/engine-api/types/time"
+	"github.com/hyperhq/hyper/types"
+
+	gflag "github.com/jessevdk/go-flags"
+)
+
+func (cli *HyperClient) HyperCmdEvents(args ...string) error {
+	var opts struct {
+		Since   string   `long:"since" value-name:"\"\"" description:"Show all events created since timestamp"`
+		Until   string   `long:"until" value-name:"\"\"" description:"Stream events until this timestamp"`
+		Filters []string `short:"f" long:"filter" value-name:"[]" description:"Filter output based on conditions provided (type, event, pod, container, vm, label)"`
+	}
+
+	var parser = gflag.NewParser(&opts, gflag.Default|gflag.IgnoreUnknown)
+	parser.Usage = "events [OPTIONS]\n\nGet real time events of pods, containers and vms from the server"
+	args, err := parser.ParseArgs(args)
+	if err != nil {
+		if !strings.Contains(err.Error(), "Usage") {
+			return err
+		} else {
+			return nil
+		}
+	}
+
+	ef := filters.NewArgs()
+	for _, f := range opts.Filters {
+		if ef, err = filters.ParseFlag(f, ef); err != nil {
+			return err
+		}
+	}
+
+	v := url.Values{}
+	now := time.Now()
+	if opts.Since != "" {
+		ts, err := timetypes.GetTimestamp(opts.Since, now)
+		if err != nil {
+			return err
+		}
+		v.Set("since", ts)
+	}
+	if opts.Until != "" {
+		ts, err := timetypes.GetTimestamp(opts.Until, now)
+		if err != nil {
+			return err
+		}
+		v.Set("until", ts)
+	}
+	if ef.Len() > 0 {
+		filterJSON, err := filters.ToParam(ef)
+		if err != nil {
+			return err
+		}
+		v.Set("filters", filterJSON)
+	}
+
+	body, _, _, err := cli.clientRequest("GET", "/events?"+v.Encode(), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	dec := json.NewDecoder(body)
+	for {
+		var ev types.Event
+		if err := dec.Decode(&ev); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		printEvent(cli.out, ev)
+	}
+}
+
+// printEvent prints an event in the form of
+// `TIME TYPE ACTION ID (key=value, ...)`
+func printEvent(out io.Writer, ev types.Event) {
+	id := ev.PodID
+	attrs := []string{}
+	switch ev.Type {
+	case types.EventTypeContainer:
+		id = ev.ContainerID
+		attrs = append(attrs, "pod="+ev.PodID)
+	case types.EventTypeVm:
+		id = ev.VmID
+		if ev.PodID != "" {
+			attrs = append(attrs, "pod="+ev.PodID)
+		}
+	}
+	if ev.PodName != "" {
+		attrs = append(attrs, "name="+ev.PodName)
+	}
+	if ev.VmID != "" && ev.Type != types.EventTypeVm {
+		attrs = append(attrs, "vm="+ev.VmID)
+	}
+	if ev.Type == types.EventTypeContainer && ev.Action == "finish" {
+		attrs = append(attrs, fmt.Sprintf("exitCode=%d", ev.ExitCode))
+	}
+
+	keys := make([]string, 0, len(ev.Labels))
+	for k := range ev.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		attrs = append(attrs, k+"="+ev.Labels[k])
+	}
+
+	fmt.Fprintf(out, "%s %s %s %s (%s)\n", time.Unix(0, ev.TimeNano).Format(time.RFC3339Nano),
+		ev.Type, ev.Action, id, strings.Join(attrs, ", "))
+}
@@ -84,22 +84,11 @@ func (cli *HyperClient) HyperCmdExec(args ...string) error {
 		v.Set("type", "pod")
 		v.Set("value", podName)
 	} else {
-		if strings.Contains(podName, "pod-") {
-			_, err = cli.GetPodInfo(podName)
-			if err != nil {
-				return err
-			}
-			containerId, err = cli.GetContainerByPod(podName)
-			if err != nil {
-				return err
-			}
-			v.Set("type", "container")
-			v.Set("value", containerId)
-		} else {
-			v.Set("type", "container")
-			v.Set("value", podName)
-			containerId = podName
-		}
+		// the daemon resolves the container, or the first container of
+		// the pod
+		v.Set("type", "container")
+		v.Set("value", podName)
+		containerId = podName
 	}
 	v.Set("command", string(command))
 	v.Set("tag", tag)
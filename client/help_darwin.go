@@ -10,13 +10,19 @@ func (cli *HyperClient) HyperCmdHelp(args ...string) error {
   %s [OPTIONS] COMMAND [ARGS...]
 
 Command:
+  annotate               Add or remove the annotations of a pod
   attach                 Attach to the tty of a specified container in a pod
   build                  Build an image from a Dockerfile
-  commit                 Create a new image from a container's changes
+  commit                 Create new images from the changes of a container or a pod
+  cp                     Copy files or directories between a container and the host
   create                 Create a pod into 'pending' status, but without running it
+  diff                   Inspect the changes on a container's filesystem
+  events                 Get real time events from the server
   exec                   Run a command in a container of a running pod
   images                 List images
   info                   Display system-wide information
+  kill                   Kill a VM, or send a signal to a container
+  label                  Add or remove the labels of a pod
   list                   List all pods or containers
   load                   Load a image from STDIN or tar archive file
   login                  Register or log in to a Docker registry server
@@ -27,7 +33,12 @@ Command:
   rmi                    Remove one or more images
   run                    Create a pod, and launch a new pod
   start                  Launch a 'pending' pod
+  stats                  Display a live stream of the resource usage of the pods
   stop                   Stop a running pod, it will become 'pending'
+  system                 Manage the hyper daemon, e.g. prune the orphaned resources
+  top                    Display the running processes of a container
+  update                 Update the CPU and memory of a pod
+  wait                   Block until pods or containers stop, then print their exit codes
 
 Help Options:
   -h, --help             Show this help message
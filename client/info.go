@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/hyperhq/hyper/engine"
+	"github.com/hyperhq/hyper/types"
 
 	gflag "github.com/jessevdk/go-flags"
 )
@@ -58,9 +59,48 @@ func (cli *HyperClient) HyperCmdInfo(args ...string) error {
 	fmt.Fprintf(cli.out, "Total Memory: %s\n", memTotal)
 	fmt.Fprintf(cli.out, "Operating System: %s\n", remoteInfo.Get("Operating System"))
 
+	var res types.ResourceInfo
+	if remoteInfo.Exists("Resource") && remoteInfo.GetJson("Resource", &res) == nil {
+		fmt.Fprintf(cli.out, "Allocated Resource:\n")
+		fmt.Fprintf(cli.out, "  vCPUs: %d / %s\n", res.Cpus, getCapacityString(res.MaxCpus, ""))
+		fmt.Fprintf(cli.out, "  Memory: %d MB / %s\n", res.Memory, getCapacityString(res.MaxMemory, " MB"))
+		fmt.Fprintf(cli.out, "  PODs: %d / %s (%d cached VMs)\n", res.Pods, getCapacityString(res.MaxPods, ""), res.CachedVms)
+	}
+
+	if remoteInfo.Exists("VmCachePolicy") {
+		fmt.Fprintf(cli.out, "VM Cache Policy: %s\n", remoteInfo.Get("VmCachePolicy"))
+	}
+	var pool types.VmPoolInfo
+	if remoteInfo.Exists("VmPool") && remoteInfo.GetJson("VmPool", &pool) == nil {
+		fmt.Fprintf(cli.out, "VM Pool: %d hits, %d misses, idle timeout %ds\n", pool.Hits, pool.Misses, pool.IdleTimeout)
+		for _, f := range pool.Flavors {
+			fmt.Fprintf(cli.out, "  %d vCPU %d MB: %d idle, %d booting (min %d, max %d)\n", f.Cpu, f.Memory, f.Idle, f.Booting, f.Min, f.Max)
+		}
+	}
+	var templates []types.VmTemplateInfo
+	if err := remoteInfo.GetJson("VmTemplates", &templates); err == nil && len(templates) > 0 {
+		fmt.Fprintf(cli.out, "VM Templates:\n")
+		for _, t := range templates {
+			fmt.Fprintf(cli.out, "  %d vCPU %d MB: %s", t.Cpu, t.Memory, t.Status)
+			if t.Status == "ready" {
+				fmt.Fprintf(cli.out, ", created %s, %d clones", t.Created, t.Clones)
+			} else if t.Message != "" {
+				fmt.Fprintf(cli.out, " (%s)", t.Message)
+			}
+			fmt.Fprintf(cli.out, "\n")
+		}
+	}
+
 	return nil
 }
 
+func getCapacityString(c int, unit string) string {
+	if c == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d%s", c, unit)
+}
+
 func getMemSizeString(s int) string {
 	var rtn float64
 	if s < 1024*1024 {
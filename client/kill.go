@@ -12,8 +12,11 @@ import (
 )
 
 func (cli *HyperClient) HyperCmdKill(args ...string) error {
-	var parser = gflag.NewParser(nil, gflag.Default)
-	parser.Usage = "kill VM_ID\n\nTerminate a VM instance"
+	var opts struct {
+		Signal string `short:"s" long:"signal" default:"" value-name:"KILL" default-mask:"-" description:"Signal to send to the container"`
+	}
+	var parser = gflag.NewParser(&opts, gflag.Default)
+	parser.Usage = "kill [OPTIONS] VM_ID|CONTAINER\n\nTerminate a VM instance, or send a signal to the process of a container"
 	args, err := parser.ParseArgs(args)
 	if err != nil {
 		if !strings.Contains(err.Error(), "Usage") {
@@ -23,7 +26,11 @@ func (cli *HyperClient) HyperCmdKill(args ...string) error {
 		}
 	}
 	if len(args) == 0 {
-		return fmt.Errorf("\"kill\" requires a minimum of 1 argument, please provide VM ID.\n")
+		return fmt.Errorf("\"kill\" requires a minimum of 1 argument, please provide VM ID or container.\n")
+	}
+
+	if opts.Signal != "" || !strings.HasPrefix(args[0], "vm-") {
+		return cli.KillContainer(args[0], opts.Signal)
 	}
 
 	vmId := args[0]
@@ -50,3 +57,13 @@ func (cli *HyperClient) HyperCmdKill(args ...string) error {
 
 	return nil
 }
+
+func (cli *HyperClient) KillContainer(container, signal string) error {
+	v := url.Values{}
+	v.Set("container", container)
+	if signal != "" {
+		v.Set("signal", signal)
+	}
+	_, _, err := readBody(cli.call("POST", "/container/kill?"+v.Encode(), nil, nil))
+	return err
+}
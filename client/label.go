@@ -0,0 +1,94 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/hyperhq/hyper/engine"
+	gflag "github.com/jessevdk/go-flags"
+)
+
+func (cli *HyperClient) HyperCmdLabel(args ...string) error {
+	return cli.setPodMetadata("label", "labels", args)
+}
+
+func (cli *HyperClient) HyperCmdAnnotate(args ...string) error {
+	return cli.setPodMetadata("annotate", "annotations", args)
+}
+
+// setPodMetadata updates the labels or the annotations of the pod, the
+// args are the pod and the changes, "key=value" to set and "key-" to remove.
+func (cli *HyperClient) setPodMetadata(cmd, kind string, args []string) error {
+	var opts struct {
+		Override bool `long:"overwrite" default:"false" description:"Allow to overwrite the existing keys"`
+	}
+	var parser = gflag.NewParser(&opts, gflag.Default)
+	parser.Usage = fmt.Sprintf("%s [OPTIONS] POD KEY=VALUE|KEY- [KEY=VALUE|KEY-...]\n\nUpdate the %s of a pod, KEY=VALUE sets the value of KEY, KEY- removes KEY", cmd, kind)
+	args, err := parser.ParseArgs(args)
+	if err != nil {
+		if !strings.Contains(err.Error(), "Usage") {
+			return err
+		} else {
+			return nil
+		}
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("\"%s\" requires a minimum of 2 arguments, please provide POD ID and the %s.\n", cmd, kind)
+	}
+
+	set, remove, err := parseMetadataArgs(args[1:])
+	if err != nil {
+		return err
+	}
+	setJson, err := json.Marshal(set)
+	if err != nil {
+		return err
+	}
+	removeJson, err := json.Marshal(remove)
+	if err != nil {
+		return err
+	}
+
+	v := url.Values{}
+	v.Set("podId", args[0])
+	v.Set(kind, string(setJson))
+	v.Set("remove", string(removeJson))
+	if opts.Override {
+		v.Set("override", "true")
+	}
+
+	body, _, err := readBody(cli.call("POST", "/pod/"+kind+"?"+v.Encode(), nil, nil))
+	if err != nil {
+		return err
+	}
+	out := engine.NewOutput()
+	if _, err = out.AddEnv(); err != nil {
+		return err
+	}
+
+	if _, err := out.Write(body); err != nil {
+		return err
+	}
+	out.Close()
+
+	fmt.Fprintf(cli.out, "The %s of pod %s are updated\n", kind, args[0])
+	return nil
+}
+
+func parseMetadataArgs(args []string) (map[string]string, []string, error) {
+	set := make(map[string]string)
+	remove := []string{}
+
+	for _, arg := range args {
+		if i := strings.Index(arg, "="); i > 0 {
+			set[arg[:i]] = arg[i+1:]
+		} else if strings.HasSuffix(arg, "-") && len(arg) > 1 {
+			remove = append(remove, arg[:len(arg)-1])
+		} else {
+			return nil, nil, fmt.Errorf("Invalid argument %q, should be KEY=VALUE or KEY-", arg)
+		}
+	}
+	return set, remove, nil
+}
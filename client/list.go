@@ -14,9 +14,11 @@ import (
 
 func (cli *HyperClient) HyperCmdList(args ...string) error {
 	var opts struct {
-		Aux bool   `short:"x" long:"aux" default:"false" description:"show the auxiliary containers"`
-		Pod string `short:"p" long:"pod" value-name:"\"\"" description:"only list the specified pod"`
-		VM  string `short:"m" long:"vm" value-name:"\"\"" description:"only list resources on the specified vm"`
+		Aux      bool     `short:"x" long:"aux" default:"false" description:"show the auxiliary containers"`
+		Pod      string   `short:"p" long:"pod" value-name:"\"\"" description:"only list the specified pod"`
+		VM       string   `short:"m" long:"vm" value-name:"\"\"" description:"only list resources on the specified vm"`
+		Selector string   `short:"l" long:"selector" value-name:"\"\"" description:"only list the pods whose labels match the selector, e.g. 'app=web,env in (qa,prod)'"`
+		Columns  []string `short:"L" long:"label-columns" value-name:"[]" description:"show the value of the label as a column"`
 	}
 
 	var parser = gflag.NewParser(&opts, gflag.Default|gflag.IgnoreUnknown)
@@ -51,6 +53,9 @@ func (cli *HyperClient) HyperCmdList(args ...string) error {
 	if opts.VM != "" {
 		v.Set("vm", opts.VM)
 	}
+	if opts.Selector != "" {
+		v.Set("selector", opts.Selector)
+	}
 	body, _, err := readBody(cli.call("GET", "/list?"+v.Encode(), nil, nil))
 	if err != nil {
 		return err
@@ -79,6 +84,24 @@ func (cli *HyperClient) HyperCmdList(args ...string) error {
 		return fmt.Errorf("Found an error while getting %s list: %s", item, remoteInfo.Get("Error"))
 	}
 
+	labels := make(map[string]map[string]string)
+	if len(opts.Columns) > 0 && remoteInfo.Exists("podLabels") {
+		if err := remoteInfo.GetJson("podLabels", &labels); err != nil {
+			return err
+		}
+	}
+	labelColumns := func(podId string) string {
+		var columns string
+		for _, key := range opts.Columns {
+			columns += "\t" + labels[podId][key]
+		}
+		return columns
+	}
+	var labelHeader string
+	for _, key := range opts.Columns {
+		labelHeader += "\t" + strings.ToUpper(key)
+	}
+
 	w := tabwriter.NewWriter(cli.out, 20, 1, 3, ' ', 0)
 	if item == "vm" {
 		vmResponse = remoteInfo.GetList("vmData")
@@ -100,15 +123,15 @@ func (cli *HyperClient) HyperCmdList(args ...string) error {
 	}
 
 	if item == "pod" {
-		fmt.Fprintln(w, "POD ID\tPOD Name\tVM name\tStatus")
+		fmt.Fprintln(w, "POD ID\tPOD Name\tVM name\tStatus"+labelHeader)
 		for _, p := range podResponse {
 			fields := strings.Split(p, ":")
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", fields[0], fields[1], fields[2], fields[3])
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s%s\n", fields[0], fields[1], fields[2], fields[3], labelColumns(fields[0]))
 		}
 	}
 
 	if item == "container" {
-		fmt.Fprintln(w, "Container ID\tName\tPOD ID\tStatus")
+		fmt.Fprintln(w, "Container ID\tName\tPOD ID\tStatus"+labelHeader)
 		for _, c := range containerResponse {
 			fields := strings.Split(c, ":")
 			name := fields[1]
@@ -117,9 +140,62 @@ func (cli *HyperClient) HyperCmdList(args ...string) error {
 					name = name[1:]
 				}
 			}
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", fields[0], name, fields[2], fields[3])
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s%s\n", fields[0], name, fields[2], fields[3], labelColumns(fields[2]))
 		}
 	}
 	w.Flush()
 	return nil
 }
+
+// SelectPods returns the IDs of the pods whose labels match the selector.
+func (cli *HyperClient) SelectPods(selector string) ([]string, error) {
+	v := url.Values{}
+	v.Set("item", "pod")
+	v.Set("selector", selector)
+	body, _, err := readBody(cli.call("GET", "/list?"+v.Encode(), nil, nil))
+	if err != nil {
+		return nil, err
+	}
+	out := engine.NewOutput()
+	remoteInfo, err := out.AddEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := out.Write(body); err != nil {
+		return nil, err
+	}
+	out.Close()
+
+	pods := []string{}
+	for _, p := range remoteInfo.GetList("podData") {
+		pods = append(pods, strings.Split(p, ":")[0])
+	}
+	return pods, nil
+}
+
+// podsToOperate returns the pods given in the args, followed by the pods
+// selected by the selector if it is not empty.
+func (cli *HyperClient) podsToOperate(args []string, selector string) ([]string, error) {
+	pods := args
+	if selector != "" {
+		selected, err := cli.SelectPods(selector)
+		if err != nil {
+			return nil, err
+		}
+		if len(selected) == 0 {
+			return nil, fmt.Errorf("No pod matches the selector %q", selector)
+		}
+		pods = append(pods, selected...)
+	}
+	return pods, nil
+}
+
+// podsFailed returns the error of the command operating on several pods if
+// any of them failed, the error of each pod has been reported.
+func podsFailed(action string, failed []string) error {
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("Failed to %s the pods: %s", action, strings.Join(failed, ", "))
+}
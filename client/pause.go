@@ -10,8 +10,11 @@ import (
 )
 
 func (cli *HyperClient) HyperCmdPause(args ...string) error {
-	var parser = gflag.NewParser(nil, gflag.Default|gflag.IgnoreUnknown)
-	parser.Usage = "pause Pod\n\nPause the pod"
+	var opts struct {
+		Selector string `short:"l" long:"selector" value-name:"\"\"" description:"Pause the pods whose labels match the selector"`
+	}
+	var parser = gflag.NewParser(&opts, gflag.Default|gflag.IgnoreUnknown)
+	parser.Usage = "pause [OPTIONS] Pod [Pod...]\n\nPause the pods"
 	args, err := parser.ParseArgs(args)
 	if err != nil {
 		if !strings.Contains(err.Error(), "Usage") {
@@ -20,12 +23,30 @@ func (cli *HyperClient) HyperCmdPause(args ...string) error {
 			return nil
 		}
 	}
-	if len(args) == 0 {
-		return fmt.Errorf("Can not accept the 'pause' command without Pod ID!")
+	if len(args) == 0 && opts.Selector == "" {
+		return fmt.Errorf("Can not accept the 'pause' command without Pod ID or selector!")
+	}
+
+	pods, err := cli.podsToOperate(args, opts.Selector)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, podId := range pods {
+		if err := cli.PausePod(podId); err != nil {
+			if len(pods) == 1 {
+				return err
+			}
+			fmt.Fprintf(cli.err, "Failed to pause pod %s: %v\n", podId, err)
+			failed = append(failed, podId)
+		}
 	}
+	return podsFailed("pause", failed)
+}
 
+func (cli *HyperClient) PausePod(podId string) error {
 	v := url.Values{}
-	v.Set("podId", args[0])
+	v.Set("podId", podId)
 
 	body, _, err := readBody(cli.call("POST", "/pod/pause?"+v.Encode(), nil, nil))
 	if err != nil {
@@ -61,12 +61,15 @@ func (cli *HyperClient) CreatePod(jsonbody string, remove bool) (string, error)
 	if err := json.Unmarshal([]byte(jsonbody), &tmpPod); err != nil {
 		return "", err
 	}
-	body, statusCode, err := readBody(cli.call("POST", "/pod/create?"+v.Encode(), tmpPod, nil))
+	// send the spec as it is, the fields not known by UserPod (e.g. the
+	// probes) are handled by the daemon
+	rawPod := json.RawMessage(jsonbody)
+	body, statusCode, err := readBody(cli.call("POST", "/pod/create?"+v.Encode(), &rawPod, nil))
 	if statusCode == 404 {
 		if err := cli.PullImages(jsonbody); err != nil {
 			return "", fmt.Errorf("failed to pull images: %s", err.Error())
 		}
-		if body, _, err = readBody(cli.call("POST", "/pod/create?"+v.Encode(), tmpPod, nil)); err != nil {
+		if body, _, err = readBody(cli.call("POST", "/pod/create?"+v.Encode(), &rawPod, nil)); err != nil {
 			return "", err
 		}
 	} else if err != nil {
@@ -241,12 +244,15 @@ func (cli *HyperClient) RunPod(podstring string, autoremove bool) (string, error
 	if err := json.Unmarshal([]byte(podstring), &tmpPod); err != nil {
 		return "", err
 	}
-	body, statusCode, err := readBody(cli.call("POST", "/pod/run?"+v.Encode(), tmpPod, nil))
+	// send the spec as it is, the fields not known by UserPod (e.g. the
+	// probes) are handled by the daemon
+	rawPod := json.RawMessage(podstring)
+	body, statusCode, err := readBody(cli.call("POST", "/pod/run?"+v.Encode(), &rawPod, nil))
 	if statusCode == 404 {
 		if err := cli.PullImages(podstring); err != nil {
 			return "", fmt.Errorf("failed to pull images: %s", err.Error())
 		}
-		if body, _, err = readBody(cli.call("POST", "/pod/run?"+v.Encode(), tmpPod, nil)); err != nil {
+		if body, _, err = readBody(cli.call("POST", "/pod/run?"+v.Encode(), &rawPod, nil)); err != nil {
 			return "", err
 		}
 	} else if err != nil {
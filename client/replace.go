@@ -44,7 +44,7 @@ func (cli *HyperClient) HyperCmdReplace(args ...string) error {
 		return err
 	}
 	// we need to stop the old pod, but leave the vm run
-	code, cause, err := cli.StopPod(oldPodId, "no")
+	code, cause, err := cli.StopPod(oldPodId, "no", "", -1)
 	if err != nil {
 		return err
 	}
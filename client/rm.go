@@ -12,8 +12,11 @@ import (
 )
 
 func (cli *HyperClient) HyperCmdRm(args ...string) error {
-	var parser = gflag.NewParser(nil, gflag.Default)
-	parser.Usage = "rm POD [POD...]\n\nRemove one or more pods"
+	var opts struct {
+		Selector string `short:"l" long:"selector" value-name:"\"\"" description:"Remove the pods whose labels match the selector"`
+	}
+	var parser = gflag.NewParser(&opts, gflag.Default)
+	parser.Usage = "rm [OPTIONS] POD [POD...]\n\nRemove one or more pods"
 	args, err := parser.ParseArgs(args)
 	if err != nil {
 		if !strings.Contains(err.Error(), "Usage") {
@@ -22,19 +25,26 @@ func (cli *HyperClient) HyperCmdRm(args ...string) error {
 			return nil
 		}
 	}
-	if len(args) == 0 {
-		return fmt.Errorf("\"rm\" requires a minimum of 1 argument, please provide POD ID.\n")
+	if len(args) == 0 && opts.Selector == "" {
+		return fmt.Errorf("\"rm\" requires a minimum of 1 argument, please provide POD ID or selector.\n")
+	}
+	pods, err := cli.podsToOperate(args, opts.Selector)
+	if err != nil {
+		return err
 	}
-	pods := args
+	var failed []string
 	for _, id := range pods {
-		err := cli.RmPod(id)
-		if err == nil {
-			fmt.Fprintf(cli.out, "Pod(%s) is successful to be deleted!\n", id)
-		} else {
-			fmt.Fprintf(cli.out, "%v\n", err)
+		if err := cli.RmPod(id); err != nil {
+			if len(pods) == 1 {
+				return err
+			}
+			fmt.Fprintf(cli.err, "%v\n", err)
+			failed = append(failed, id)
+			continue
 		}
+		fmt.Fprintf(cli.out, "Pod(%s) is successful to be deleted!\n", id)
 	}
-	return nil
+	return podsFailed("remove", failed)
 }
 
 func (cli *HyperClient) RmPod(id string) error {
@@ -0,0 +1,260 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/go-units"
+	"github.com/hyperhq/hyper/engine"
+	"github.com/hyperhq/hyper/types"
+
+	gflag "github.com/jessevdk/go-flags"
+)
+
+// containerRates is the resource usage of a container between two samples,
+// the cpu is in the percent of one cpu and the I/O is in bytes per second.
+type containerRates struct {
+	Pod        string
+	Container  string
+	Cpu        float64
+	Memory     uint64
+	BlockRead  float64
+	BlockWrite float64
+	NetRx      float64
+	NetTx      float64
+}
+
+func (cli *HyperClient) HyperCmdStats(args ...string) error {
+	var opts struct {
+		NoStream bool `long:"no-stream" default:"false" default-mask:"-" description:"Print the usage once instead of refreshing it"`
+	}
+	var parser = gflag.NewParser(&opts, gflag.Default)
+	parser.Usage = "stats [OPTIONS] [POD...]\n\nDisplay a live table of the resource usage of the containers in the pods, all the running pods by default"
+	args, err := parser.ParseArgs(args)
+	if err != nil {
+		if !strings.Contains(err.Error(), "Usage") {
+			return err
+		} else {
+			return nil
+		}
+	}
+
+	pods := args
+	if len(pods) == 0 {
+		if pods, err = cli.runningPods(); err != nil {
+			return err
+		}
+		if len(pods) == 0 {
+			return fmt.Errorf("There is no running pod")
+		}
+	}
+
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		rates = make(map[string][]containerRates)
+		errs  []string
+		done  = make(chan struct{})
+	)
+	for _, pod := range pods {
+		wg.Add(1)
+		go func(pod string) {
+			defer wg.Done()
+			err := cli.streamPodStats(pod, opts.NoStream, func(r []containerRates) {
+				mu.Lock()
+				rates[pod] = r
+				mu.Unlock()
+			})
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Sprintf("%s: %s", pod, err.Error()))
+				mu.Unlock()
+			}
+		}(pod)
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	if !opts.NoStream {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+	refresh:
+		for {
+			select {
+			case <-ticker.C:
+				mu.Lock()
+				// clear the screen and move to the top left
+				fmt.Fprint(cli.out, "\033[2J\033[H")
+				printStats(cli.out, rates)
+				mu.Unlock()
+			case <-done:
+				break refresh
+			}
+		}
+	} else {
+		<-done
+		printStats(cli.out, rates)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// runningPods returns the IDs of the running pods.
+func (cli *HyperClient) runningPods() ([]string, error) {
+	v := url.Values{}
+	v.Set("item", "pod")
+	body, _, err := readBody(cli.call("GET", "/list?"+v.Encode(), nil, nil))
+	if err != nil {
+		return nil, err
+	}
+	out := engine.NewOutput()
+	remoteInfo, err := out.AddEnv()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := out.Write(body); err != nil {
+		return nil, err
+	}
+	out.Close()
+
+	var pods []string
+	for _, p := range remoteInfo.GetList("podData") {
+		// id:name:vm:status
+		fields := strings.Split(p, ":")
+		if len(fields) > 3 && fields[3] == "running" {
+			pods = append(pods, fields[0])
+		}
+	}
+	return pods, nil
+}
+
+// streamPodStats reads the stats stream of the pod, and updates the rates
+// with each sample after the first one. Only the first rates are read if
+// once is set.
+func (cli *HyperClient) streamPodStats(pod string, once bool, update func([]containerRates)) error {
+	v := url.Values{}
+	v.Set("podId", pod)
+	v.Set("stream", "1")
+	body, _, _, err := cli.clientRequest("GET", "/pod/stats?"+v.Encode(), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	dec := json.NewDecoder(body)
+	var prev *types.PodStats
+	for {
+		var cur types.PodStats
+		if err := dec.Decode(&cur); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if prev != nil {
+			update(podRates(prev, &cur))
+			if once {
+				return nil
+			}
+		}
+		prev = &cur
+	}
+}
+
+// podRates computes the rates of the containers in both samples.
+func podRates(prev, cur *types.PodStats) []containerRates {
+	previous := make(map[string]*types.ContainerStats)
+	for i := range prev.Containers {
+		previous[prev.Containers[i].ContainerID] = &prev.Containers[i]
+	}
+
+	pod := cur.PodName
+	if pod == "" {
+		pod = cur.PodID
+	}
+
+	var rates []containerRates
+	for i := range cur.Containers {
+		c := &cur.Containers[i]
+		p, ok := previous[c.ContainerID]
+		if !ok {
+			continue
+		}
+		seconds := c.Timestamp.Sub(p.Timestamp).Seconds()
+		if seconds <= 0 {
+			continue
+		}
+
+		name := c.Name
+		if name == "" {
+			name = stringid.TruncateID(c.ContainerID)
+		}
+
+		r := containerRates{
+			Pod:       pod,
+			Container: name,
+			Cpu:       rate(p.Cpu.Usage.Total, c.Cpu.Usage.Total, seconds) / 1e9 * 100,
+			Memory:    c.Memory.Usage,
+		}
+		prevRead, prevWrite := p.Blkio.Bytes()
+		read, write := c.Blkio.Bytes()
+		r.BlockRead, r.BlockWrite = rate(prevRead, read, seconds), rate(prevWrite, write, seconds)
+		prevRx, prevTx := p.Network.Bytes()
+		rx, tx := c.Network.Bytes()
+		r.NetRx, r.NetTx = rate(prevRx, rx, seconds), rate(prevTx, tx, seconds)
+
+		rates = append(rates, r)
+	}
+	return rates
+}
+
+// rate returns the increase of the counter per second, a counter reset is
+// taken as no increase.
+func rate(prev, cur uint64, seconds float64) float64 {
+	if cur < prev {
+		return 0
+	}
+	return float64(cur-prev) / seconds
+}
+
+func printStats(out io.Writer, rates map[string][]containerRates) {
+	var all []containerRates
+	for _, r := range rates {
+		all = append(all, r...)
+	}
+	sort.Sort(ratesByName(all))
+
+	w := tabwriter.NewWriter(out, 20, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "POD\tCONTAINER\tCPU %\tMEM USAGE\tBLOCK I/O (R/W)\tNET I/O (RX/TX)")
+	for _, r := range all {
+		fmt.Fprintf(w, "%s\t%s\t%.2f%%\t%s\t%s/s / %s/s\t%s/s / %s/s\n", r.Pod, r.Container, r.Cpu,
+			units.BytesSize(float64(r.Memory)),
+			units.HumanSize(r.BlockRead), units.HumanSize(r.BlockWrite),
+			units.HumanSize(r.NetRx), units.HumanSize(r.NetTx))
+	}
+	w.Flush()
+}
+
+type ratesByName []containerRates
+
+func (r ratesByName) Len() int      { return len(r) }
+func (r ratesByName) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r ratesByName) Less(i, j int) bool {
+	if r[i].Pod != r[j].Pod {
+		return r[i].Pod < r[j].Pod
+	}
+	return r[i].Container < r[j].Container
+}
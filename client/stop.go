@@ -3,6 +3,7 @@ package client
 import (
 	"fmt"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/hyperhq/hyper/engine"
@@ -14,10 +15,13 @@ import (
 func (cli *HyperClient) HyperCmdStop(args ...string) error {
 
 	var opts struct {
-		Novm bool `long:"onlypod" default:"false" description:"Stop a Pod, but left the VM running"`
+		Novm     bool   `long:"onlypod" default:"false" description:"Stop a Pod, but left the VM running"`
+		Signal   string `short:"s" long:"signal" default:"TERM" value-name:"TERM" description:"Signal to send to the containers"`
+		Timeout  int    `short:"t" long:"timeout" default:"10" value-name:"10" description:"Seconds to wait for the containers to exit before killing the pod"`
+		Selector string `short:"l" long:"selector" value-name:"\"\"" description:"Stop the pods whose labels match the selector"`
 	}
 	var parser = gflag.NewParser(&opts, gflag.Default)
-	parser.Usage = "stop POD_ID\n\nStop a running pod"
+	parser.Usage = "stop [OPTIONS] POD_ID [POD_ID...]\n\nStop running pods, the containers are sent the signal and given a grace period to exit"
 	args, err := parser.ParseArgs(args)
 	if err != nil {
 		if !strings.Contains(err.Error(), "Usage") {
@@ -26,30 +30,50 @@ func (cli *HyperClient) HyperCmdStop(args ...string) error {
 			return nil
 		}
 	}
-	if len(args) == 0 {
-		return fmt.Errorf("\"stop\" requires a minimum of 1 argument, please provide POD ID.\n")
+	if len(args) == 0 && opts.Selector == "" {
+		return fmt.Errorf("\"stop\" requires a minimum of 1 argument, please provide POD ID or selector.\n")
+	}
+
+	pods, err := cli.podsToOperate(args, opts.Selector)
+	if err != nil {
+		return err
 	}
 
-	podID := args[0]
 	stopVm := "yes"
 	if opts.Novm {
 		stopVm = "no"
 	}
-	code, cause, err := cli.StopPod(podID, stopVm)
-	if err != nil {
-		return err
-	}
-	if code != types.E_POD_STOPPED && code != types.E_VM_SHUTDOWN {
-		return fmt.Errorf("Error code is %d, cause is %s", code, cause)
+	var failed []string
+	for _, podID := range pods {
+		code, cause, err := cli.StopPod(podID, stopVm, opts.Signal, opts.Timeout)
+		if err == nil && code != types.E_POD_STOPPED && code != types.E_VM_SHUTDOWN {
+			err = fmt.Errorf("Error code is %d, cause is %s", code, cause)
+		}
+		if err != nil {
+			if len(pods) == 1 {
+				return err
+			}
+			fmt.Fprintf(cli.err, "Failed to stop the POD %s: %v\n", podID, err)
+			failed = append(failed, podID)
+			continue
+		}
+		fmt.Printf("Successfully shutdown the POD: %s!\n", podID)
 	}
-	fmt.Printf("Successfully shutdown the POD: %s!\n", podID)
-	return nil
+	return podsFailed("stop", failed)
 }
 
-func (cli *HyperClient) StopPod(podId, stopVm string) (int, string, error) {
+// StopPod stops the pod, an empty signal or a negative timeout lets the
+// daemon pick the default one.
+func (cli *HyperClient) StopPod(podId, stopVm, signal string, timeout int) (int, string, error) {
 	v := url.Values{}
 	v.Set("podId", podId)
 	v.Set("stopVm", stopVm)
+	if signal != "" {
+		v.Set("signal", signal)
+	}
+	if timeout >= 0 {
+		v.Set("timeout", strconv.Itoa(timeout))
+	}
 	body, _, err := readBody(cli.call("POST", "/pod/stop?"+v.Encode(), nil, nil))
 	if err != nil {
 		if strings.Contains(err.Error(), "leveldb: not found") {
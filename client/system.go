@@ -0,0 +1,73 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/hyperhq/hyper/types"
+
+	gflag "github.com/jessevdk/go-flags"
+)
+
+func (cli *HyperClient) HyperCmdSystem(args ...string) error {
+	fmt.Fprintf(cli.out, "Usage:\n  %s system COMMAND\n\nManage the hyper daemon\n\nCommand:\n  prune                  Remove the resources left behind by the pods and VMs which are gone\n", os.Args[0])
+	return nil
+}
+
+func (cli *HyperClient) HyperCmdSystemPrune(args ...string) error {
+	var opts struct {
+		DryRun bool `long:"dry-run" default:"false" default-mask:"-" description:"Only list the orphaned resources, do not remove them"`
+	}
+	var parser = gflag.NewParser(&opts, gflag.Default)
+	parser.Usage = "system prune [OPTIONS]\n\nRemove the service and hosts directories, volumes, containers and database keys left behind by the pods and VMs which are gone"
+	args, err := parser.ParseArgs(args)
+	if err != nil {
+		if !strings.Contains(err.Error(), "Usage") {
+			return err
+		} else {
+			return nil
+		}
+	}
+
+	v := url.Values{}
+	if opts.DryRun {
+		v.Set("dryrun", "1")
+	}
+	body, _, err := readBody(cli.call("POST", "/system/prune?"+v.Encode(), nil, nil))
+	if err != nil {
+		return err
+	}
+
+	var report types.PruneReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		return err
+	}
+
+	action := "Removed"
+	if report.DryRun {
+		action = "Would remove"
+	}
+	for _, group := range []struct {
+		kind  string
+		items []string
+	}{
+		{"directory", report.Dirs},
+		{"volume", report.Volumes},
+		{"container", report.Containers},
+		{"database key", report.Keys},
+	} {
+		for _, item := range group.items {
+			fmt.Fprintf(cli.out, "%s %s %s\n", action, group.kind, item)
+		}
+	}
+	fmt.Fprintf(cli.out, "%s %d directories, %d volumes, %d containers and %d database keys\n", action,
+		len(report.Dirs), len(report.Volumes), len(report.Containers), len(report.Keys))
+
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("%s", strings.Join(report.Errors, "\n"))
+	}
+	return nil
+}
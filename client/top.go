@@ -0,0 +1,132 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hyperhq/hyper/types"
+
+	gflag "github.com/jessevdk/go-flags"
+)
+
+// topColumn is a column of hyper top, named as the one of ps.
+type topColumn struct {
+	title string
+	value func(p *types.ContainerProcess) string
+}
+
+var topColumns = map[string]topColumn{
+	"pid":  {"PID", func(p *types.ContainerProcess) string { return strconv.Itoa(p.Pid) }},
+	"ppid": {"PPID", func(p *types.ContainerProcess) string { return strconv.Itoa(p.Ppid) }},
+	"user": {"USER", func(p *types.ContainerProcess) string { return p.User }},
+	"uid":  {"UID", func(p *types.ContainerProcess) string { return strconv.Itoa(p.Uid) }},
+	"stat": {"STAT", func(p *types.ContainerProcess) string { return p.State }},
+	"%cpu": {"%CPU", func(p *types.ContainerProcess) string { return fmt.Sprintf("%.1f", p.CpuPercent) }},
+	"rss":  {"RSS", func(p *types.ContainerProcess) string { return strconv.FormatUint(p.Rss/1024, 10) }},
+	"start": {"START", func(p *types.ContainerProcess) string {
+		return formatStartTime(time.Unix(p.StartTime, 0), time.Now())
+	}},
+	"time":    {"TIME", func(p *types.ContainerProcess) string { return formatCpuTime(p.CpuTime) }},
+	"comm":    {"COMMAND", func(p *types.ContainerProcess) string { return p.Command }},
+	"args":    {"COMMAND", func(p *types.ContainerProcess) string { return p.Args }},
+	"command": {"COMMAND", func(p *types.ContainerProcess) string { return p.Args }},
+	"cmd":     {"CMD", func(p *types.ContainerProcess) string { return p.Args }},
+}
+
+func (cli *HyperClient) HyperCmdTop(args ...string) error {
+	var opts struct {
+		Format string `short:"o" long:"format" default:"pid,user,%cpu,rss,start,time,args" value-name:"\"\"" description:"The comma separated columns to show, in pid, ppid, user, uid, stat, %cpu, rss (KiB), start, time, comm and args"`
+	}
+	var parser = gflag.NewParser(&opts, gflag.Default)
+	parser.Usage = "top [OPTIONS] CONTAINER\n\nDisplay the running processes of a container"
+	args, err := parser.ParseArgs(args)
+	if err != nil {
+		if !strings.Contains(err.Error(), "Usage") {
+			return err
+		} else {
+			return nil
+		}
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("\"top\" requires a minimum of 1 argument, please provide the container.\n")
+	}
+
+	columns, err := parseTopColumns(opts.Format)
+	if err != nil {
+		return err
+	}
+
+	v := url.Values{}
+	v.Set("container", args[0])
+	body, _, err := readBody(cli.call("GET", "/container/top?"+v.Encode(), nil, nil))
+	if err != nil {
+		return err
+	}
+
+	var top types.ContainerTop
+	if err := json.Unmarshal(body, &top); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cli.out, 8, 1, 3, ' ', 0)
+	titles := make([]string, len(columns))
+	for i, c := range columns {
+		titles[i] = c.title
+	}
+	fmt.Fprintln(w, strings.Join(titles, "\t"))
+	for i := range top.Processes {
+		values := make([]string, len(columns))
+		for j, c := range columns {
+			values[j] = c.value(&top.Processes[i])
+		}
+		fmt.Fprintln(w, strings.Join(values, "\t"))
+	}
+	w.Flush()
+	return nil
+}
+
+func parseTopColumns(format string) ([]topColumn, error) {
+	var columns []topColumn
+	for _, name := range strings.Split(format, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		c, ok := topColumns[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown column %s", name)
+		}
+		columns = append(columns, c)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("No column to show")
+	}
+	return columns, nil
+}
+
+// formatStartTime shows the time of day for the processes started today,
+// and the date for the older ones, as ps does.
+func formatStartTime(start, now time.Time) string {
+	if now.Sub(start) < 24*time.Hour && start.YearDay() == now.YearDay() {
+		return start.Format("15:04")
+	}
+	if start.Year() == now.Year() {
+		return start.Format("Jan02")
+	}
+	return start.Format("2006")
+}
+
+// formatCpuTime shows the cpu time in [DD-]HH:MM:SS.
+func formatCpuTime(seconds float64) string {
+	total := int64(seconds)
+	days, hours, minutes := total/86400, total/3600%24, total/60%60
+	if days > 0 {
+		return fmt.Sprintf("%d-%02d:%02d:%02d", days, hours, minutes, total%60)
+	}
+	return fmt.Sprintf("%02d:%02d:%02d", hours, minutes, total%60)
+}
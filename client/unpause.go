@@ -10,8 +10,11 @@ import (
 )
 
 func (cli *HyperClient) HyperCmdUnpause(args ...string) error {
-	var parser = gflag.NewParser(nil, gflag.Default|gflag.IgnoreUnknown)
-	parser.Usage = "unpause Pod\n\nUnpause the pod"
+	var opts struct {
+		Selector string `short:"l" long:"selector" value-name:"\"\"" description:"Unpause the pods whose labels match the selector"`
+	}
+	var parser = gflag.NewParser(&opts, gflag.Default|gflag.IgnoreUnknown)
+	parser.Usage = "unpause [OPTIONS] Pod [Pod...]\n\nUnpause the pods"
 	args, err := parser.ParseArgs(args)
 	if err != nil {
 		if !strings.Contains(err.Error(), "Usage") {
@@ -20,12 +23,30 @@ func (cli *HyperClient) HyperCmdUnpause(args ...string) error {
 			return nil
 		}
 	}
-	if len(args) == 0 {
-		return fmt.Errorf("Can not accept the 'unpause' command without Pod ID!")
+	if len(args) == 0 && opts.Selector == "" {
+		return fmt.Errorf("Can not accept the 'unpause' command without Pod ID or selector!")
+	}
+
+	pods, err := cli.podsToOperate(args, opts.Selector)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, podId := range pods {
+		if err := cli.UnpausePod(podId); err != nil {
+			if len(pods) == 1 {
+				return err
+			}
+			fmt.Fprintf(cli.err, "Failed to unpause pod %s: %v\n", podId, err)
+			failed = append(failed, podId)
+		}
 	}
+	return podsFailed("unpause", failed)
+}
 
+func (cli *HyperClient) UnpausePod(podId string) error {
 	v := url.Values{}
-	v.Set("podId", args[0])
+	v.Set("podId", podId)
 
 	body, _, err := readBody(cli.call("POST", "/pod/unpause?"+v.Encode(), nil, nil))
 	if err != nil {
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/hyperhq/hyper/engine"
+	gflag "github.com/jessevdk/go-flags"
+)
+
+func (cli *HyperClient) HyperCmdUpdate(args ...string) error {
+	var opts struct {
+		Cpu    int `long:"cpu" default:"0" value-name:"0" default-mask:"-" description:"New CPU number of the pod"`
+		Memory int `long:"memory" default:"0" value-name:"0" default-mask:"-" description:"New memory size (MB) of the pod"`
+	}
+	var parser = gflag.NewParser(&opts, gflag.Default)
+	parser.Usage = "update [OPTIONS] POD_ID\n\nUpdate the CPU and memory of a pod, a running pod can only grow"
+	args, err := parser.ParseArgs(args)
+	if err != nil {
+		if !strings.Contains(err.Error(), "Usage") {
+			return err
+		} else {
+			return nil
+		}
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("Can not accept the 'update' command without Pod ID!")
+	}
+	if opts.Cpu <= 0 && opts.Memory <= 0 {
+		return fmt.Errorf("Please specify the new --cpu or --memory of the pod")
+	}
+
+	v := url.Values{}
+	v.Set("podId", args[0])
+	if opts.Cpu > 0 {
+		v.Set("cpu", strconv.Itoa(opts.Cpu))
+	}
+	if opts.Memory > 0 {
+		v.Set("memory", strconv.Itoa(opts.Memory))
+	}
+
+	body, _, err := readBody(cli.call("POST", "/pod/update?"+v.Encode(), nil, nil))
+	if err != nil {
+		return err
+	}
+
+	out := engine.NewOutput()
+	remoteInfo, err := out.AddEnv()
+	if err != nil {
+		return err
+	}
+
+	if _, err := out.Write(body); err != nil {
+		return err
+	}
+	out.Close()
+
+	fmt.Fprintf(cli.out, "Pod %s: %d vCPUs, %dMB memory\n", remoteInfo.Get("ID"), remoteInfo.GetInt("Vcpu"), remoteInfo.GetInt("Memory"))
+	return nil
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hyperhq/hyper/types"
+
+	gflag "github.com/jessevdk/go-flags"
+)
+
+func (cli *HyperClient) HyperCmdWait(args ...string) error {
+	var opts struct {
+		Container bool `short:"c" long:"container" default:"false" default-mask:"-" description:"Wait for containers instead of pods"`
+		Timeout   int  `short:"t" long:"timeout" default:"0" value-name:"0" default-mask:"-" description:"Seconds to wait before giving up, 0 means waiting forever"`
+	}
+	var parser = gflag.NewParser(&opts, gflag.Default)
+	parser.Usage = "wait [OPTIONS] POD|CONTAINER [POD|CONTAINER...]\n\nBlock until the pods or containers stop, then print their exit codes"
+	args, err := parser.ParseArgs(args)
+	if err != nil {
+		if !strings.Contains(err.Error(), "Usage") {
+			return err
+		} else {
+			return nil
+		}
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("\"wait\" requires a minimum of 1 argument, please provide POD ID or container.\n")
+	}
+
+	var errs []string
+	w := tabwriter.NewWriter(cli.out, 20, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tName\tPhase\tExit Code")
+	for _, id := range args {
+		if opts.Container {
+			c, err := cli.WaitContainer(id, opts.Timeout)
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", c.ContainerID, c.Name, c.Phase, c.ExitCode)
+			continue
+		}
+
+		p, err := cli.WaitPod(id, opts.Timeout)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		for _, c := range p.Containers {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", c.ContainerID, c.Name, c.Phase, c.ExitCode)
+		}
+	}
+	w.Flush()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+func (cli *HyperClient) WaitPod(podName string, timeout int) (*types.PodExit, error) {
+	v := url.Values{}
+	v.Set("podName", podName)
+	v.Set("timeout", strconv.Itoa(timeout))
+	body, _, err := readBody(cli.call("POST", "/pod/wait?"+v.Encode(), nil, nil))
+	if err != nil {
+		return nil, err
+	}
+
+	var result types.PodExit
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (cli *HyperClient) WaitContainer(container string, timeout int) (*types.ContainerExit, error) {
+	v := url.Values{}
+	v.Set("container", container)
+	v.Set("timeout", strconv.Itoa(timeout))
+	body, _, err := readBody(cli.call("POST", "/container/wait?"+v.Encode(), nil, nil))
+	if err != nil {
+		return nil, err
+	}
+
+	var result types.ContainerExit
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,161 @@
+package daemon
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hyperhq/hyper/lib/sysinfo"
+	apitypes "github.com/hyperhq/hyper/types"
+	"github.com/hyperhq/runv/hypervisor/pod"
+	"github.com/hyperhq/runv/hypervisor/types"
+)
+
+const DefaultMaxPods = 1024
+
+// AdmissionConfig is the capacity of the host for the VMs, the memory is in
+// MB. A zero limit means unlimited.
+type AdmissionConfig struct {
+	MaxCpus        int
+	MaxMemory      int
+	ReservedMemory int
+	MaxPods        int
+}
+
+// allocation is an amount of the resource taken by the VMs.
+type allocation struct {
+	cpus, mem, pods int
+}
+
+func (a *allocation) add(b allocation) {
+	a.cpus += b.cpus
+	a.mem += b.mem
+	a.pods += b.pods
+}
+
+// admission rejects the pods which would make the resource allocated on
+// the host go beyond the capacity. The allocation is the resource of the
+// running pods and the cached VMs, plus the pending requests which have
+// been admitted but whose pods are not running yet.
+type admission struct {
+	sync.Mutex
+	maxCpus int
+	maxMem  int
+	maxPods int
+	pending map[string]allocation
+}
+
+func podAllocation(resource pod.UserResource) allocation {
+	a := allocation{cpus: resource.Vcpu, mem: resource.Memory, pods: 1}
+	if a.cpus <= 0 {
+		a.cpus = defaultVmCpu
+	}
+	if a.mem <= 0 {
+		a.mem = defaultVmMem
+	}
+	return a
+}
+
+// InitAdmission sets up the admission control, the memory available to the
+// VMs is limited by both MaxMemory and the host memory minus ReservedMemory.
+func (daemon *Daemon) InitAdmission(config *AdmissionConfig) error {
+	if config.MaxCpus < 0 || config.MaxMemory < 0 || config.ReservedMemory < 0 || config.MaxPods < 0 {
+		return fmt.Errorf("Invalid admission config %#v, the limits can not be negative", *config)
+	}
+
+	maxMem := config.MaxMemory
+	if config.ReservedMemory > 0 {
+		meminfo, err := sysinfo.GetMemInfo()
+		if err != nil {
+			return err
+		}
+		// MemTotal of /proc/meminfo is in kB
+		hostMem := int(meminfo.MemTotal / 1024)
+		if config.ReservedMemory >= hostMem {
+			return fmt.Errorf("Reserved memory %dMB is not less than the host memory %dMB", config.ReservedMemory, hostMem)
+		}
+		if maxMem == 0 || hostMem-config.ReservedMemory < maxMem {
+			maxMem = hostMem - config.ReservedMemory
+		}
+	}
+
+	a := &daemon.admission
+	a.Lock()
+	a.maxCpus = config.MaxCpus
+	a.maxMem = maxMem
+	a.maxPods = config.MaxPods
+	a.Unlock()
+	return nil
+}
+
+// allocated sums up the resource of the running pods, the cached VMs and
+// the pending requests, the caller must hold the lock of the admission.
+func (daemon *Daemon) allocated() (allocation, int) {
+	var total allocation
+
+	for _, p := range daemon.PodList.snapshot() {
+		if _, ok := daemon.admission.pending[p.id]; ok {
+			continue
+		}
+		p.RLock()
+		if p.status.Status == types.S_POD_RUNNING || p.status.Status == types.S_POD_PAUSED {
+			total.add(podAllocation(p.spec.Resource))
+		}
+		p.RUnlock()
+	}
+
+	cached := daemon.vmCache.allocated()
+	total.add(allocation{cpus: cached.cpus, mem: cached.mem})
+
+	for _, a := range daemon.admission.pending {
+		total.add(a)
+	}
+	return total, cached.pods
+}
+
+// admit checks whether the request fits in the capacity, the admitted
+// request is counted as pending until the returned release function is
+// called, which should be after the pod is running or failed to start.
+func (daemon *Daemon) admit(id string, req allocation) (func(), error) {
+	a := &daemon.admission
+	a.Lock()
+	defer a.Unlock()
+
+	total, _ := daemon.allocated()
+	if a.maxPods > 0 && req.pods > 0 && total.pods+req.pods > a.maxPods {
+		return nil, fmt.Errorf("Pod full, %d of the maximum %d pods are running", total.pods, a.maxPods)
+	}
+	if a.maxCpus > 0 && req.cpus > 0 && total.cpus+req.cpus > a.maxCpus {
+		return nil, fmt.Errorf("Insufficient vCPUs for %s: %d requested, %d of %d allocated", id, req.cpus, total.cpus, a.maxCpus)
+	}
+	if a.maxMem > 0 && req.mem > 0 && total.mem+req.mem > a.maxMem {
+		return nil, fmt.Errorf("Insufficient memory for %s: %dMB requested, %dMB of %dMB allocated", id, req.mem, total.mem, a.maxMem)
+	}
+
+	if a.pending == nil {
+		a.pending = make(map[string]allocation)
+	}
+	a.pending[id] = req
+	return func() {
+		a.Lock()
+		delete(a.pending, id)
+		a.Unlock()
+	}, nil
+}
+
+// ResourceInfo returns the resource allocated on the host and the capacity.
+func (daemon *Daemon) ResourceInfo() *apitypes.ResourceInfo {
+	a := &daemon.admission
+	a.Lock()
+	defer a.Unlock()
+
+	total, cachedVms := daemon.allocated()
+	return &apitypes.ResourceInfo{
+		Cpus:      total.cpus,
+		MaxCpus:   a.maxCpus,
+		Memory:    total.mem,
+		MaxMemory: a.maxMem,
+		Pods:      total.pods,
+		MaxPods:   a.maxPods,
+		CachedVms: cachedVms,
+	}
+}
@@ -0,0 +1,91 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/hyperhq/runv/hypervisor"
+	"github.com/hyperhq/runv/hypervisor/pod"
+	"github.com/hyperhq/runv/hypervisor/types"
+)
+
+func TestAdmit(t *testing.T) {
+	c, err := newTestVmCache("1:128:0:2")
+	if err != nil {
+		t.Fatalf("failed to create the cache: %v", err)
+	}
+	d := c.daemon
+	d.PodList = NewPodList()
+	if err := d.InitAdmission(&AdmissionConfig{MaxCpus: 4, MaxMemory: 1024, MaxPods: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	running := newTestPod("pod-running")
+	running.spec = &pod.UserPod{Resource: pod.UserResource{Vcpu: 2, Memory: 512}}
+	d.PodList.Put(running)
+	stopped := newTestPod("pod-stopped")
+	stopped.status.Status = types.S_POD_SUCCEEDED
+	stopped.spec = &pod.UserPod{Resource: pod.UserResource{Vcpu: 4, Memory: 1024}}
+	d.PodList.Put(stopped)
+	c.put(&hypervisor.Vm{Id: "vm-cached", Cpu: 1, Mem: 128})
+
+	info := d.ResourceInfo()
+	if info.Cpus != 3 || info.Memory != 640 || info.Pods != 1 || info.CachedVms != 1 {
+		t.Fatalf("unexpected allocation %#v", info)
+	}
+
+	if _, err := d.admit("pod-large", allocation{cpus: 2, mem: 128, pods: 1}); err == nil {
+		t.Error("expect the vCPUs to be insufficient")
+	}
+	if _, err := d.admit("pod-large", allocation{cpus: 1, mem: 512, pods: 1}); err == nil {
+		t.Error("expect the memory to be insufficient")
+	}
+
+	release, err := d.admit("pod-a", podAllocation(pod.UserResource{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.admit("pod-b", allocation{pods: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.admit("pod-c", allocation{pods: 1}); err == nil {
+		t.Error("expect the pods to be full")
+	}
+
+	// the pending request is replaced by the pod once it is running
+	p := newTestPod("pod-a")
+	p.spec = &pod.UserPod{}
+	d.PodList.Put(p)
+	if info := d.ResourceInfo(); info.Cpus != 4 || info.Memory != 768 || info.Pods != 3 {
+		t.Errorf("unexpected allocation with the pending pod %#v", info)
+	}
+	release()
+	if info := d.ResourceInfo(); info.Cpus != 4 || info.Memory != 768 || info.Pods != 3 {
+		t.Errorf("unexpected allocation after release %#v", info)
+	}
+}
+
+func TestAdmitVmPool(t *testing.T) {
+	c, err := newTestVmCache("1:128:2:4,2:256:2:4")
+	if err != nil {
+		t.Fatalf("failed to create the cache: %v", err)
+	}
+	d := c.daemon
+	d.PodList = NewPodList()
+	if err := d.InitAdmission(&AdmissionConfig{MaxCpus: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the VMs fitting in the vCPUs left are booted
+	if booting := c.reserve(); len(booting) != 2 || booting[0] != c.pools[0] || booting[1] != c.pools[0] {
+		t.Fatalf("expect 2 VMs of the small flavor to boot, got %v", booting)
+	}
+	if c.pools[1].booting != 0 {
+		t.Errorf("expect no VM of the large flavor to boot, got %d", c.pools[1].booting)
+	}
+	if info := d.ResourceInfo(); info.Cpus != 2 || info.Memory != 256 || info.CachedVms != 2 {
+		t.Errorf("unexpected allocation %#v", info)
+	}
+	if booting := c.reserve(); len(booting) != 0 {
+		t.Errorf("expect the pools to be filled later, got %v", booting)
+	}
+}
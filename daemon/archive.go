@@ -0,0 +1,331 @@
+package daemon
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/chrootarchive"
+	"github.com/docker/docker/pkg/symlink"
+	"github.com/golang/glog"
+	"github.com/hyperhq/runv/hypervisor"
+	"github.com/hyperhq/runv/hypervisor/types"
+)
+
+// The archives are packed and unpacked on the host, in the rootfs of the
+// container in the shared dir of the VM or in the directory of a vfs
+// volume, so that nothing is needed in the image and the directories and
+// the permissions are kept. The rootfs of the storages which attach a
+// block device to the VM, e.g. devicemapper, is not accessible on the host
+// while the container is running, the regular files in it are read and
+// written through the VM instead.
+
+// vmFileTimeout is how long the VM is waited for to check a path in the
+// container.
+const vmFileTimeout = 10 * time.Second
+
+// containerDir is a directory of the host which is seen in the container,
+// or the container in the VM if vm is not nil.
+type containerDir struct {
+	root     string
+	rel      string
+	readOnly bool
+
+	vm        *hypervisor.Vm
+	container string
+}
+
+// containerPath returns the directory of the host holding the path in the
+// running container, and the path relative to it.
+func (daemon *Daemon) containerPath(name, filePath string) (*containerDir, error) {
+	if !path.IsAbs(filePath) {
+		return nil, fmt.Errorf("The path %s in the container should be absolute", filePath)
+	}
+	p, idx, err := daemon.ResolveContainer(name)
+	if err != nil {
+		return nil, err
+	}
+
+	p.RLock()
+	defer p.RUnlock()
+	c := p.status.Containers[idx]
+	if p.vm == nil || p.status.Status != types.S_POD_RUNNING || c.Status != types.S_POD_RUNNING {
+		return nil, fmt.Errorf("Container %s is not running", name)
+	}
+
+	filePath = path.Clean(filePath)
+	var (
+		mount    string
+		volume   string
+		readOnly bool
+	)
+	if idx < len(p.spec.Containers) {
+		for _, v := range p.spec.Containers[idx].Volumes {
+			mp := path.Clean(v.Path)
+			if (filePath == mp || strings.HasPrefix(filePath, strings.TrimSuffix(mp, "/")+"/")) && len(mp) > len(mount) {
+				mount, volume, readOnly = mp, v.Volume, v.ReadOnly
+			}
+		}
+	}
+	if mount != "" {
+		for _, v := range p.spec.Volumes {
+			if v.Name != volume {
+				continue
+			}
+			if v.Driver != "vfs" || v.Source == "" {
+				return &containerDir{rel: filePath, readOnly: readOnly, vm: p.vm, container: c.Id}, nil
+			}
+			return &containerDir{root: v.Source, rel: path.Join("/", strings.TrimPrefix(filePath, mount)), readOnly: readOnly}, nil
+		}
+		return nil, fmt.Errorf("Volume %s of container %s is not found", volume, name)
+	}
+
+	mountId, err := GetMountIdByContainer(daemon.Storage.Type(), c.Id)
+	if err != nil {
+		return nil, err
+	}
+	rootfs := path.Join(hypervisor.BaseDir, p.vm.Id, hypervisor.ShareDirTag, mountId, "rootfs")
+	if fi, err := os.Stat(rootfs); err != nil || !fi.IsDir() {
+		return &containerDir{rel: filePath, vm: p.vm, container: c.Id}, nil
+	}
+	return &containerDir{root: rootfs, rel: filePath}, nil
+}
+
+// resolve returns the path on the host with the symlinks evaluated in the
+// directory, so that the links in the container can't lead out of it.
+func (d *containerDir) resolve(rel string) (string, error) {
+	return symlink.FollowSymlinkInScope(path.Join(d.root, rel), d.root)
+}
+
+// ContainerArchivePath writes the tar archive of the file or the directory
+// at the path in the container, the entries are rooted at the base name of
+// the path. The link at the path is archived as is.
+func (daemon *Daemon) ContainerArchivePath(name, filePath string, w io.Writer) error {
+	d, err := daemon.containerPath(name, filePath)
+	if err != nil {
+		return err
+	}
+	if d.vm != nil {
+		glog.V(1).Infof("Archive %s of container %s through the VM", filePath, name)
+		return d.archiveFromVm(w)
+	}
+
+	dir, base := archive.SplitPathDirEntry(d.rel)
+	resolved, err := d.resolve(dir)
+	if err != nil {
+		return err
+	}
+	source := path.Join(resolved, base)
+	if _, err := os.Lstat(source); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("No such file or directory: %s in container %s", filePath, name)
+		}
+		return err
+	}
+
+	glog.V(1).Infof("Archive %s of container %s", filePath, name)
+	content, err := archive.TarResourceRebase(source, base)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	_, err = io.Copy(w, content)
+	return err
+}
+
+// ContainerExtractToPath extracts the tar archive to the path in the
+// container. The entries are extracted into the path if it is a directory,
+// otherwise the root entry of the archive is renamed to the path.
+func (daemon *Daemon) ContainerExtractToPath(name, filePath string, content io.Reader) error {
+	d, err := daemon.containerPath(name, filePath)
+	if err != nil {
+		return err
+	}
+	if d.readOnly {
+		return fmt.Errorf("The path %s is in a read-only volume of container %s", filePath, name)
+	}
+	if d.vm != nil {
+		glog.V(1).Infof("Extract the archive to %s of container %s through the VM", filePath, name)
+		return d.extractInVm(name, filePath, content)
+	}
+
+	dst, err := d.resolve(d.rel)
+	if err != nil {
+		return err
+	}
+
+	var base string
+	if fi, err := os.Stat(dst); err != nil || !fi.IsDir() {
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if strings.HasSuffix(filePath, "/") {
+			return fmt.Errorf("The directory %s doesn't exist in container %s", filePath, name)
+		}
+		// copy to a new name, rebase the archive on it
+		dst, base = path.Split(dst)
+		if fi, err := os.Stat(dst); err != nil || !fi.IsDir() {
+			return fmt.Errorf("The parent directory of %s doesn't exist in container %s", filePath, name)
+		}
+	}
+
+	sanitized := sanitizeArchive(content, base)
+	defer sanitized.Close()
+
+	glog.V(1).Infof("Extract the archive to %s of container %s", filePath, name)
+	return chrootarchive.Untar(sanitized, dst, &archive.TarOptions{
+		NoOverwriteDirNonDir: true,
+	})
+}
+
+// archiveFromVm writes the archive of the regular file at the path, which
+// is read through the VM. The directories can't be archived this way.
+func (d *containerDir) archiveFromVm(w io.Writer) error {
+	data, err := d.vm.ReadFile(d.container, d.rel)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	hdr := &tar.Header{
+		Name:     path.Base(d.rel),
+		Mode:     0644,
+		Size:     int64(len(data)),
+		Typeflag: tar.TypeReg,
+		ModTime:  time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// extractInVm writes the regular files of the archive through the VM, the
+// other entries are rejected. The files are extracted into the path if it
+// is a directory in the container, otherwise the root entry is renamed to
+// the path.
+func (d *containerDir) extractInVm(name, filePath string, content io.Reader) error {
+	dst, base := d.rel, ""
+	if execInContainer(d.vm, d.container, []string{"test", "-d", d.rel}, vmFileTimeout) != nil {
+		if strings.HasSuffix(filePath, "/") {
+			return fmt.Errorf("The directory %s doesn't exist in container %s", filePath, name)
+		}
+		dst, base = path.Split(d.rel)
+	}
+
+	sanitized := sanitizeArchive(content, base)
+	defer sanitized.Close()
+
+	tr := tar.NewReader(sanitized)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			return fmt.Errorf("Can not copy %s, only the regular files could be copied to the rootfs which is not on the host", hdr.Name)
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if err := d.vm.WriteFile(d.container, path.Join(dst, hdr.Name), data); err != nil {
+			return err
+		}
+	}
+}
+
+// cleanEntry returns the name of the entry relative to the root of the
+// archive, the entries leading out of it are rejected.
+func cleanEntry(name string) (string, error) {
+	for _, elem := range strings.Split(name, "/") {
+		if elem == ".." {
+			return "", fmt.Errorf("Invalid entry %s in the archive", name)
+		}
+	}
+	clean := strings.TrimLeft(name, "/")
+	if clean == "" {
+		return "", fmt.Errorf("Invalid entry %s in the archive", name)
+	}
+	return clean, nil
+}
+
+// rebaseEntry renames the root of the entry to base.
+func rebaseEntry(name, root, base string) string {
+	if name == root {
+		return base
+	}
+	if strings.HasPrefix(name, root+"/") {
+		return base + strings.TrimPrefix(name, root)
+	}
+	return name
+}
+
+// sanitizeArchive streams the entries of the archive and fails on the
+// ones leading out of the destination. The root entry is renamed to base
+// if it is not empty.
+func sanitizeArchive(content io.Reader, base string) io.ReadCloser {
+	pr, pw := io.Pipe()
+
+	go func() {
+		tr := tar.NewReader(content)
+		tw := tar.NewWriter(pw)
+		var root string
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				pw.CloseWithError(tw.Close())
+				return
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+
+			if hdr.Name, err = cleanEntry(hdr.Name); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if hdr.Typeflag == tar.TypeLink {
+				if hdr.Linkname, err = cleanEntry(hdr.Linkname); err != nil {
+					pw.CloseWithError(err)
+					return
+				}
+			}
+			if base != "" {
+				if root == "" {
+					root = strings.SplitN(strings.TrimSuffix(hdr.Name, "/"), "/", 2)[0]
+				}
+				hdr.Name = rebaseEntry(hdr.Name, root, base)
+				if hdr.Typeflag == tar.TypeLink {
+					hdr.Linkname = rebaseEntry(hdr.Linkname, root, base)
+				}
+			}
+
+			if err := tw.WriteHeader(hdr); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := io.Copy(tw, tr); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+
+	return pr
+}
@@ -0,0 +1,284 @@
+package daemon
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+
+	"github.com/docker/docker/pkg/reexec"
+	"github.com/hyperhq/hyper/utils"
+	"github.com/hyperhq/runv/hypervisor"
+	"github.com/hyperhq/runv/hypervisor/pod"
+)
+
+func init() {
+	// the archives are extracted by hyperd re-executed in a chroot
+	reexec.Init()
+}
+
+type testEntry struct {
+	name, content string
+	typeflag      byte
+	mode          int64
+	linkname      string
+	uid, gid      int
+}
+
+func newTestArchive(t *testing.T, entries ...testEntry) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typeflag, Mode: e.mode, Size: int64(len(e.content)), Linkname: e.linkname, Uid: e.uid, Gid: e.gid}
+		if e.typeflag != tar.TypeReg {
+			hdr.Size = 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// readTestArchive returns the entries of the archive by name.
+func readTestArchive(t *testing.T, data []byte) map[string]testEntry {
+	entries := map[string]testEntry{}
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[hdr.Name] = testEntry{hdr.Name, string(content), hdr.Typeflag, hdr.Mode & 07777, hdr.Linkname, hdr.Uid, hdr.Gid}
+	}
+	return entries
+}
+
+func TestSanitizeArchive(t *testing.T) {
+	data := newTestArchive(t,
+		testEntry{name: "conf/", typeflag: tar.TypeDir, mode: 0750},
+		testEntry{name: "conf/app.conf", content: "debug=1", typeflag: tar.TypeReg, mode: 0600},
+		testEntry{name: "conf/link", typeflag: tar.TypeLink, linkname: "conf/app.conf"},
+	)
+
+	rebased, err := ioutil.ReadAll(sanitizeArchive(bytes.NewReader(data), "etc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := readTestArchive(t, rebased)
+	if len(entries) != 3 || entries["etc/"].mode != 0750 || entries["etc/app.conf"].content != "debug=1" ||
+		entries["etc/link"].linkname != "etc/app.conf" {
+		t.Fatalf("unexpected rebased archive %v", entries)
+	}
+
+	kept, err := ioutil.ReadAll(sanitizeArchive(bytes.NewReader(data), ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries := readTestArchive(t, kept); len(entries) != 3 || entries["conf/app.conf"].mode != 0600 {
+		t.Fatalf("unexpected archive %v", entries)
+	}
+
+	for _, e := range []testEntry{
+		{name: "../evil", typeflag: tar.TypeReg},
+		{name: "conf/../../evil", typeflag: tar.TypeReg},
+		{name: "conf/link", typeflag: tar.TypeLink, linkname: "../../etc/passwd"},
+	} {
+		data := newTestArchive(t, e)
+		if _, err := ioutil.ReadAll(sanitizeArchive(bytes.NewReader(data), "")); err == nil {
+			t.Errorf("expect the entry %s -> %s to be rejected", e.name, e.linkname)
+		}
+	}
+
+	if _, err := ioutil.ReadAll(sanitizeArchive(bytes.NewReader([]byte("not a tar")), "")); err == nil {
+		t.Fatal("expect error for the invalid archive")
+	}
+}
+
+// newArchiveTestDaemon returns a daemon with a running container whose
+// rootfs is in the shared dir of the VM, and a vfs volume mounted at
+// /data, /config is mounted read-only.
+func newArchiveTestDaemon(t *testing.T) (*Daemon, string, string, func()) {
+	dir, err := ioutil.TempDir("", "hyper-archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldRoot, oldBaseDir := utils.HYPER_ROOT, hypervisor.BaseDir
+	utils.HYPER_ROOT = path.Join(dir, "root")
+	hypervisor.BaseDir = path.Join(dir, "vms")
+
+	p := newTestPod("pod-archive")
+	c := p.status.Containers[0]
+	p.vm = &hypervisor.Vm{Id: "vm-archive"}
+	p.spec = &pod.UserPod{
+		Containers: []pod.UserContainer{{Volumes: []pod.UserVolumeReference{
+			{Path: "/data", Volume: "data"},
+			{Path: "/config", Volume: "config", ReadOnly: true},
+		}}},
+		Volumes: []pod.UserVolume{
+			{Name: "data", Source: path.Join(dir, "data"), Driver: "vfs"},
+			{Name: "config", Source: path.Join(dir, "config"), Driver: "vfs"},
+		},
+	}
+
+	d := &Daemon{PodList: NewPodList(), Storage: &fakeStorage{root: path.Join(dir, "storage")}}
+	d.PodList.Put(p)
+
+	mountDir := path.Join(utils.HYPER_ROOT, "image/fake/layerdb/mounts", c.Id)
+	rootfs := path.Join(hypervisor.BaseDir, p.vm.Id, hypervisor.ShareDirTag, "mount-archive", "rootfs")
+	for _, dir := range []string{mountDir, path.Join(rootfs, "etc"), path.Join(dir, "data"), path.Join(dir, "config")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(path.Join(mountDir, "mount-id"), []byte("mount-archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return d, c.Id, rootfs, func() {
+		utils.HYPER_ROOT, hypervisor.BaseDir = oldRoot, oldBaseDir
+		os.RemoveAll(dir)
+	}
+}
+
+func TestContainerArchivePath(t *testing.T) {
+	d, container, rootfs, cleanup := newArchiveTestDaemon(t)
+	defer cleanup()
+
+	if err := ioutil.WriteFile(path.Join(rootfs, "etc/app.conf"), []byte("debug=1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path.Join(rootfs, "etc"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	// the links in the container are evaluated in its rootfs
+	if err := os.Symlink("/", path.Join(rootfs, "host")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := d.ContainerArchivePath(container, "/etc", &buf); err != nil {
+		t.Fatal(err)
+	}
+	entries := readTestArchive(t, buf.Bytes())
+	if len(entries) != 2 || entries["etc/"].mode != 0750 || entries["etc/app.conf"].mode != 0600 ||
+		entries["etc/app.conf"].content != "debug=1" {
+		t.Fatalf("unexpected archive %v", entries)
+	}
+
+	buf.Reset()
+	if err := d.ContainerArchivePath(container, "/host/etc/app.conf", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if entries := readTestArchive(t, buf.Bytes()); len(entries) != 1 || entries["app.conf"].content != "debug=1" {
+		t.Fatalf("unexpected archive %v", entries)
+	}
+
+	if err := d.ContainerArchivePath(container, "/host/etc/hostname", &buf); err == nil {
+		t.Fatal("expect the link to be evaluated in the rootfs")
+	}
+	if err := d.ContainerArchivePath(container, "etc", &buf); err == nil {
+		t.Fatal("expect error for the relative path")
+	}
+	if err := d.ContainerArchivePath("c-none", "/etc", &buf); err == nil {
+		t.Fatal("expect error for the unknown container")
+	}
+}
+
+func TestContainerExtractToPath(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("the archives are extracted in a chroot, which needs root")
+	}
+	d, container, rootfs, cleanup := newArchiveTestDaemon(t)
+	defer cleanup()
+
+	data := newTestArchive(t,
+		testEntry{name: "conf/", typeflag: tar.TypeDir, mode: 0750},
+		testEntry{name: "conf/app.conf", content: "debug=1", typeflag: tar.TypeReg, mode: 0600, uid: 1234, gid: 5678},
+	)
+	check := func(dir string) {
+		if fi, err := os.Stat(dir); err != nil || fi.Mode().Perm() != 0750 {
+			t.Fatalf("unexpected directory %s: %v, %v", dir, fi, err)
+		}
+		fi, err := os.Stat(path.Join(dir, "app.conf"))
+		if err != nil || fi.Mode().Perm() != 0600 {
+			t.Fatalf("unexpected file in %s: %v, %v", dir, fi, err)
+		}
+		// the owner in the archive is kept
+		if st := fi.Sys().(*syscall.Stat_t); st.Uid != 1234 || st.Gid != 5678 {
+			t.Fatalf("expect the file to be owned by 1234:5678, got %d:%d", st.Uid, st.Gid)
+		}
+	}
+
+	// into a directory
+	if err := d.ContainerExtractToPath(container, "/etc", bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	check(path.Join(rootfs, "etc/conf"))
+
+	// as a new name, in the volume
+	if err := d.ContainerExtractToPath(container, "/data/app", bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := d.PodList.Get("pod-archive")
+	check(path.Join(p.spec.Volumes[0].Source, "app"))
+
+	if err := d.ContainerExtractToPath(container, "/config", bytes.NewReader(data)); err == nil {
+		t.Fatal("expect the read-only volume to be rejected")
+	}
+	if err := d.ContainerExtractToPath(container, "/none/", bytes.NewReader(data)); err == nil {
+		t.Fatal("expect error for the missing directory")
+	}
+
+	evil := newTestArchive(t, testEntry{name: "../evil", content: "x", typeflag: tar.TypeReg, mode: 0644})
+	if err := d.ContainerExtractToPath(container, "/etc", bytes.NewReader(evil)); err == nil {
+		t.Fatal("expect the entry out of the destination to be rejected")
+	}
+	if _, err := os.Stat(path.Join(rootfs, "evil")); !os.IsNotExist(err) {
+		t.Fatalf("expect no file out of the destination: %v", err)
+	}
+}
+
+func TestContainerArchiveThroughVm(t *testing.T) {
+	d, container, rootfs, cleanup := newArchiveTestDaemon(t)
+	defer cleanup()
+
+	// the rootfs of the block device storages is only seen in the VM
+	if err := os.RemoveAll(rootfs); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := d.ContainerArchivePath(container, "/etc/app.conf", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if entries := readTestArchive(t, buf.Bytes()); len(entries) != 1 || entries["app.conf"].typeflag != tar.TypeReg {
+		t.Fatalf("unexpected archive %v", entries)
+	}
+
+	file := newTestArchive(t, testEntry{name: "app.conf", content: "debug=1", typeflag: tar.TypeReg, mode: 0644})
+	if err := d.ContainerExtractToPath(container, "/etc", bytes.NewReader(file)); err != nil {
+		t.Fatal(err)
+	}
+	dir := newTestArchive(t, testEntry{name: "conf/", typeflag: tar.TypeDir, mode: 0755})
+	if err := d.ContainerExtractToPath(container, "/etc", bytes.NewReader(dir)); err == nil {
+		t.Fatal("expect the directory to be rejected")
+	}
+	evil := newTestArchive(t, testEntry{name: "../evil", content: "x", typeflag: tar.TypeReg, mode: 0644})
+	if err := d.ContainerExtractToPath(container, "/etc", bytes.NewReader(evil)); err == nil {
+		t.Fatal("expect the entry out of the destination to be rejected")
+	}
+}
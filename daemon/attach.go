@@ -26,16 +26,20 @@ func (daemon *Daemon) Attach(stdin io.ReadCloser, stdout io.WriteCloser, key, id
 
 	// We need find the vm id which running POD, and stop it
 	if key == "pod" {
-		podId = id
+		pod, err := daemon.ResolvePod(id)
+		if err != nil {
+			return err
+		}
+		podId = pod.id
 		container = ""
 	} else {
-		container = id
-		pod, _, err := daemon.GetPodByContainerIdOrName(container)
+		pod, idx, err := daemon.ResolveContainerOrPod(id)
 		if err != nil {
 			return err
 		}
 
 		podId = pod.id
+		container = pod.status.Containers[idx].Id
 		pod.RLock()
 		pod.ttyList[tag] = tty
 		pod.RUnlock()
@@ -54,7 +58,7 @@ func (daemon *Daemon) Attach(stdin io.ReadCloser, stdout io.WriteCloser, key, id
 		return err
 	}
 
-	vm, ok := daemon.VmList[vmId]
+	vm, ok := daemon.VmList.Get(vmId)
 	if !ok {
 		err = fmt.Errorf("Can find VM whose Id is %s!", vmId)
 		return err
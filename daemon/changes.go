@@ -0,0 +1,39 @@
+package daemon
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/docker/docker/pkg/archive"
+	"github.com/golang/glog"
+)
+
+type changesByPath []archive.Change
+
+func (c changesByPath) Len() int           { return len(c) }
+func (c changesByPath) Less(i, j int) bool { return c[i].Path < c[j].Path }
+func (c changesByPath) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+
+// ContainerChanges returns the files added, changed and deleted in the
+// writable layer of the container against its image, sorted by path.
+func (daemon *Daemon) ContainerChanges(name string) ([]archive.Change, error) {
+	pod, idx, err := daemon.ResolveContainer(name)
+	if err != nil {
+		return nil, err
+	}
+	if daemon.Storage == nil {
+		return nil, fmt.Errorf("No storage driver to get the changes of container %s", name)
+	}
+
+	id := pod.status.Containers[idx].Id
+	glog.V(1).Infof("Get the changes of container %s by %s", id, daemon.Storage.Type())
+	changes, err := daemon.Storage.Changes(daemon, id)
+	if err != nil {
+		return nil, err
+	}
+	if changes == nil {
+		changes = []archive.Change{}
+	}
+	sort.Sort(changesByPath(changes))
+	return changes, nil
+}
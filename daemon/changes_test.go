@@ -0,0 +1,49 @@
+package daemon
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/pkg/archive"
+)
+
+type changesStorage struct {
+	Storage
+	changes map[string][]archive.Change
+}
+
+func (s *changesStorage) Type() string { return "test" }
+
+func (s *changesStorage) Changes(daemon *Daemon, containerId string) ([]archive.Change, error) {
+	return s.changes[containerId], nil
+}
+
+func TestContainerChanges(t *testing.T) {
+	d, cleanup := newTestDBDaemon(t)
+	defer cleanup()
+	d.PodList.Put(newTestPod("pod-changes"))
+	d.Storage = &changesStorage{changes: map[string][]archive.Change{
+		"container-pod-changes": {
+			{Path: "/etc/hosts", Kind: archive.ChangeModify},
+			{Path: "/etc", Kind: archive.ChangeModify},
+			{Path: "/data", Kind: archive.ChangeAdd},
+		},
+	}}
+
+	changes, err := d.ContainerChanges("c-pod-changes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []archive.Change{
+		{Path: "/data", Kind: archive.ChangeAdd},
+		{Path: "/etc", Kind: archive.ChangeModify},
+		{Path: "/etc/hosts", Kind: archive.ChangeModify},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("unexpected changes %v", changes)
+	}
+
+	if _, err := d.ContainerChanges("c-pod-none"); err == nil {
+		t.Error("expect error for the container which doesn't exist")
+	}
+}
@@ -0,0 +1,114 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	dockertypes "github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/container"
+	"github.com/golang/glog"
+	apitypes "github.com/hyperhq/hyper/types"
+)
+
+// CommitPod commits the containers of the pod, except the service discovery
+// one, to the images named by the prefix and the container names. The
+// returned spec is the one of the pod with the images replaced, so that the
+// pod could be recreated from the images with the same volumes, files,
+// services and resources.
+func (daemon *Daemon) CommitPod(podId, prefix string, cfg *dockertypes.ContainerCommitConfig) (*apitypes.PodCommitResult, error) {
+	if prefix == "" {
+		return nil, fmt.Errorf("The prefix of the images is not specified")
+	}
+	tag := cfg.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+
+	p, err := daemon.ResolvePod(podId)
+	if err != nil {
+		return nil, err
+	}
+
+	p.opLock.Lock()
+	glog.V(2).Infof("lock pod %s", p.id)
+	defer glog.V(2).Infof("unlock pod %s", p.id)
+	defer p.opLock.Unlock()
+
+	raw, err := daemon.GetPodByName(p.id)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := "/" + ServiceDiscoveryContainerName(p.spec.Name)
+	result := &apitypes.PodCommitResult{}
+	for _, c := range p.status.Containers {
+		if c.Name == serviceName {
+			continue
+		}
+
+		name := strings.TrimPrefix(c.Name, "/")
+		repo := commitRepo(prefix, name)
+		// the config of each container is kept in its image
+		commitCfg := *cfg
+		commitCfg.Repo, commitCfg.Tag = repo, tag
+		commitCfg.Config, commitCfg.MergeConfigs = &container.Config{}, true
+
+		glog.V(1).Infof("Commit container %s of pod %s to %s:%s", c.Id, p.id, repo, tag)
+		imgId, err := daemon.Daemon.Commit(c.Id, &commitCfg)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to commit container %s: %s", name, err.Error())
+		}
+
+		result.Images = append(result.Images, apitypes.CommittedImage{
+			Container: name,
+			Image:     repo + ":" + tag,
+			ID:        imgId,
+		})
+	}
+	if len(result.Images) == 0 {
+		return nil, fmt.Errorf("Pod %s has no container to commit", podId)
+	}
+
+	images := make([]string, len(result.Images))
+	for i, img := range result.Images {
+		images[i] = img.Image
+	}
+	if result.Spec, err = committedPodSpec(raw, images); err != nil {
+		return nil, err
+	}
+
+	daemon.LogPodEvent(p, "commit")
+	return result, nil
+}
+
+// commitRepo returns the repository of the image committed from the
+// container, the repository names are lower case.
+func commitRepo(prefix, container string) string {
+	return strings.ToLower(prefix + "-" + container)
+}
+
+// committedPodSpec replaces the images of the containers in the raw pod
+// spec, the images are in the order of the containers. The raw spec rather
+// than the runv UserPod is used, so that the service discovery container
+// and volume are not in it, and the fields handled by hyper are kept.
+func committedPodSpec(raw []byte, images []string) ([]byte, error) {
+	spec := make(map[string]interface{})
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		return nil, err
+	}
+
+	containers, _ := spec["containers"].([]interface{})
+	if len(containers) != len(images) {
+		return nil, fmt.Errorf("The pod spec has %d containers, but %d are committed", len(containers), len(images))
+	}
+	for i, c := range containers {
+		container, ok := c.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Invalid container %d in the pod spec", i)
+		}
+		container["image"] = images[i]
+	}
+
+	return json.MarshalIndent(spec, "", "    ")
+}
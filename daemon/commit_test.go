@@ -0,0 +1,59 @@
+package daemon
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCommittedPodSpec(t *testing.T) {
+	raw := []byte(`{
+		"id": "web",
+		"containers": [
+			{"name": "nginx", "image": "nginx", "livenessProbe": {"exec": {"command": ["true"]}}},
+			{"image": "busybox", "files": [{"filename": "conf", "path": "/etc/conf"}]}
+		],
+		"resource": {"vcpu": 2, "memory": 256},
+		"files": [{"name": "conf", "encoding": "raw", "content": "x"}],
+		"volumes": [{"name": "data", "source": "", "driver": ""}],
+		"services": [{"serviceip": "10.0.0.1", "serviceport": 80}]
+	}`)
+
+	data, err := committedPodSpec(raw, []string{"app-nginx:v1", "app-busybox:v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var spec, orig map[string]interface{}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(raw, &orig); err != nil {
+		t.Fatal(err)
+	}
+
+	containers := spec["containers"].([]interface{})
+	for i, image := range []string{"app-nginx:v1", "app-busybox:v1"} {
+		if c := containers[i].(map[string]interface{}); c["image"] != image {
+			t.Errorf("expect the image of container %d to be %s, got %v", i, image, c["image"])
+		}
+	}
+	if _, ok := containers[0].(map[string]interface{})["livenessProbe"]; !ok {
+		t.Error("the probe of the container is dropped")
+	}
+	for _, key := range []string{"id", "resource", "files", "volumes", "services"} {
+		if !reflect.DeepEqual(spec[key], orig[key]) {
+			t.Errorf("expect %s to be kept, got %v", key, spec[key])
+		}
+	}
+
+	if _, err := committedPodSpec(raw, []string{"app-nginx:v1"}); err == nil {
+		t.Error("expect the mismatched images to be rejected")
+	}
+}
+
+func TestCommitRepo(t *testing.T) {
+	if repo := commitRepo("registry:5000/App", "web-Nginx"); repo != "registry:5000/app-web-nginx" {
+		t.Errorf("unexpected repository %s", repo)
+	}
+}
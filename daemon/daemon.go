@@ -6,6 +6,8 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/Unknwon/goconfig"
@@ -13,8 +15,11 @@ import (
 	"github.com/docker/docker/daemon/logger/jsonfilelog"
 	"github.com/docker/docker/opts"
 	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/pkg/version"
 	"github.com/docker/docker/registry"
+	dockertypes "github.com/docker/engine-api/types"
 	"github.com/golang/glog"
+	"github.com/hyperhq/hyper/daemon/events"
 	"github.com/hyperhq/hyper/utils"
 	"github.com/hyperhq/runv/hypervisor"
 	"github.com/hyperhq/runv/hypervisor/pod"
@@ -27,13 +32,24 @@ var (
 	DefaultResourcePath string = "/var/run/hyper/Pods"
 )
 
+// containerEngine creates and inspects the containers of the pods, it is
+// the docker daemon except in the tests.
+type containerEngine interface {
+	ContainerCreate(params dockertypes.ContainerCreateConfig) (dockertypes.ContainerCreateResponse, error)
+	ContainerInspect(name string, size bool, version version.Version) (interface{}, error)
+	ContainerRm(name string, config *dockertypes.ContainerRmConfig) error
+}
+
 type Daemon struct {
 	*docker.Daemon
+	engine      containerEngine
 	ID          string
 	db          *leveldb.DB
+	events      *events.Events
 	PodList     *PodList
-	VmList      map[string]*hypervisor.Vm
+	VmList      *VmList
 	vmCache     VmCache
+	admission   admission
 	Kernel      string
 	Initrd      string
 	Bios        string
@@ -45,60 +61,12 @@ type Daemon struct {
 	Storage     Storage
 	Hypervisor  string
 	DefaultLog  *pod.PodLogConfig
-}
-
-func (daemon *Daemon) Restore() error {
-	if daemon.GetPodNum() == 0 {
-		return nil
-	}
-
-	podList := map[string]string{}
-
-	iter := daemon.db.NewIterator(util.BytesPrefix([]byte("pod-")), nil)
-	for iter.Next() {
-		key := iter.Key()
-		value := iter.Value()
-		if strings.Contains(string(key), "pod-container-") {
-			glog.V(1).Infof(string(value))
-			continue
-		}
-		glog.V(1).Infof("Get the pod item, pod is %s!", key)
-		err := daemon.db.Delete(key, nil)
-		if err != nil {
-			return err
-		}
-		podList[string(key)[4:]] = string(value)
-	}
-	iter.Release()
-	err := iter.Error()
-	if err != nil {
-		return err
-	}
-
-	daemon.PodList.Lock()
-	glog.V(2).Infof("lock PodList")
-	defer glog.V(2).Infof("unlock PodList")
-	defer daemon.PodList.Unlock()
-
-	for k, v := range podList {
-		_, err = daemon.createPodInternal(k, v, false, true)
-		if err != nil {
-			glog.Warningf("Got a unexpected error, %s", err.Error())
-			continue
-		}
-		vmId, err := daemon.DbGetVmByPod(k)
-		if err != nil {
-			glog.V(1).Info(err.Error(), " for ", k)
-			continue
-		}
-		p, _ := daemon.PodList.Get(k)
-		if err := p.AssociateVm(daemon, string(vmId)); err != nil {
-			glog.V(1).Info("Some problem during associate vm %s to pod %s, %v", string(vmId), k, err)
-			// continue to next
-		}
-	}
-
-	return nil
+	started     time.Time
+	// metricsEnabled is accessed atomically, it may be changed on reload
+	metricsEnabled int32
+	// configLock protects Host and DefaultLog, which may be changed on
+	// reload
+	configLock sync.RWMutex
 }
 
 func NewDaemon(cfg *goconfig.ConfigFile) (*Daemon, error) {
@@ -136,6 +104,9 @@ func NewDaemonFromDirectory(cfg *goconfig.ConfigFile) (*Daemon, error) {
 		return nil, err
 	}
 
+	// the VM templates left by the last run are not used any more
+	os.RemoveAll(templateRoot())
+
 	var realRoot = path.Join(utils.HYPER_ROOT, "lib")
 	// Create the root directory if it doesn't exists
 	if err := os.MkdirAll(realRoot, 0755); err != nil && !os.IsExist(err) {
@@ -154,23 +125,27 @@ func NewDaemonFromDirectory(cfg *goconfig.ConfigFile) (*Daemon, error) {
 	daemon := &Daemon{
 		ID:          fmt.Sprintf("%d", os.Getpid()),
 		db:          db,
+		events:      events.New(),
 		Kernel:      kernel,
 		Initrd:      initrd,
 		Bios:        bios,
 		Cbfs:        cbfs,
 		VboxImage:   vboxImage,
 		PodList:     NewPodList(),
-		VmList:      make(map[string]*hypervisor.Vm),
+		VmList:      NewVmList(),
 		Host:        host,
 		BridgeIP:    bridgeip,
 		BridgeIface: biface,
+		started:     time.Now(),
 	}
 	daemon.vmCache.daemon = daemon
+	daemon.EnableMetrics(cfg.MustBool(goconfig.DEFAULT_SECTION, "EnableMetrics", false))
 
 	daemon.Daemon, err = docker.NewDaemon(dockerCfg, registryCfg)
 	if err != nil {
 		return nil, err
 	}
+	daemon.engine = daemon.Daemon
 
 	// Get the docker daemon info
 	sysinfo, err := daemon.Daemon.SystemInfo()
@@ -190,6 +165,9 @@ func NewDaemonFromDirectory(cfg *goconfig.ConfigFile) (*Daemon, error) {
 var (
 	dockerCfg   = &docker.Config{}
 	registryCfg = &registry.Service{}
+	// registryLock protects the config of registryCfg, which may be
+	// replaced on reload while the registries are accessed
+	registryLock sync.RWMutex
 )
 
 func presentInHelp(usage string) string { return usage }
@@ -239,15 +217,64 @@ func InitDockerCfg(mirrors []string, insecureRegistries []string, graphdriver, r
 	registryCfg = registry.NewService(registryOpts)
 }
 
+// ReloadRegistry replaces the registry mirrors and the insecure registries,
+// it waits for the pulls and the pushes in progress.
+func ReloadRegistry(mirrors []string, insecureRegistries []string) error {
+	registryOpts := &registry.Options{
+		Mirrors:            opts.NewListOpts(registry.ValidateMirror),
+		InsecureRegistries: opts.NewListOpts(registry.ValidateIndexName),
+	}
+
+	for _, m := range mirrors {
+		if err := registryOpts.Mirrors.Set(m); err != nil {
+			return err
+		}
+	}
+
+	for _, ir := range insecureRegistries {
+		if err := registryOpts.InsecureRegistries.Set(ir); err != nil {
+			return err
+		}
+	}
+
+	config := registry.NewServiceConfig(registryOpts)
+	registryLock.Lock()
+	registryCfg.Config = config
+	registryLock.Unlock()
+	return nil
+}
+
 func (daemon *Daemon) DefaultLogCfg(driver string, cfg map[string]string) {
 	if driver == "" {
 		driver = jsonfilelog.Name
 	}
 
+	daemon.configLock.Lock()
 	daemon.DefaultLog = &pod.PodLogConfig{
 		Type:   driver,
 		Config: cfg,
 	}
+	daemon.configLock.Unlock()
+}
+
+// GetDefaultLog returns the log config of the pods which don't specify one.
+func (daemon *Daemon) GetDefaultLog() *pod.PodLogConfig {
+	daemon.configLock.RLock()
+	defer daemon.configLock.RUnlock()
+	return daemon.DefaultLog
+}
+
+// SetHost sets the extra address the API listens on.
+func (daemon *Daemon) SetHost(host string) {
+	daemon.configLock.Lock()
+	daemon.Host = host
+	daemon.configLock.Unlock()
+}
+
+func (daemon *Daemon) GetHost() string {
+	daemon.configLock.RLock()
+	defer daemon.configLock.RUnlock()
+	return daemon.Host
 }
 
 func (daemon *Daemon) GetPodNum() int64 {
@@ -293,32 +320,46 @@ func (daemon *Daemon) WritePodToDB(podName string, podData []byte) error {
 	return nil
 }
 
-// Lock protected
+// GetPod returns the pod with its lock held, the caller must unlock it. The
+// pod is created if podArgs is given, it is locked before being added to the
+// PodList, so that nobody else could operate on it before the caller.
 func (daemon *Daemon) GetPod(podId, podArgs string, autoremove bool) (*Pod, error) {
-	var (
-		pod *Pod
-		ok  bool
-	)
-
 	if podArgs == "" {
-		if pod, ok = daemon.PodList.Get(podId); !ok {
+		pod, ok := daemon.PodList.Get(podId)
+		if !ok {
 			return nil, fmt.Errorf("Can not find the POD instance of %s", podId)
 		}
+		if err := daemon.lockPod(pod); err != nil {
+			return nil, err
+		}
 		return pod, nil
 	}
 
-	pod, err := daemon.createPodInternal(podId, podArgs, autoremove, true)
+	pod, err := daemon.newPod(podId, podArgs, autoremove)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = daemon.AddPod(pod, podArgs); err != nil {
+	pod.opLock.Lock()
+	if err = daemon.addNewPod(pod, podArgs); err != nil {
+		pod.opLock.Unlock()
 		return nil, err
 	}
 
 	return pod, nil
 }
 
+// lockPod acquires the lock of the pod, it fails if the pod has been removed
+// while waiting for the lock.
+func (daemon *Daemon) lockPod(p *Pod) error {
+	p.opLock.Lock()
+	if _, ok := daemon.PodList.Get(p.id); !ok {
+		p.opLock.Unlock()
+		return fmt.Errorf("The pod(%s) has been removed", p.id)
+	}
+	return nil
+}
+
 func (daemon *Daemon) GetPodByName(podName string) ([]byte, error) {
 	key := fmt.Sprintf("pod-%s", podName)
 	data, err := daemon.db.Get([]byte(key), nil)
@@ -475,10 +516,6 @@ func (daemon *Daemon) DeleteVmByPod(podId string) error {
 }
 
 func (daemon *Daemon) GetVmByPodId(podId string) (string, error) {
-	daemon.PodList.RLock()
-	glog.V(2).Infof("lock read of PodList")
-	defer glog.V(2).Infof("unlock read of PodList")
-	defer daemon.PodList.RUnlock()
 	pod, ok := daemon.PodList.Get(podId)
 	if !ok {
 		return "", fmt.Errorf("Not found Pod %s", podId)
@@ -487,11 +524,6 @@ func (daemon *Daemon) GetVmByPodId(podId string) (string, error) {
 }
 
 func (daemon *Daemon) GetPodByContainer(containerId string) (string, error) {
-	daemon.PodList.RLock()
-	glog.V(2).Infof("lock read of PodList")
-	defer glog.V(2).Infof("unlock read of PodList")
-	defer daemon.PodList.RUnlock()
-
 	if pod, ok := daemon.PodList.GetByContainerId(containerId); ok {
 		return pod.id, nil
 	} else {
@@ -499,19 +531,6 @@ func (daemon *Daemon) GetPodByContainer(containerId string) (string, error) {
 	}
 }
 
-func (daemon *Daemon) GetPodByContainerIdOrName(name string) (pod *Pod, idx int, err error) {
-	daemon.PodList.RLock()
-	glog.V(2).Infof("lock read of PodList")
-	defer glog.V(2).Infof("unlock read of PodList")
-	defer daemon.PodList.RUnlock()
-
-	if pod, idx, ok := daemon.PodList.GetByContainerIdOrName(name); ok {
-		return pod, idx, nil
-	} else {
-		return nil, -1, fmt.Errorf("cannot found container %s", name)
-	}
-}
-
 func (daemon *Daemon) AddPod(pod *Pod, podArgs string) (err error) {
 	// store the UserPod into the db
 	if err = daemon.WritePodToDB(pod.id, []byte(podArgs)); err != nil {
@@ -544,11 +563,11 @@ func (daemon *Daemon) RemovePod(podId string) {
 }
 
 func (daemon *Daemon) AddVm(vm *hypervisor.Vm) {
-	daemon.VmList[vm.Id] = vm
+	daemon.VmList.Put(vm)
 }
 
 func (daemon *Daemon) RemoveVm(vmId string) {
-	delete(daemon.VmList, vmId)
+	daemon.VmList.Delete(vmId)
 }
 
 func (daemon *Daemon) UpdateVmData(vmId string, data []byte) error {
@@ -593,17 +612,15 @@ func (daemon *Daemon) DeleteVmData(vmId string) error {
 
 func (daemon *Daemon) DestroyAllVm() error {
 	glog.V(0).Info("The daemon will stop all pod")
-	daemon.PodList.Lock()
-	glog.V(2).Infof("lock PodList")
-
 	daemon.PodList.Foreach(func(p *Pod) error {
+		p.opLock.Lock()
+		defer p.opLock.Unlock()
+
 		if _, _, err := daemon.StopPodWithLock(p.id, "yes"); err != nil {
 			glog.V(1).Infof("fail to stop %s: %v", p.id, err)
 		}
 		return nil
 	})
-	daemon.PodList.Unlock()
-	glog.V(2).Infof("unlock PodList")
 	iter := daemon.db.NewIterator(util.BytesPrefix([]byte("vm-")), nil)
 	for iter.Next() {
 		key := iter.Key()
@@ -614,7 +631,22 @@ func (daemon *Daemon) DestroyAllVm() error {
 	return err
 }
 
+// DestroyAndKeepVm detaches from the VMs and leaves them running, the pods
+// in them are reconnected by Restore when hyperd starts again.
 func (daemon *Daemon) DestroyAndKeepVm() error {
+	daemon.PodList.Foreach(func(p *Pod) error {
+		p.opLock.Lock()
+		defer p.opLock.Unlock()
+
+		// nothing should touch the VMs after they are released
+		p.cancelRestart()
+		p.stopProbes()
+		if p.vm != nil {
+			stopLogger(p.status)
+		}
+		return nil
+	})
+
 	for i := 0; i < 3; i++ {
 		code, err := daemon.ReleaseAllVms()
 		if err != nil && code == types.E_BUSY {
@@ -629,9 +661,11 @@ func (daemon *Daemon) DestroyAndKeepVm() error {
 func (daemon *Daemon) Shutdown() error {
 	glog.V(0).Info("The daemon will be shutdown")
 	glog.V(0).Info("Shutdown all VMs")
-	for vm := range daemon.VmList {
-		daemon.KillVm(vm)
-	}
+	daemon.VmList.Foreach(func(vm *hypervisor.Vm) error {
+		daemon.KillVm(vm.Id)
+		return nil
+	})
+	daemon.DestroyVmCache()
 	daemon.db.Close()
 	glog.Flush()
 	return nil
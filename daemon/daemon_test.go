@@ -0,0 +1,320 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	docker "github.com/docker/docker/daemon"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/version"
+	"github.com/docker/docker/reference"
+	"github.com/docker/docker/registry"
+	dockertypes "github.com/docker/engine-api/types"
+	"github.com/hyperhq/hyper/daemon/driverloader"
+	"github.com/hyperhq/hyper/daemon/events"
+	"github.com/hyperhq/hyper/daemon/fakedriver"
+	"github.com/hyperhq/hyper/utils"
+	"github.com/hyperhq/runv/hypervisor"
+	"github.com/hyperhq/runv/hypervisor/pod"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// fakeEngine keeps the containers of the pods in memory in place of the
+// docker daemon.
+type fakeEngine struct {
+	sync.Mutex
+	storage    string
+	containers map[string]*dockertypes.ContainerJSON
+	next       int
+}
+
+func (e *fakeEngine) ContainerCreate(params dockertypes.ContainerCreateConfig) (dockertypes.ContainerCreateResponse, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.next++
+	id := fmt.Sprintf("%064x", e.next)
+	name := params.Name
+	if name == "" {
+		name = "container-" + id[:12]
+	}
+	for _, c := range e.containers {
+		if c.Name == "/"+name {
+			return dockertypes.ContainerCreateResponse{}, fmt.Errorf("Conflict, the name %s is in use", name)
+		}
+	}
+
+	// the mount id is read from the layer db of docker
+	mountDir := path.Join(utils.HYPER_ROOT, "image", e.storage, "layerdb/mounts", id)
+	if err := os.MkdirAll(mountDir, 0755); err != nil {
+		return dockertypes.ContainerCreateResponse{}, err
+	}
+	if err := ioutil.WriteFile(path.Join(mountDir, "mount-id"), []byte("mount-"+id), 0644); err != nil {
+		return dockertypes.ContainerCreateResponse{}, err
+	}
+
+	config := *params.Config
+	cmd := append(config.Entrypoint.Slice(), config.Cmd.Slice()...)
+	if len(cmd) == 0 {
+		cmd = []string{"sh"}
+	}
+	e.containers[id] = &dockertypes.ContainerJSON{
+		ContainerJSONBase: &dockertypes.ContainerJSONBase{
+			ID:      id,
+			Created: time.Now().UTC().Format(time.RFC3339Nano),
+			Path:    cmd[0],
+			Args:    cmd[1:],
+			State:   &dockertypes.ContainerState{},
+			Image:   config.Image,
+			Name:    "/" + name,
+		},
+		Config: &config,
+	}
+	return dockertypes.ContainerCreateResponse{ID: id}, nil
+}
+
+func (e *fakeEngine) get(name string) (*dockertypes.ContainerJSON, error) {
+	for id, c := range e.containers {
+		if id == name || c.Name == "/"+strings.TrimPrefix(name, "/") {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("No such container: %s", name)
+}
+
+func (e *fakeEngine) ContainerInspect(name string, size bool, version version.Version) (interface{}, error) {
+	e.Lock()
+	defer e.Unlock()
+	return e.get(name)
+}
+
+func (e *fakeEngine) ContainerRm(name string, config *dockertypes.ContainerRmConfig) error {
+	e.Lock()
+	defer e.Unlock()
+
+	c, err := e.get(name)
+	if err != nil {
+		return err
+	}
+	delete(e.containers, c.ID)
+	return nil
+}
+
+// fakeStorage hands the containers an empty rootfs.
+type fakeStorage struct {
+	root string
+}
+
+func (s *fakeStorage) Type() string     { return "fake" }
+func (s *fakeStorage) RootPath() string { return s.root }
+func (s *fakeStorage) Init() error      { return nil }
+func (s *fakeStorage) CleanUp() error   { return nil }
+
+func (s *fakeStorage) PrepareContainer(id, sharedir string) (*hypervisor.ContainerInfo, error) {
+	return &hypervisor.ContainerInfo{
+		Id:      id,
+		MountId: id,
+		Rootfs:  "rootfs",
+		Image:   id,
+		Fstype:  "dir",
+	}, nil
+}
+
+func (s *fakeStorage) InjectFile(src io.Reader, containerId, target, rootDir string, perm, uid, gid int) error {
+	return nil
+}
+
+func (s *fakeStorage) CreateVolume(daemon *Daemon, podId, shortName string) (*hypervisor.VolumeInfo, error) {
+	dir := path.Join(s.root, "volumes", podId, shortName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &hypervisor.VolumeInfo{Name: shortName, Filepath: dir, Fstype: "dir"}, nil
+}
+
+func (s *fakeStorage) RemoveVolume(podId string, record []byte) error {
+	return nil
+}
+
+func (s *fakeStorage) Changes(daemon *Daemon, containerId string) ([]archive.Change, error) {
+	return nil, nil
+}
+
+// fakeDaemon is a daemon running its pods in the VMs of the fake driver.
+type fakeDaemon struct {
+	*Daemon
+	t      *testing.T
+	dir    string
+	driver *fakedriver.Driver
+	engine *fakeEngine
+}
+
+// newFakeDaemon returns a daemon running the pods in the fake VMs, the
+// containers are kept in memory. It needs root to mount the volumes of the
+// pods, and is skipped in the short mode.
+func newFakeDaemon(t *testing.T) (*fakeDaemon, func()) {
+	if testing.Short() {
+		t.Skip("skip the pods running in the fake VMs in short mode")
+	}
+	if os.Geteuid() != 0 {
+		t.Skip("the fake daemon needs root to mount the volumes")
+	}
+
+	dir, err := ioutil.TempDir("", "hyper-fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldRoot, oldResourcePath, oldBaseDir, oldDriver := utils.HYPER_ROOT, DefaultResourcePath, hypervisor.BaseDir, hypervisor.HDriver
+	restore := func() {
+		utils.HYPER_ROOT, DefaultResourcePath, hypervisor.BaseDir, hypervisor.HDriver = oldRoot, oldResourcePath, oldBaseDir, oldDriver
+		os.RemoveAll(dir)
+	}
+	utils.HYPER_ROOT = path.Join(dir, "root")
+	DefaultResourcePath = path.Join(dir, "pods")
+	hypervisor.BaseDir = path.Join(dir, "vms")
+
+	driver, err := driverloader.Probe("fake")
+	if err != nil {
+		restore()
+		t.Fatal(err)
+	}
+	hypervisor.HDriver = driver
+	if err := hypervisor.InitNetwork("", "", true); err != nil {
+		restore()
+		t.Fatal(err)
+	}
+
+	db, err := leveldb.OpenFile(path.Join(dir, "hyper.db"), nil)
+	if err != nil {
+		restore()
+		t.Fatal(err)
+	}
+
+	fd := &fakeDaemon{
+		t:      t,
+		dir:    dir,
+		driver: driver.(*fakedriver.Driver),
+		engine: &fakeEngine{storage: "fake", containers: make(map[string]*dockertypes.ContainerJSON)},
+	}
+	fd.Daemon = fd.newDaemon(db)
+
+	return fd, func() {
+		fd.DestroyAllVm()
+		db.Close()
+		restore()
+	}
+}
+
+func (fd *fakeDaemon) newDaemon(db *leveldb.DB) *Daemon {
+	d := &Daemon{
+		ID:         "fake",
+		db:         db,
+		engine:     fd.engine,
+		events:     events.New(),
+		PodList:    NewPodList(),
+		VmList:     NewVmList(),
+		Storage:    &fakeStorage{root: path.Join(fd.dir, "storage")},
+		Hypervisor: "fake",
+		DefaultLog: &pod.PodLogConfig{Type: "none"},
+		started:    time.Now(),
+	}
+	d.vmCache.daemon = d
+	return d
+}
+
+// restart simulates the restart of hyperd in the live restore mode, the VMs
+// are released and keep running, down is called when hyperd is down, then
+// the pods are restored from the db by a new daemon.
+func (fd *fakeDaemon) restart(down func()) {
+	if err := fd.DestroyAndKeepVm(); err != nil {
+		fd.t.Fatalf("failed to release the VMs: %v", err)
+	}
+	if down != nil {
+		down()
+	}
+	fd.Daemon = fd.newDaemon(fd.db)
+	if err := fd.Restore(); err != nil {
+		fd.t.Fatalf("failed to restore the pods: %v", err)
+	}
+}
+
+// createPod creates the pod of a busybox container running cmd.
+func (fd *fakeDaemon) createPod(name string, extra string) *Pod {
+	spec := fmt.Sprintf(`{"id": %q, "containers": [{"name": "%s-c", "image": "busybox", "command": ["sleep", "1000"]}],
+		"resource": {"vcpu": 1, "memory": 128}%s}`, name, name, extra)
+	p, err := fd.CreatePod("", spec, false)
+	if err != nil {
+		fd.t.Fatalf("failed to create pod %s: %v", name, err)
+	}
+	return p
+}
+
+func (fd *fakeDaemon) startPod(p *Pod) {
+	if _, _, err := fd.StartPod(nil, nil, p.id, "", ""); err != nil {
+		fd.t.Fatalf("failed to start pod %s: %v", p.id, err)
+	}
+}
+
+// status returns the status of the pod and the VM it runs in.
+func (fd *fakeDaemon) status(p *Pod) (uint, string) {
+	p.RLock()
+	defer p.RUnlock()
+	return p.status.Status, p.status.Vm
+}
+
+// waitFor polls the condition until it holds or the timeout expires.
+func (fd *fakeDaemon) waitFor(timeout time.Duration, what string, cond func() bool) {
+	for deadline := time.Now().Add(timeout); !cond(); {
+		if time.Now().After(deadline) {
+			fd.t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func (fd *fakeDaemon) waitStatus(p *Pod, status uint) {
+	fd.waitFor(10*time.Second, fmt.Sprintf("pod %s to be %s", p.id, podPhase(status)), func() bool {
+		s, _ := fd.status(p)
+		return s == status
+	})
+}
+
+func TestReloadRegistry(t *testing.T) {
+	old := registryCfg
+	defer func() { registryCfg = old }()
+	registryCfg = &registry.Service{}
+	d := &Daemon{Daemon: &docker.Daemon{RegistryService: registryCfg}}
+
+	// the config is read by the pulls while it is reloaded
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			ref, _ := reference.ParseNamed("busybox")
+			d.ResolveRepository(ref)
+		}
+	}()
+	if err := ReloadRegistry([]string{"https://mirror.example.com"}, []string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	mirrors := registryCfg.Config.Mirrors
+	if len(mirrors) != 1 || mirrors[0] != "https://mirror.example.com/" {
+		t.Fatalf("unexpected mirrors %v", mirrors)
+	}
+	if err := ReloadRegistry([]string{"not a mirror"}, nil); err == nil {
+		t.Fatal("expect the invalid mirror to be rejected")
+	}
+	if mirrors := registryCfg.Config.Mirrors; len(mirrors) != 1 {
+		t.Fatalf("expect the config to be kept, got the mirrors %v", mirrors)
+	}
+}
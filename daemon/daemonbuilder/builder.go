@@ -59,7 +59,7 @@ func (d Docker) Pull(name string) (builder.Image, error) {
 	pullRegistryAuth := &types.AuthConfig{}
 	if len(d.AuthConfigs) > 0 {
 		// The request came with a full auth config file, we prefer to use that
-		repoInfo, err := d.Daemon.RegistryService.ResolveRepository(ref)
+		repoInfo, err := d.Daemon.ResolveRepository(ref)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,38 @@
+// Package driverloader selects the hypervisor driver of hyperd. The drivers
+// registered in hyperd, e.g. the fake driver, are looked up first, the
+// others are probed by runv.
+package driverloader
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/hyperhq/runv/driverloader"
+	"github.com/hyperhq/runv/hypervisor"
+)
+
+var (
+	lock    sync.Mutex
+	drivers = make(map[string]func() hypervisor.HypervisorDriver)
+)
+
+// Register makes the driver available by its name, it is usually called in
+// the init function of the package of the driver.
+func Register(name string, init func() hypervisor.HypervisorDriver) {
+	lock.Lock()
+	defer lock.Unlock()
+	drivers[strings.ToLower(name)] = init
+}
+
+// Probe returns the driver registered by the name, or the driver of runv
+// if there is no such one.
+func Probe(driver string) (hypervisor.HypervisorDriver, error) {
+	lock.Lock()
+	init, ok := drivers[strings.ToLower(driver)]
+	lock.Unlock()
+
+	if ok {
+		return init(), nil
+	}
+	return driverloader.Probe(driver)
+}
@@ -0,0 +1,75 @@
+package daemon
+
+import (
+	"github.com/hyperhq/hyper/daemon/events"
+	"github.com/hyperhq/hyper/types"
+	"github.com/hyperhq/runv/hypervisor"
+)
+
+// LogPodEvent generates an event related to a pod.
+func (daemon *Daemon) LogPodEvent(p *Pod, action string) {
+	daemon.events.Log(types.Event{
+		Type:    types.EventTypePod,
+		Action:  action,
+		PodID:   p.id,
+		PodName: p.status.Name,
+		VmID:    p.status.Vm,
+		Labels:  copyLabels(p.spec.Labels),
+	})
+}
+
+// LogPodContainerEvents generates an event for each container of a pod,
+// the exit code of the container is carried with the event.
+func (daemon *Daemon) LogPodContainerEvents(p *Pod, action string) {
+	for _, c := range p.status.Containers {
+		daemon.LogPodContainerEvent(p, c, action)
+	}
+}
+
+// LogPodContainerEvent generates an event related to a container of a pod.
+func (daemon *Daemon) LogPodContainerEvent(p *Pod, c *hypervisor.Container, action string) {
+	daemon.events.Log(types.Event{
+		Type:        types.EventTypeContainer,
+		Action:      action,
+		PodID:       p.id,
+		PodName:     p.status.Name,
+		ContainerID: c.Id,
+		VmID:        p.status.Vm,
+		ExitCode:    c.ExitCode,
+		Labels:      copyLabels(p.spec.Labels),
+	})
+}
+
+// LogVmEvent generates an event related to a vm.
+func (daemon *Daemon) LogVmEvent(vmId, podId, action string) {
+	daemon.events.Log(types.Event{
+		Type:   types.EventTypeVm,
+		Action: action,
+		PodID:  podId,
+		VmID:   vmId,
+	})
+}
+
+// SubscribeToEvents returns the buffered events between since and until and
+// a channel for the upcoming events which match the filter.
+func (daemon *Daemon) SubscribeToEvents(since, sinceNano, until, untilNano int64, ef *events.Filter) ([]types.Event, chan interface{}) {
+	return daemon.events.SubscribeTopic(since, sinceNano, until, untilNano, ef)
+}
+
+// UnsubscribeFromEvents stops the event subscription for a client by closing the
+// channel where the daemon sends events to.
+func (daemon *Daemon) UnsubscribeFromEvents(listener chan interface{}) {
+	daemon.events.Evict(listener)
+}
+
+// copyLabels guarantees that labels are not mutated by event triggers.
+func copyLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+	attributes := make(map[string]string, len(labels))
+	for k, v := range labels {
+		attributes[k] = v
+	}
+	return attributes
+}
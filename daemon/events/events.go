@@ -0,0 +1,99 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/docker/docker/pkg/pubsub"
+	"github.com/hyperhq/hyper/types"
+)
+
+const (
+	eventsLimit = 256
+	bufferSize  = 1024
+)
+
+// Events is pubsub channel for the lifecycle events of pods, containers
+// and vms generated by the daemon.
+type Events struct {
+	mu     sync.Mutex
+	events []types.Event
+	pub    *pubsub.Publisher
+}
+
+// New returns new *Events instance
+func New() *Events {
+	return &Events{
+		events: make([]types.Event, 0, eventsLimit),
+		pub:    pubsub.NewPublisher(100*time.Millisecond, bufferSize),
+	}
+}
+
+// SubscribeTopic adds new listener to events, returns the stored events
+// which happened after `since` and not after `until` and match the filter,
+// and a channel in which you can expect new events (in form of
+// interface{}, so you need type assertion). `until` is ignored if it is -1.
+func (e *Events) SubscribeTopic(since, sinceNano, until, untilNano int64, ef *Filter) ([]types.Event, chan interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var buffered []types.Event
+	topic := func(m interface{}) bool {
+		return ef.Include(m.(types.Event))
+	}
+
+	if since != -1 {
+		for i := len(e.events) - 1; i >= 0; i-- {
+			ev := e.events[i]
+			if ev.Time < since || ((ev.Time == since) && (ev.TimeNano < sinceNano)) {
+				break
+			}
+			if until != -1 && ev.TimeNano > time.Unix(until, untilNano).UnixNano() {
+				continue
+			}
+			if ef.Len() == 0 || topic(ev) {
+				buffered = append([]types.Event{ev}, buffered...)
+			}
+		}
+	}
+
+	var ch chan interface{}
+	if ef.Len() > 0 {
+		ch = e.pub.SubscribeTopic(topic)
+	} else {
+		// Subscribe to all events if there are no filters
+		ch = e.pub.Subscribe()
+	}
+
+	return buffered, ch
+}
+
+// Evict evicts listener from pubsub
+func (e *Events) Evict(l chan interface{}) {
+	e.pub.Evict(l)
+}
+
+// Log stamps the event with the current time, records it and broadcasts
+// it to listeners. Each listener has 100 millisecond for receiving event
+// or it will be skipped.
+func (e *Events) Log(ev types.Event) {
+	now := time.Now().UTC()
+	ev.Time = now.Unix()
+	ev.TimeNano = now.UnixNano()
+
+	e.mu.Lock()
+	if len(e.events) == cap(e.events) {
+		// discard oldest event
+		copy(e.events, e.events[1:])
+		e.events[len(e.events)-1] = ev
+	} else {
+		e.events = append(e.events, ev)
+	}
+	e.mu.Unlock()
+	e.pub.Publish(ev)
+}
+
+// SubscribersCount returns number of event listeners
+func (e *Events) SubscribersCount() int {
+	return e.pub.Len()
+}
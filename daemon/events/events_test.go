@@ -0,0 +1,104 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/engine-api/types/filters"
+	"github.com/hyperhq/hyper/types"
+)
+
+func TestEventsLog(t *testing.T) {
+	e := New()
+	_, l1 := e.SubscribeTopic(-1, 0, -1, 0, &Filter{filter: filters.NewArgs()})
+	_, l2 := e.SubscribeTopic(-1, 0, -1, 0, &Filter{filter: filters.NewArgs()})
+	defer e.Evict(l1)
+	defer e.Evict(l2)
+	if count := e.SubscribersCount(); count != 2 {
+		t.Fatalf("Must be 2 subscribers, got %d", count)
+	}
+	e.Log(types.Event{Type: types.EventTypePod, Action: "start", PodID: "pod-test"})
+	for _, l := range []chan interface{}{l1, l2} {
+		select {
+		case msg := <-l:
+			ev, ok := msg.(types.Event)
+			if !ok {
+				t.Fatalf("Unexpected type %T", msg)
+			}
+			if ev.Action != "start" || ev.PodID != "pod-test" {
+				t.Fatalf("Unexpected event %v", ev)
+			}
+			if ev.Time == 0 {
+				t.Fatal("Event time is not set")
+			}
+		case <-time.After(1 * time.Second):
+			t.Fatal("Timeout waiting for broadcasted message")
+		}
+	}
+}
+
+func TestEventsLimit(t *testing.T) {
+	e := New()
+	for i := 0; i < eventsLimit+16; i++ {
+		e.Log(types.Event{Type: types.EventTypeVm, Action: "shutdown"})
+	}
+	buffered, l := e.SubscribeTopic(0, 0, -1, 0, &Filter{filter: filters.NewArgs()})
+	defer e.Evict(l)
+	if len(buffered) != eventsLimit {
+		t.Fatalf("Must be %d events, got %d", eventsLimit, len(buffered))
+	}
+}
+
+func TestEventsUntil(t *testing.T) {
+	e := New()
+	e.Log(types.Event{Type: types.EventTypePod, Action: "create", PodID: "pod-old"})
+	old := e.events[0]
+	// the events are stamped with the current time
+	for {
+		e.Log(types.Event{Type: types.EventTypePod, Action: "create", PodID: "pod-new"})
+		if e.events[len(e.events)-1].TimeNano > old.TimeNano {
+			break
+		}
+	}
+
+	until := time.Unix(0, old.TimeNano)
+	buffered, l := e.SubscribeTopic(0, 0, until.Unix(), int64(until.Nanosecond()), &Filter{filter: filters.NewArgs()})
+	defer e.Evict(l)
+	if len(buffered) == 0 || buffered[0].PodID != "pod-old" {
+		t.Fatalf("Expected the event before until, got %v", buffered)
+	}
+	for _, ev := range buffered {
+		if ev.TimeNano > old.TimeNano {
+			t.Fatalf("Unexpected event %v after until", ev)
+		}
+	}
+	if len(buffered) == len(e.events) {
+		t.Fatal("Expected the events after until to be skipped")
+	}
+}
+
+func TestEventsFilter(t *testing.T) {
+	args := filters.NewArgs()
+	args.Add("type", types.EventTypeContainer)
+	args.Add("label", "app=web")
+	ef, err := NewFilter(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := New()
+	e.Log(types.Event{Type: types.EventTypePod, Action: "create", Labels: map[string]string{"app": "web"}})
+	e.Log(types.Event{Type: types.EventTypeContainer, Action: "finish", Labels: map[string]string{"app": "db"}})
+	e.Log(types.Event{Type: types.EventTypeContainer, Action: "finish", ContainerID: "abc", Labels: map[string]string{"app": "web"}})
+
+	buffered, l := e.SubscribeTopic(0, 0, -1, 0, ef)
+	defer e.Evict(l)
+	if len(buffered) != 1 || buffered[0].ContainerID != "abc" {
+		t.Fatalf("Unexpected filtered events %v", buffered)
+	}
+
+	args.Add("image", "busybox")
+	if _, err := NewFilter(args); err == nil {
+		t.Fatal("Expected error for unknown filter key")
+	}
+}
@@ -0,0 +1,57 @@
+package events
+
+import (
+	"github.com/docker/engine-api/types/filters"
+	"github.com/hyperhq/hyper/types"
+)
+
+// acceptedFilters are the filter keys /events understands
+var acceptedFilters = map[string]bool{
+	"type":      true,
+	"event":     true,
+	"pod":       true,
+	"container": true,
+	"vm":        true,
+	"label":     true,
+}
+
+// Filter can filter out hyper events from a stream
+type Filter struct {
+	filter filters.Args
+}
+
+// NewFilter creates a new Filter, it fails if the filter contains an
+// unknown key.
+func NewFilter(filter filters.Args) (*Filter, error) {
+	if err := filter.Validate(acceptedFilters); err != nil {
+		return nil, err
+	}
+	return &Filter{filter: filter}, nil
+}
+
+// Len returns the number of filter keys
+func (ef *Filter) Len() int {
+	return ef.filter.Len()
+}
+
+// Include returns true when the event ev is included by the filters
+func (ef *Filter) Include(ev types.Event) bool {
+	return ef.filter.ExactMatch("event", ev.Action) &&
+		ef.filter.ExactMatch("type", ev.Type) &&
+		ef.matchPod(ev) &&
+		ef.filter.FuzzyMatch("container", ev.ContainerID) &&
+		ef.filter.FuzzyMatch("vm", ev.VmID) &&
+		ef.matchLabels(ev.Labels)
+}
+
+func (ef *Filter) matchPod(ev types.Event) bool {
+	return ef.filter.FuzzyMatch("pod", ev.PodID) ||
+		ef.filter.ExactMatch("pod", ev.PodName)
+}
+
+func (ef *Filter) matchLabels(labels map[string]string) bool {
+	if !ef.filter.Include("label") {
+		return true
+	}
+	return ef.filter.MatchKVList("label", labels)
+}
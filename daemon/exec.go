@@ -1,18 +1,21 @@
 package daemon
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/hyperhq/runv/hypervisor"
+	"github.com/hyperhq/runv/hypervisor/pod"
 	"github.com/hyperhq/runv/hypervisor/types"
 )
 
 func (daemon *Daemon) ExitCode(container, tag string) (int, error) {
 	glog.V(1).Infof("Get container id is %s", container)
 
-	pod, _, err := daemon.GetPodByContainerIdOrName(container)
+	pod, _, err := daemon.ResolveContainerOrPod(container)
 	if err != nil {
 		return -1, err
 	}
@@ -46,16 +49,20 @@ func (daemon *Daemon) Exec(stdin io.ReadCloser, stdout io.WriteCloser, key, id,
 
 	// We need find the vm id which running POD, and stop it
 	if key == "pod" {
-		vmId = id
+		vm, err := daemon.ResolveVm(id)
+		if err != nil {
+			return err
+		}
+		vmId = vm.Id
 		container = ""
 	} else {
 		glog.V(1).Infof("Get container id is %s", id)
-		pod, _, err := daemon.GetPodByContainerIdOrName(id)
+		pod, idx, err := daemon.ResolveContainerOrPod(id)
 		if err != nil {
 			return err
 		}
 
-		container = id
+		container = pod.status.Containers[idx].Id
 
 		pod.Lock()
 		pod.ttyList[tag] = tty
@@ -75,7 +82,7 @@ func (daemon *Daemon) Exec(stdin io.ReadCloser, stdout io.WriteCloser, key, id,
 		}
 	}
 
-	vm, ok := daemon.VmList[vmId]
+	vm, ok := daemon.VmList.Get(vmId)
 	if !ok {
 		err = fmt.Errorf("Can not find VM whose Id is %s!", vmId)
 		return err
@@ -91,3 +98,36 @@ func (daemon *Daemon) Exec(stdin io.ReadCloser, stdout io.WriteCloser, key, id,
 
 	return nil
 }
+
+// execInContainer runs the command in the container and waits for at most
+// timeout, a non-zero exit code is reported as an error.
+func execInContainer(vm *hypervisor.Vm, container string, command []string, timeout time.Duration) error {
+	execcmd, err := json.Marshal(command)
+	if err != nil {
+		return err
+	}
+
+	tty := &hypervisor.TtyIO{
+		Callback:  make(chan *types.VmResponse, 1),
+		ClientTag: pod.RandStr(8, "alphanum"),
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- vm.Exec(tty, container, string(execcmd))
+	}()
+
+	select {
+	case err = <-result:
+		if err != nil {
+			return err
+		}
+	case <-time.After(timeout):
+		return fmt.Errorf("exec %v timeout after %v", command, timeout)
+	}
+
+	if tty.ExitCode != 0 {
+		return fmt.Errorf("exec %v failed with exit code %d", command, tty.ExitCode)
+	}
+	return nil
+}
@@ -0,0 +1,469 @@
+// Package fakedriver is a hypervisor driver which simulates the VMs and the
+// hyperstart in them inside the process, so that the daemon could be run and
+// tested on the hosts without any hypervisor. It is selected with
+// "Hypervisor=fake" in the config file.
+package fakedriver
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/hyperhq/hyper/daemon/driverloader"
+	"github.com/hyperhq/runv/hypervisor"
+	"github.com/hyperhq/runv/hypervisor/network"
+	"github.com/hyperhq/runv/hypervisor/pod"
+	"github.com/hyperhq/runv/hypervisor/types"
+)
+
+// ExecResult is the output and the exit code of an exec command.
+type ExecResult struct {
+	Output   string
+	ExitCode int
+}
+
+// Driver is the fake hypervisor driver, it keeps all the simulated VMs and
+// the scripted results of the commands run in them.
+type Driver struct {
+	sync.Mutex
+	vms    map[string]*vmState
+	execs  map[string]ExecResult
+	stats  func(vmId string) *types.PodStats
+	nextIP int
+}
+
+func init() {
+	driverloader.Register("fake", func() hypervisor.HypervisorDriver {
+		return InitDriver()
+	})
+}
+
+func InitDriver() *Driver {
+	return &Driver{
+		vms:   make(map[string]*vmState),
+		execs: make(map[string]ExecResult),
+	}
+}
+
+// SetExecResult scripts the result of the exec command, the commands not
+// scripted exit with 0 and print nothing.
+func (d *Driver) SetExecResult(command string, result ExecResult) {
+	d.Lock()
+	defer d.Unlock()
+	d.execs[command] = result
+}
+
+func (d *Driver) execResult(command []string) ExecResult {
+	d.Lock()
+	defer d.Unlock()
+	return d.execs[strings.Join(command, " ")]
+}
+
+// SetStats sets the function which returns the stats of the VMs.
+func (d *Driver) SetStats(fn func(vmId string) *types.PodStats) {
+	d.Lock()
+	defer d.Unlock()
+	d.stats = fn
+}
+
+// FinishContainer makes the container in the VM exit with the code, the pod
+// finishes once all its containers exit.
+func (d *Driver) FinishContainer(vmId, container string, exitCode int) error {
+	vm, ok := d.getVm(vmId)
+	if !ok {
+		return fmt.Errorf("fake VM %s doesn't exist", vmId)
+	}
+	if !vm.running(container) {
+		return fmt.Errorf("container %s is not running in fake VM %s", container, vmId)
+	}
+	vm.exitContainer(container, exitCode)
+	return nil
+}
+
+// ReadFile returns the file written to the container in the VM.
+func (d *Driver) ReadFile(vmId, container, file string) ([]byte, error) {
+	vm, ok := d.getVm(vmId)
+	if !ok {
+		return nil, fmt.Errorf("fake VM %s doesn't exist", vmId)
+	}
+	return vm.readFile(container, file)
+}
+
+// VmIds returns the IDs of the running VMs.
+func (d *Driver) VmIds() []string {
+	d.Lock()
+	defer d.Unlock()
+
+	ids := []string{}
+	for id := range d.vms {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (d *Driver) getVm(id string) (*vmState, bool) {
+	d.Lock()
+	defer d.Unlock()
+	vm, ok := d.vms[id]
+	return vm, ok
+}
+
+func (d *Driver) removeVm(id string) {
+	d.Lock()
+	defer d.Unlock()
+	delete(d.vms, id)
+}
+
+func (d *Driver) InitContext(homeDir string) hypervisor.DriverContext {
+	return &fakeContext{driver: d}
+}
+
+func (d *Driver) LoadContext(persisted map[string]interface{}) (hypervisor.DriverContext, error) {
+	if t, ok := persisted["hypervisor"]; !ok || t != "fake" {
+		return nil, fmt.Errorf("wrong driver type in persisted info")
+	}
+	id, ok := persisted["id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("no VM ID in persisted info")
+	}
+	vm, ok := d.getVm(id)
+	if !ok {
+		return nil, fmt.Errorf("fake VM %s doesn't exist", id)
+	}
+	return &fakeContext{driver: d, vm: vm}, nil
+}
+
+func (d *Driver) BuildinNetwork() bool {
+	return true
+}
+
+func (d *Driver) InitNetwork(bIface, bIP string, disableIptables bool) error {
+	return nil
+}
+
+func (d *Driver) SupportLazyMode() bool {
+	return false
+}
+
+// vmState is a simulated VM.
+type vmState struct {
+	id     string
+	driver *Driver
+	h      *hyperstart
+
+	sync.Mutex
+	hub        chan hypervisor.VmEvent
+	listeners  []net.Listener
+	containers []string
+	exitCodes  map[string]int
+	files      map[string][]byte
+	finished   bool
+	closed     bool
+	disks      int
+}
+
+func (vm *vmState) post(event hypervisor.VmEvent) {
+	vm.Lock()
+	hub := vm.hub
+	vm.Unlock()
+	if hub != nil {
+		hub <- event
+	}
+}
+
+func (vm *vmState) listen(sock string, serve func(conn net.Conn, first bool)) error {
+	os.Remove(sock)
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		return err
+	}
+
+	vm.Lock()
+	vm.listeners = append(vm.listeners, l)
+	vm.Unlock()
+
+	go func() {
+		for first := true; ; first = false {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn, first)
+		}
+	}()
+	return nil
+}
+
+func (vm *vmState) startPod(containers []string) {
+	vm.Lock()
+	defer vm.Unlock()
+
+	vm.containers = containers
+	vm.exitCodes = make(map[string]int)
+	vm.finished = false
+}
+
+func (vm *vmState) addContainer(id string) {
+	vm.Lock()
+	defer vm.Unlock()
+
+	vm.containers = append(vm.containers, id)
+	vm.finished = false
+}
+
+func (vm *vmState) running(id string) bool {
+	vm.Lock()
+	defer vm.Unlock()
+
+	for _, c := range vm.containers {
+		if c == id {
+			_, exited := vm.exitCodes[id]
+			return !exited && !vm.finished
+		}
+	}
+	return false
+}
+
+func (vm *vmState) exitContainer(id string, code int) {
+	vm.Lock()
+	if _, exited := vm.exitCodes[id]; exited || vm.finished {
+		vm.Unlock()
+		return
+	}
+	vm.exitCodes[id] = code
+
+	codes := make([]int, 0, len(vm.containers))
+	for _, c := range vm.containers {
+		code, exited := vm.exitCodes[c]
+		if !exited {
+			vm.Unlock()
+			return
+		}
+		codes = append(codes, code)
+	}
+	vm.finished = true
+	vm.Unlock()
+
+	glog.V(1).Infof("pod in fake VM %s finished with %v", vm.id, codes)
+	if err := vm.h.finishPod(codes); err != nil {
+		glog.Errorf("fake VM %s failed to send pod finish: %s", vm.id, err.Error())
+	}
+}
+
+func (vm *vmState) writeFile(container, file string, data []byte) {
+	vm.Lock()
+	defer vm.Unlock()
+	vm.files[container+":"+file] = data
+}
+
+func (vm *vmState) readFile(container, file string) ([]byte, error) {
+	vm.Lock()
+	defer vm.Unlock()
+
+	data, ok := vm.files[container+":"+file]
+	if !ok {
+		return nil, fmt.Errorf("file %s doesn't exist in container %s", file, container)
+	}
+	return data, nil
+}
+
+// close stops the VM, returns false if it is already stopped.
+func (vm *vmState) close() bool {
+	vm.Lock()
+	if vm.closed {
+		vm.Unlock()
+		return false
+	}
+	vm.closed = true
+	for _, l := range vm.listeners {
+		l.Close()
+	}
+	vm.Unlock()
+
+	vm.h.ctlLock.Lock()
+	if vm.h.ctl != nil {
+		vm.h.ctl.Close()
+	}
+	vm.h.ctlLock.Unlock()
+	vm.h.ttyLock.Lock()
+	if vm.h.tty != nil {
+		vm.h.tty.Close()
+	}
+	vm.h.ttyLock.Unlock()
+
+	vm.driver.removeVm(vm.id)
+	return true
+}
+
+func (vm *vmState) shutdown() {
+	if vm.close() {
+		go vm.post(&hypervisor.VmExit{})
+	}
+}
+
+func (vm *vmState) kill() {
+	vm.close()
+	go vm.post(&hypervisor.VmKilledEvent{Success: true})
+}
+
+type fakeContext struct {
+	driver *Driver
+	vm     *vmState
+}
+
+func (fc *fakeContext) Launch(ctx *hypervisor.VmContext) {
+	vm := &vmState{
+		id:     ctx.Id,
+		driver: fc.driver,
+		hub:    ctx.Hub,
+		files:  make(map[string][]byte),
+	}
+	vm.h = &hyperstart{vm: vm}
+	fc.vm = vm
+
+	fc.driver.Lock()
+	fc.driver.vms[vm.id] = vm
+	fc.driver.Unlock()
+
+	err := vm.listen(ctx.HyperSockName, vm.h.serveCtl)
+	if err == nil {
+		err = vm.listen(ctx.TtySockName, func(conn net.Conn, first bool) {
+			vm.h.serveTty(conn)
+		})
+	}
+	if err == nil {
+		err = vm.listen(ctx.ConsoleSockName, func(conn net.Conn, first bool) {
+			fmt.Fprintf(conn, "fake VM %s is running\r\n", vm.id)
+		})
+	}
+	if err != nil {
+		vm.close()
+		ctx.Hub <- &hypervisor.VmStartFailEvent{Message: err.Error()}
+		return
+	}
+	glog.V(1).Infof("fake VM %s launched", vm.id)
+}
+
+func (fc *fakeContext) Associate(ctx *hypervisor.VmContext) {
+	fc.vm.Lock()
+	fc.vm.hub = ctx.Hub
+	fc.vm.Unlock()
+}
+
+func (fc *fakeContext) Dump() (map[string]interface{}, error) {
+	if fc.vm == nil {
+		return nil, fmt.Errorf("fake VM is not launched")
+	}
+	return map[string]interface{}{
+		"hypervisor": "fake",
+		"id":         fc.vm.id,
+	}, nil
+}
+
+func (fc *fakeContext) AddDisk(ctx *hypervisor.VmContext, sourceType string, blockInfo *hypervisor.BlockDescriptor) {
+	fc.vm.Lock()
+	id := fc.vm.disks
+	fc.vm.disks++
+	fc.vm.Unlock()
+
+	ctx.Hub <- &hypervisor.BlockdevInsertedEvent{
+		Name:       blockInfo.Name,
+		SourceType: sourceType,
+		DeviceName: fmt.Sprintf("sd%c", 'a'+id%26),
+		ScsiId:     id,
+	}
+}
+
+func (fc *fakeContext) RemoveDisk(ctx *hypervisor.VmContext, blockInfo *hypervisor.BlockDescriptor, callback hypervisor.VmEvent) {
+	ctx.Hub <- callback
+}
+
+func (fc *fakeContext) AddNic(ctx *hypervisor.VmContext, host *hypervisor.HostNicInfo, guest *hypervisor.GuestNicInfo) {
+	ctx.Hub <- &hypervisor.NetDevInsertedEvent{
+		Index:      guest.Index,
+		DeviceName: guest.Device,
+		Address:    guest.Busaddr,
+	}
+}
+
+func (fc *fakeContext) RemoveNic(ctx *hypervisor.VmContext, n *hypervisor.InterfaceCreated, callback hypervisor.VmEvent) {
+	ctx.Hub <- callback
+}
+
+func (fc *fakeContext) SetCpus(ctx *hypervisor.VmContext, cpus int, result chan<- error) {
+	result <- nil
+}
+
+func (fc *fakeContext) AddMem(ctx *hypervisor.VmContext, slot, size int, result chan<- error) {
+	result <- nil
+}
+
+func (fc *fakeContext) Save(ctx *hypervisor.VmContext, path string, result chan<- error) {
+	result <- nil
+}
+
+func (fc *fakeContext) Shutdown(ctx *hypervisor.VmContext) {
+	fc.vm.shutdown()
+}
+
+func (fc *fakeContext) Kill(ctx *hypervisor.VmContext) {
+	fc.vm.kill()
+}
+
+func (fc *fakeContext) Pause(ctx *hypervisor.VmContext, pause bool, result chan<- error) {
+	result <- nil
+}
+
+func (fc *fakeContext) ConfigureNetwork(vmId, requestedIP string, maps []pod.UserContainerPort, config pod.UserInterface) (*network.Settings, error) {
+	return &network.Settings{
+		Mac:         config.Mac,
+		IPAddress:   config.Ip,
+		IPPrefixLen: 24,
+		Gateway:     config.Gw,
+		Bridge:      config.Bridge,
+		Device:      config.Ifname,
+	}, nil
+}
+
+func (fc *fakeContext) AllocateNetwork(vmId, requestedIP string, maps []pod.UserContainerPort) (*network.Settings, error) {
+	ip := requestedIP
+	if ip == "" {
+		fc.driver.Lock()
+		fc.driver.nextIP++
+		ip = fmt.Sprintf("192.168.123.%d", fc.driver.nextIP%253+2)
+		fc.driver.Unlock()
+	}
+
+	return &network.Settings{
+		Mac:         "52:54:00:12:34:56",
+		IPAddress:   ip,
+		IPPrefixLen: 24,
+		Gateway:     "192.168.123.1",
+		Bridge:      "fake0",
+		Device:      "eth0",
+		Automatic:   true,
+	}, nil
+}
+
+func (fc *fakeContext) ReleaseNetwork(vmId, releasedIP string, maps []pod.UserContainerPort, file *os.File) error {
+	return nil
+}
+
+func (fc *fakeContext) Stats(ctx *hypervisor.VmContext) (*types.PodStats, error) {
+	fc.driver.Lock()
+	fn := fc.driver.stats
+	fc.driver.Unlock()
+
+	if fn != nil {
+		if stats := fn(fc.vm.id); stats != nil {
+			return stats, nil
+		}
+	}
+	return &types.PodStats{Timestamp: time.Now()}, nil
+}
+
+func (fc *fakeContext) Close() {}
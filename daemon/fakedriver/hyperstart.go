@@ -0,0 +1,296 @@
+package fakedriver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+// The message codes of the hyperstart protocol, the same as api.h of
+// hyperstart.
+const (
+	initVersion = iota
+	initStartPod
+	initGetPod
+	initStopPod
+	initDestroyPod
+	initRestartContainer
+	initExecCmd
+	initFinishCmd
+	initReady
+	initAck
+	initError
+	initWinSize
+	initPing
+	initFinishPod
+	initNext
+	initWriteFile
+	initReadFile
+	initNewContainer
+	initKillContainer
+	initOnlineCpuMem
+	initSetupInterface
+	initSetupRoute
+)
+
+const (
+	ctlHeaderLen = 8
+	ttyHeaderLen = 12
+	chunkSize    = 512
+)
+
+type message struct {
+	code uint32
+	data []byte
+}
+
+type startPodCommand struct {
+	Containers []struct {
+		Id string `json:"id"`
+	} `json:"containers"`
+}
+
+type containerCommand struct {
+	Id string `json:"id"`
+}
+
+type execCommand struct {
+	Container string   `json:"container"`
+	Sequence  uint64   `json:"seq"`
+	Command   []string `json:"cmd"`
+}
+
+type killCommand struct {
+	Container string `json:"container"`
+	Signal    int    `json:"signal"`
+}
+
+type fileCommand struct {
+	Container string `json:"container"`
+	File      string `json:"file"`
+}
+
+// hyperstart simulates the init process in the VM, it talks the hyperstart
+// protocol with runv through the control and the tty channel.
+type hyperstart struct {
+	vm *vmState
+
+	ctlLock sync.Mutex
+	ctl     net.Conn
+	ttyLock sync.Mutex
+	tty     net.Conn
+}
+
+func (h *hyperstart) writeCtl(code uint32, data []byte) error {
+	buf := make([]byte, ctlHeaderLen+len(data))
+	binary.BigEndian.PutUint32(buf[0:4], code)
+	binary.BigEndian.PutUint32(buf[4:8], uint32(len(buf)))
+	copy(buf[ctlHeaderLen:], data)
+
+	h.ctlLock.Lock()
+	defer h.ctlLock.Unlock()
+	if h.ctl == nil {
+		return fmt.Errorf("control channel is not connected")
+	}
+	_, err := h.ctl.Write(buf)
+	return err
+}
+
+func (h *hyperstart) next(n int) error {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, uint32(n))
+	return h.writeCtl(initNext, buf)
+}
+
+// readCtl reads a message from the control channel, every piece read is
+// acknowledged with a next message as hyperstart does.
+func (h *hyperstart) readCtl(conn net.Conn) (*message, error) {
+	header := make([]byte, ctlHeaderLen)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	if err := h.next(ctlHeaderLen); err != nil {
+		return nil, err
+	}
+
+	length := int(binary.BigEndian.Uint32(header[4:8]))
+	if length < ctlHeaderLen {
+		return nil, fmt.Errorf("invalid message length %d", length)
+	}
+	data := make([]byte, length-ctlHeaderLen)
+	for read := 0; read < len(data); {
+		n := len(data) - read
+		if n > chunkSize {
+			n = chunkSize
+		}
+		if _, err := io.ReadFull(conn, data[read:read+n]); err != nil {
+			return nil, err
+		}
+		read += n
+		if err := h.next(n); err != nil {
+			return nil, err
+		}
+	}
+
+	return &message{code: binary.BigEndian.Uint32(header[0:4]), data: data}, nil
+}
+
+func (h *hyperstart) writeTty(seq uint64, data []byte) error {
+	buf := make([]byte, ttyHeaderLen+len(data))
+	binary.BigEndian.PutUint64(buf[0:8], seq)
+	binary.BigEndian.PutUint32(buf[8:12], uint32(len(buf)))
+	copy(buf[ttyHeaderLen:], data)
+
+	h.ttyLock.Lock()
+	defer h.ttyLock.Unlock()
+	if h.tty == nil {
+		return fmt.Errorf("tty channel is not connected")
+	}
+	_, err := h.tty.Write(buf)
+	return err
+}
+
+// serveCtl handles the commands from runv until the connection is closed
+// or the pod is destroyed.
+func (h *hyperstart) serveCtl(conn net.Conn, ready bool) {
+	h.ctlLock.Lock()
+	h.ctl = conn
+	h.ctlLock.Unlock()
+
+	if ready {
+		if err := h.writeCtl(initReady, nil); err != nil {
+			glog.Errorf("fake VM %s failed to send ready: %s", h.vm.id, err.Error())
+			return
+		}
+	}
+
+	for {
+		msg, err := h.readCtl(conn)
+		if err != nil {
+			if err != io.EOF {
+				glog.V(1).Infof("fake VM %s control channel closed: %s", h.vm.id, err.Error())
+			}
+			return
+		}
+
+		reply, err := h.handle(msg)
+		if err != nil {
+			h.writeCtl(initError, []byte(err.Error()))
+			continue
+		}
+		h.writeCtl(initAck, reply)
+		if msg.code == initDestroyPod {
+			h.vm.shutdown()
+			return
+		}
+	}
+}
+
+// serveTty drains the input of the processes, the fake processes read
+// nothing.
+func (h *hyperstart) serveTty(conn net.Conn) {
+	h.ttyLock.Lock()
+	h.tty = conn
+	h.ttyLock.Unlock()
+
+	io.Copy(ioutil.Discard, conn)
+}
+
+func (h *hyperstart) handle(msg *message) ([]byte, error) {
+	glog.V(3).Infof("fake VM %s got command %d: %s", h.vm.id, msg.code, string(msg.data))
+
+	switch msg.code {
+	case initStartPod:
+		var cmd startPodCommand
+		if err := json.Unmarshal(msg.data, &cmd); err != nil {
+			return nil, err
+		}
+		var ids []string
+		for _, c := range cmd.Containers {
+			ids = append(ids, c.Id)
+		}
+		h.vm.startPod(ids)
+	case initNewContainer:
+		var cmd containerCommand
+		if err := json.Unmarshal(msg.data, &cmd); err != nil {
+			return nil, err
+		}
+		h.vm.addContainer(cmd.Id)
+	case initExecCmd:
+		var cmd execCommand
+		if err := json.Unmarshal(msg.data, &cmd); err != nil {
+			return nil, err
+		}
+		if !h.vm.running(cmd.Container) {
+			return nil, fmt.Errorf("container %s is not running", cmd.Container)
+		}
+		result := h.vm.driver.execResult(cmd.Command)
+		go h.finishExec(cmd.Sequence, result)
+	case initKillContainer:
+		var cmd killCommand
+		if err := json.Unmarshal(msg.data, &cmd); err != nil {
+			return nil, err
+		}
+		if !h.vm.running(cmd.Container) {
+			return nil, fmt.Errorf("container %s is not running", cmd.Container)
+		}
+		// signal 0 only checks whether the container is running
+		if cmd.Signal != 0 {
+			go h.vm.exitContainer(cmd.Container, 128+cmd.Signal)
+		}
+	case initWriteFile:
+		// the content of the file follows the json command
+		r := bytes.NewReader(msg.data)
+		dec := json.NewDecoder(r)
+		var cmd fileCommand
+		if err := dec.Decode(&cmd); err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(io.MultiReader(dec.Buffered(), r))
+		if err != nil {
+			return nil, err
+		}
+		h.vm.writeFile(cmd.Container, cmd.File, data)
+	case initReadFile:
+		var cmd fileCommand
+		if err := json.Unmarshal(msg.data, &cmd); err != nil {
+			return nil, err
+		}
+		return h.vm.readFile(cmd.Container, cmd.File)
+	case initDestroyPod, initStopPod, initPing, initWinSize, initOnlineCpuMem,
+		initSetupInterface, initSetupRoute, initRestartContainer, initVersion:
+	default:
+		return nil, fmt.Errorf("unsupported command %d", msg.code)
+	}
+
+	return nil, nil
+}
+
+// finishExec writes the output of the exec process, its exit code and the
+// end of the stream to the tty channel.
+func (h *hyperstart) finishExec(seq uint64, result ExecResult) {
+	if result.Output != "" {
+		if err := h.writeTty(seq, []byte(result.Output)); err != nil {
+			glog.Errorf("fake VM %s failed to write exec output: %s", h.vm.id, err.Error())
+			return
+		}
+	}
+	h.writeTty(seq, []byte{uint8(result.ExitCode)})
+	h.writeTty(seq, nil)
+}
+
+// finishPod notifies runv the exit codes of the containers of the pod.
+func (h *hyperstart) finishPod(codes []int) error {
+	buf := make([]byte, 4*len(codes))
+	for i, code := range codes {
+		binary.BigEndian.PutUint32(buf[4*i:], uint32(code))
+	}
+	return h.writeCtl(initFinishPod, buf)
+}
@@ -0,0 +1,213 @@
+package fakedriver
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/hyperhq/runv/hypervisor"
+)
+
+type testVm struct {
+	t   *testing.T
+	d   *Driver
+	ctx *hypervisor.VmContext
+	ctl net.Conn
+	tty net.Conn
+}
+
+func launchTestVm(t *testing.T) (*testVm, func()) {
+	dir, err := ioutil.TempDir("", "fakedriver")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := InitDriver()
+	ctx := &hypervisor.VmContext{
+		Id:              "vm-test",
+		HyperSockName:   path.Join(dir, "hyper.sock"),
+		TtySockName:     path.Join(dir, "tty.sock"),
+		ConsoleSockName: path.Join(dir, "console.sock"),
+		Hub:             make(chan hypervisor.VmEvent, 16),
+	}
+	d.InitContext(dir).Launch(ctx)
+
+	tv := &testVm{t: t, d: d, ctx: ctx}
+	if tv.ctl, err = net.Dial("unix", ctx.HyperSockName); err != nil {
+		t.Fatal(err)
+	}
+	if tv.tty, err = net.Dial("unix", ctx.TtySockName); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := tv.read(); code != initReady {
+		t.Fatalf("expect ready, got %d", code)
+	}
+
+	return tv, func() {
+		tv.ctl.Close()
+		tv.tty.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func (tv *testVm) read() (uint32, []byte) {
+	tv.ctl.SetReadDeadline(time.Now().Add(5 * time.Second))
+	header := make([]byte, ctlHeaderLen)
+	if _, err := io.ReadFull(tv.ctl, header); err != nil {
+		tv.t.Fatal(err)
+	}
+	data := make([]byte, binary.BigEndian.Uint32(header[4:8])-ctlHeaderLen)
+	if _, err := io.ReadFull(tv.ctl, data); err != nil {
+		tv.t.Fatal(err)
+	}
+	return binary.BigEndian.Uint32(header[0:4]), data
+}
+
+// send sends the command and returns the reply after the next messages.
+func (tv *testVm) send(code uint32, data string) (uint32, []byte) {
+	buf := make([]byte, ctlHeaderLen+len(data))
+	binary.BigEndian.PutUint32(buf[0:4], code)
+	binary.BigEndian.PutUint32(buf[4:8], uint32(len(buf)))
+	copy(buf[ctlHeaderLen:], data)
+	if _, err := tv.ctl.Write(buf); err != nil {
+		tv.t.Fatal(err)
+	}
+
+	received := 0
+	for {
+		code, data := tv.read()
+		if code != initNext {
+			if received != len(buf) {
+				tv.t.Errorf("expect next for %d bytes, got %d", len(buf), received)
+			}
+			return code, data
+		}
+		received += int(binary.BigEndian.Uint32(data))
+	}
+}
+
+func (tv *testVm) readTty() (uint64, []byte) {
+	tv.tty.SetReadDeadline(time.Now().Add(5 * time.Second))
+	header := make([]byte, ttyHeaderLen)
+	if _, err := io.ReadFull(tv.tty, header); err != nil {
+		tv.t.Fatal(err)
+	}
+	data := make([]byte, binary.BigEndian.Uint32(header[8:12])-ttyHeaderLen)
+	if _, err := io.ReadFull(tv.tty, data); err != nil {
+		tv.t.Fatal(err)
+	}
+	return binary.BigEndian.Uint64(header[0:8]), data
+}
+
+func TestFakePodLifecycle(t *testing.T) {
+	tv, cleanup := launchTestVm(t)
+	defer cleanup()
+
+	if code, _ := tv.send(initStartPod, `{"containers":[{"id":"c1"},{"id":"c2"}]}`); code != initAck {
+		t.Fatalf("start pod, expect ack, got %d", code)
+	}
+
+	tv.d.SetExecResult("ls /", ExecResult{Output: "bin etc", ExitCode: 3})
+	if code, _ := tv.send(initExecCmd, `{"container":"c1","seq":5,"cmd":["ls","/"]}`); code != initAck {
+		t.Fatalf("exec, expect ack, got %d", code)
+	}
+	if seq, data := tv.readTty(); seq != 5 || string(data) != "bin etc" {
+		t.Errorf("expect exec output on 5, got %q on %d", data, seq)
+	}
+	if _, data := tv.readTty(); len(data) != 1 || data[0] != 3 {
+		t.Errorf("expect exit code 3, got %v", data)
+	}
+	if _, data := tv.readTty(); len(data) != 0 {
+		t.Errorf("expect end of the stream, got %v", data)
+	}
+	if code, _ := tv.send(initExecCmd, `{"container":"c3","seq":6,"cmd":["ls"]}`); code != initError {
+		t.Errorf("exec in unknown container, expect error, got %d", code)
+	}
+
+	content := make([]byte, 3*chunkSize)
+	for i := range content {
+		content[i] = byte('a' + i%26)
+	}
+	if code, _ := tv.send(initWriteFile, `{"container":"c1","file":"/etc/hosts"}`+string(content)); code != initAck {
+		t.Fatalf("write file, expect ack, got %d", code)
+	}
+	if code, data := tv.send(initReadFile, `{"container":"c1","file":"/etc/hosts"}`); code != initAck || string(data) != string(content) {
+		t.Errorf("read file, expect the written content, got %d", code)
+	}
+	if code, _ := tv.send(initReadFile, `{"container":"c2","file":"/etc/hosts"}`); code != initError {
+		t.Errorf("read missing file, expect error, got %d", code)
+	}
+
+	if code, _ := tv.send(initKillContainer, `{"container":"c1","signal":0}`); code != initAck {
+		t.Fatalf("probe the running container, expect ack, got %d", code)
+	}
+	if code, _ := tv.send(initKillContainer, `{"container":"c1","signal":9}`); code != initAck {
+		t.Fatalf("kill, expect ack, got %d", code)
+	}
+	if err := tv.d.FinishContainer("vm-test", "c2", 1); err != nil {
+		t.Fatal(err)
+	}
+	code, data := tv.read()
+	if code != initFinishPod || len(data) != 8 {
+		t.Fatalf("expect finish pod, got %d %v", code, data)
+	}
+	if c1, c2 := binary.BigEndian.Uint32(data[0:4]), binary.BigEndian.Uint32(data[4:8]); c1 != 137 || c2 != 1 {
+		t.Errorf("expect exit codes 137 and 1, got %d and %d", c1, c2)
+	}
+	if code, _ := tv.send(initKillContainer, `{"container":"c1","signal":0}`); code != initError {
+		t.Errorf("probe the exited container, expect error, got %d", code)
+	}
+
+	if code, _ := tv.send(initDestroyPod, ""); code != initAck {
+		t.Fatalf("destroy pod, expect ack, got %d", code)
+	}
+	select {
+	case ev := <-tv.ctx.Hub:
+		if _, ok := ev.(*hypervisor.VmExit); !ok {
+			t.Errorf("expect VM exit, got %#v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("VM doesn't exit")
+	}
+	if ids := tv.d.VmIds(); len(ids) != 0 {
+		t.Errorf("expect no VM left, got %v", ids)
+	}
+}
+
+func TestFakeDriverContext(t *testing.T) {
+	tv, cleanup := launchTestVm(t)
+	defer cleanup()
+
+	dc, err := tv.d.LoadContext(map[string]interface{}{"hypervisor": "fake", "id": "vm-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tv.d.LoadContext(map[string]interface{}{"hypervisor": "fake", "id": "vm-none"}); err == nil {
+		t.Error("load context of unknown VM should fail")
+	}
+
+	result := make(chan error, 1)
+	dc.SetCpus(tv.ctx, 2, result)
+	if err := <-result; err != nil {
+		t.Error(err)
+	}
+
+	dc.AddDisk(tv.ctx, "image", &hypervisor.BlockDescriptor{Name: "vol1"})
+	if ev, ok := (<-tv.ctx.Hub).(*hypervisor.BlockdevInsertedEvent); !ok || ev.Name != "vol1" || ev.DeviceName != "sda" {
+		t.Errorf("expect vol1 inserted as sda, got %#v", ev)
+	}
+
+	if stats, err := dc.Stats(tv.ctx); err != nil || stats == nil {
+		t.Errorf("expect stats, got %v", err)
+	}
+
+	dc.Kill(tv.ctx)
+	if _, ok := (<-tv.ctx.Hub).(*hypervisor.VmKilledEvent); !ok {
+		t.Error("expect VM killed")
+	}
+}
@@ -110,6 +110,9 @@ func (d *Driver) Setup() (err error) {
 	)
 
 	d.daemon = daemon
+	if !d.daemon.VmList.Reserve(d.pullVm) {
+		return fmt.Errorf("VM(%s) already exists", d.pullVm)
+	}
 	vm, err = d.daemon.StartVm(d.pullVm, 1, 64, false, false, types.VM_KEEP_AFTER_SHUTDOWN)
 	if err != nil {
 		glog.Errorf(err.Error())
@@ -337,7 +340,7 @@ func (d *Driver) VmMountLayer(id string) error {
 		return err
 	}
 	podId := fmt.Sprintf("pull-%s", utils.RandStr(10, "alpha"))
-	vm, ok := daemon.VmList[d.pullVm]
+	vm, ok := daemon.VmList.Get(d.pullVm)
 	if !ok {
 		return fmt.Errorf("can not find VM(%s)", d.pullVm)
 	}
@@ -348,7 +351,9 @@ func (d *Driver) VmMountLayer(id string) error {
 			d.daemon.KillVm(d.pullVm)
 			return err
 		}
-		vm := d.daemon.VmList[d.pullVm]
+		if vm, ok = d.daemon.VmList.Get(d.pullVm); !ok {
+			return fmt.Errorf("can not find VM(%s)", d.pullVm)
+		}
 		// wait for cmd finish
 		Status, err := vm.GetResponseChan()
 		if err != nil {
@@ -1,11 +1,13 @@
 package vbox
 
 import (
+	"archive/tar"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/docker/docker/pkg/archive"
@@ -76,7 +78,7 @@ func (d *Driver) Diff(id, parent string) (diff archive.Archive, err error) {
 	}
 
 	// start or replace pod
-	vm, ok := d.daemon.VmList[d.pullVm]
+	vm, ok := d.daemon.VmList.Get(d.pullVm)
 	if !ok {
 		return nil, fmt.Errorf("can not find VM(%s)", d.pullVm)
 	}
@@ -87,7 +89,9 @@ func (d *Driver) Diff(id, parent string) (diff archive.Archive, err error) {
 			d.daemon.KillVm(d.pullVm)
 			return nil, err
 		}
-		vm := d.daemon.VmList[d.pullVm]
+		if vm, ok = d.daemon.VmList.Get(d.pullVm); !ok {
+			return nil, fmt.Errorf("can not find VM(%s)", d.pullVm)
+		}
 		// wait for cmd finish
 		Status, err := vm.GetResponseChan()
 		if err != nil {
@@ -158,8 +162,61 @@ func (d *Driver) Diff(id, parent string) (diff archive.Archive, err error) {
 	}), nil
 }
 
+// Changes lists the entries of the diff of the layer, a whiteout entry is
+// a deleted file, the others are added, or changed if they exist in the
+// parent layers.
 func (d *Driver) Changes(id, parent string) ([]archive.Change, error) {
-	return nil, nil
+	diff, err := d.Diff(id, parent)
+	if err != nil {
+		return nil, err
+	}
+	defer diff.Close()
+
+	var layers []string
+	if parent != "" {
+		ids, err := getParentIds(d.RootPath(), id)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range ids {
+			layers = append(layers, path.Join(d.RootPath(), "diff", i))
+		}
+	}
+
+	var changes []archive.Change
+	tr := tar.NewReader(diff)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Join("/", hdr.Name)
+		base := path.Base(name)
+		if name == "/" || strings.HasPrefix(base, archive.WhiteoutMetaPrefix) {
+			continue
+		}
+		if strings.HasPrefix(base, archive.WhiteoutPrefix) {
+			changes = append(changes, archive.Change{
+				Path: path.Join(path.Dir(name), base[len(archive.WhiteoutPrefix):]),
+				Kind: archive.ChangeDelete,
+			})
+			continue
+		}
+
+		change := archive.Change{Path: name, Kind: archive.ChangeAdd}
+		for _, layer := range layers {
+			if _, err := os.Lstat(path.Join(layer, name)); err == nil {
+				change.Kind = archive.ChangeModify
+				break
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
 }
 
 func (d *Driver) DiffSize(id, parent string) (size int64, err error) {
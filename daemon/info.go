@@ -14,27 +14,22 @@ import (
 )
 
 func (daemon *Daemon) GetPodInfo(podName string) (types.PodInfo, error) {
-	daemon.PodList.RLock()
-	glog.V(2).Infof("lock read of PodList")
-	defer daemon.PodList.RUnlock()
-	defer glog.V(2).Infof("unlock read of PodList")
-	var (
-		pod     *Pod
-		ok      bool
-		imageid string
-	)
-	if strings.Contains(podName, "pod-") {
-		pod, ok = daemon.PodList.Get(podName)
-		if !ok {
-			return types.PodInfo{}, fmt.Errorf("Can not get Pod info with pod ID(%s)", podName)
-		}
-	} else {
-		pod = daemon.PodList.GetByName(podName)
-		if pod == nil {
-			return types.PodInfo{}, fmt.Errorf("Can not get Pod info with pod name(%s)", podName)
-		}
+	var imageid string
+
+	pod, err := daemon.ResolvePod(podName)
+	if err != nil {
+		return types.PodInfo{}, err
 	}
 
+	// the IPs are got from the VM, before the status of the pod is locked
+	podIPs := []string{}
+	if vm := pod.vm; vm != nil {
+		podIPs = pod.status.GetPodIP(vm)
+	}
+
+	pod.RLock()
+	defer pod.RUnlock()
+
 	// Construct the PodInfo JSON structure
 	cStatus := []types.ContainerStatus{}
 	containers := []types.Container{}
@@ -44,7 +39,7 @@ func (daemon *Daemon) GetPodInfo(podName string) (types.PodInfo, error) {
 		vols := []types.VolumeMount{}
 		cmd := []string{}
 		args := []string{}
-		Response, err := daemon.Daemon.ContainerInspect(c.Id, false, version.Version("1.21"))
+		Response, err := daemon.engine.ContainerInspect(c.Id, false, version.Version("1.21"))
 		if err == nil {
 			var jsonResponse *dockertypes.ContainerJSON
 			jsonResponse, _ = Response.(*dockertypes.ContainerJSON)
@@ -91,35 +86,11 @@ func (daemon *Daemon) GetPodInfo(podName string) (types.PodInfo, error) {
 			Volume:          vols,
 			Tty:             pod.spec.Containers[i].Tty,
 			ImagePullPolicy: "",
+			LivenessProbe:   pod.probe(i, ProbeLiveness),
+			ReadinessProbe:  pod.probe(i, ProbeReadiness),
 		}
 		containers = append(containers, container)
-		// Set ContainerStatus
-		s := types.ContainerStatus{}
-		s.Name = c.Name
-		s.ContainerID = c.Id
-		s.Waiting = types.WaitingStatus{Reason: ""}
-		s.Running = types.RunningStatus{StartedAt: ""}
-		s.Terminated = types.TermStatus{}
-		if c.Status == runvtypes.S_POD_CREATED {
-			s.Waiting.Reason = "Pending"
-			s.Phase = "pending"
-		} else if c.Status == runvtypes.S_POD_RUNNING {
-			s.Running.StartedAt = pod.status.StartedAt
-			s.Phase = "running"
-		} else { // S_POD_FAILED or S_POD_SUCCEEDED
-			if c.Status == runvtypes.S_POD_FAILED {
-				s.Terminated.ExitCode = c.ExitCode
-				s.Terminated.Reason = "Failed"
-				s.Phase = "failed"
-			} else {
-				s.Terminated.ExitCode = c.ExitCode
-				s.Terminated.Reason = "Succeeded"
-				s.Phase = "succeeded"
-			}
-			s.Terminated.StartedAt = pod.status.StartedAt
-			s.Terminated.FinishedAt = pod.status.FinishedAt
-		}
-		cStatus = append(cStatus, s)
+		cStatus = append(cStatus, daemon.containerStatus(pod, i))
 	}
 	podVoumes := []types.PodVolume{}
 	for _, v := range pod.spec.Volumes {
@@ -129,15 +100,12 @@ func (daemon *Daemon) GetPodInfo(podName string) (types.PodInfo, error) {
 			Driver:   v.Driver})
 	}
 	spec := types.PodSpec{
-		Volumes:    podVoumes,
-		Containers: containers,
-		Labels:     pod.spec.Labels,
-		Vcpu:       pod.spec.Resource.Vcpu,
-		Memory:     pod.spec.Resource.Memory,
-	}
-	podIPs := []string{}
-	if pod.vm != nil {
-		podIPs = pod.status.GetPodIP(pod.vm)
+		Volumes:     podVoumes,
+		Containers:  containers,
+		Labels:      pod.spec.Labels,
+		Annotations: pod.annotations,
+		Vcpu:        pod.spec.Resource.Vcpu,
+		Memory:      pod.spec.Resource.Memory,
 	}
 	status := types.PodStatus{
 		Status:    cStatus,
@@ -169,39 +137,6 @@ func (daemon *Daemon) GetPodInfo(podName string) (types.PodInfo, error) {
 	}, nil
 }
 
-func (daemon *Daemon) GetPodStats(podId string) (interface{}, error) {
-	daemon.PodList.RLock()
-	glog.V(2).Infof("lock read of PodList")
-	defer daemon.PodList.RUnlock()
-	defer glog.V(2).Infof("unlock read of PodList")
-	var (
-		pod *Pod
-		ok  bool
-	)
-	if strings.Contains(podId, "pod-") {
-		pod, ok = daemon.PodList.Get(podId)
-		if !ok {
-			return nil, fmt.Errorf("Can not get Pod stats with pod ID(%s)", podId)
-		}
-	} else {
-		pod = daemon.PodList.GetByName(podId)
-		if pod == nil {
-			return nil, fmt.Errorf("Can not get Pod stats with pod name(%s)", podId)
-		}
-	}
-
-	if pod.vm == nil || pod.status.Status != runvtypes.S_POD_RUNNING {
-		return nil, fmt.Errorf("Can not get pod stats for non-running pod (%s)", podId)
-	}
-
-	response := pod.vm.Stats()
-	if response.Data == nil {
-		return nil, fmt.Errorf("Stats for pod %s is nil", podId)
-	}
-
-	return response.Data, nil
-}
-
 func (daemon *Daemon) GetContainerInfo(name string) (types.ContainerInfo, error) {
 
 	var (
@@ -209,7 +144,7 @@ func (daemon *Daemon) GetContainerInfo(name string) (types.ContainerInfo, error)
 		c       *hypervisor.Container
 		i       int = 0
 		imageid string
-		ok      bool
+		err     error
 		cmd     []string
 		args    []string
 	)
@@ -218,23 +153,18 @@ func (daemon *Daemon) GetContainerInfo(name string) (types.ContainerInfo, error)
 	}
 	glog.Infof(name)
 
-	daemon.PodList.RLock()
-	glog.V(2).Infof("lock read of PodList")
-
-	pod, i, ok = daemon.PodList.GetByContainerIdOrName(name)
-	if !ok {
-		daemon.PodList.RUnlock()
-		glog.V(2).Infof("unlock read of PodList")
-		return types.ContainerInfo{}, fmt.Errorf("Can not find container by name(%s)", name)
+	pod, i, err = daemon.ResolveContainer(name)
+	if err != nil {
+		return types.ContainerInfo{}, err
 	}
+	pod.RLock()
+	defer pod.RUnlock()
 	c = pod.status.Containers[i]
-	daemon.PodList.RUnlock()
-	glog.V(2).Infof("unlock read of PodList")
 
 	ports := []types.ContainerPort{}
 	envs := []types.EnvironmentVar{}
 	vols := []types.VolumeMount{}
-	rsp, err := daemon.Daemon.ContainerInspect(c.Id, false, version.Version("1.21"))
+	rsp, err := daemon.engine.ContainerInspect(c.Id, false, version.Version("1.21"))
 	if err == nil {
 		var jsonResponse *dockertypes.ContainerJSON
 		jsonResponse, _ = rsp.(*dockertypes.ContainerJSON)
@@ -269,9 +199,39 @@ func (daemon *Daemon) GetContainerInfo(name string) (types.ContainerInfo, error)
 			MountPath: v.Path,
 			ReadOnly:  v.ReadOnly})
 	}
+	return types.ContainerInfo{
+		Container: types.Container{
+			Name:            c.Name,
+			ContainerID:     c.Id,
+			Image:           pod.spec.Containers[i].Image,
+			ImageID:         imageid,
+			Commands:        cmd,
+			Args:            args,
+			Workdir:         pod.spec.Containers[i].Workdir,
+			Ports:           ports,
+			Environment:     envs,
+			Volume:          vols,
+			Tty:             pod.spec.Containers[i].Tty,
+			ImagePullPolicy: "",
+			LivenessProbe:   pod.probe(i, ProbeLiveness),
+			ReadinessProbe:  pod.probe(i, ProbeReadiness),
+		},
+		PodID:  pod.id,
+		Status: daemon.containerStatus(pod, i),
+	}, nil
+}
+
+// containerStatus returns the status of the ith container of the pod,
+// including the results of its probes.
+func (daemon *Daemon) containerStatus(pod *Pod, i int) types.ContainerStatus {
+	c := pod.status.Containers[i]
+
 	s := types.ContainerStatus{}
 	s.Name = c.Name
 	s.ContainerID = c.Id
+	s.RestartCount = daemon.GetRestartCount(pod.id, c.Name)
+	s.Ready = pod.containerReady(i)
+	s.Liveness, s.Readiness = pod.probeStatus(i)
 	s.Waiting = types.WaitingStatus{Reason: ""}
 	s.Running = types.RunningStatus{StartedAt: ""}
 	s.Terminated = types.TermStatus{}
@@ -294,22 +254,5 @@ func (daemon *Daemon) GetContainerInfo(name string) (types.ContainerInfo, error)
 		s.Terminated.StartedAt = pod.status.StartedAt
 		s.Terminated.FinishedAt = pod.status.FinishedAt
 	}
-	return types.ContainerInfo{
-		Container: types.Container{
-			Name:            c.Name,
-			ContainerID:     c.Id,
-			Image:           pod.spec.Containers[i].Image,
-			ImageID:         imageid,
-			Commands:        cmd,
-			Args:            args,
-			Workdir:         pod.spec.Containers[i].Workdir,
-			Ports:           ports,
-			Environment:     envs,
-			Volume:          vols,
-			Tty:             pod.spec.Containers[i].Tty,
-			ImagePullPolicy: "",
-		},
-		PodID:  pod.id,
-		Status: s,
-	}, nil
+	return s
 }
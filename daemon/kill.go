@@ -0,0 +1,31 @@
+package daemon
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/golang/glog"
+	"github.com/hyperhq/runv/hypervisor/types"
+)
+
+// KillContainer sends the signal to the process tree of one container in
+// the guest, the other containers of the pod are left untouched.
+func (daemon *Daemon) KillContainer(name string, sig syscall.Signal) error {
+	pod, idx, err := daemon.ResolveContainer(name)
+	if err != nil {
+		return err
+	}
+
+	c := pod.status.Containers[idx]
+	if pod.vm == nil || pod.status.Status != types.S_POD_RUNNING || c.Status != types.S_POD_RUNNING {
+		return fmt.Errorf("Container %s is not running", name)
+	}
+
+	glog.V(1).Infof("Send signal %d to container %s of pod %s", sig, c.Id, pod.id)
+	if err := pod.vm.KillContainer(c.Id, sig); err != nil {
+		return err
+	}
+	daemon.LogPodContainerEvent(pod, c, "kill")
+
+	return nil
+}
@@ -3,12 +3,14 @@ package daemon
 import (
 	"fmt"
 
-	"github.com/golang/glog"
+	labelselector "github.com/hyperhq/hyper/lib/selector"
 	"github.com/hyperhq/runv/hypervisor"
 	"github.com/hyperhq/runv/hypervisor/types"
 )
 
-func (daemon *Daemon) List(item, podId, vmId string, auxiliary bool) (map[string][]string, error) {
+// List lists the pods, containers or VMs, only the ones of the pods whose
+// labels match the selector are listed.
+func (daemon *Daemon) List(item, podId, vmId, selector string, auxiliary bool) (map[string][]string, error) {
 	var (
 		pod                   *Pod           = nil
 		vm                    *hypervisor.Vm = nil
@@ -21,40 +23,76 @@ func (daemon *Daemon) List(item, podId, vmId string, auxiliary bool) (map[string
 		return list, fmt.Errorf("Can not support %s list!", item)
 	}
 
-	daemon.PodList.RLock()
-	glog.Infof("lock read of PodList")
-	defer glog.Infof("unlock read of PodList")
-	defer daemon.PodList.RUnlock()
+	sel, err := labelselector.Parse(selector)
+	if err != nil {
+		return list, err
+	}
+	// the pods are read with their RWMutex held, they may be operated
+	// at the same time
+	match := func(p *Pod) bool {
+		p.RLock()
+		defer p.RUnlock()
+		return sel.Empty() || sel.Matches(p.spec.Labels)
+	}
+	vmOf := func(p *Pod) string {
+		p.RLock()
+		defer p.RUnlock()
+		return p.status.Vm
+	}
+	showPodOf := func(p *Pod) string {
+		p.RLock()
+		defer p.RUnlock()
+		return p.id + ":" + showPod(p.status)
+	}
+	showContainersOf := func(p *Pod) []string {
+		p.RLock()
+		defer p.RUnlock()
+		return showPodContainers(p.status, auxiliary)
+	}
+	matchVm := func(v *hypervisor.Vm) bool {
+		if sel.Empty() {
+			return true
+		}
+		if v.Pod == nil {
+			return false
+		}
+		p, ok := daemon.PodList.Get(v.Pod.Id)
+		return ok && match(p)
+	}
 
 	if podId != "" {
-		var ok bool
-		pod, ok = daemon.PodList.Get(podId)
-		if !ok || (pod == nil) {
-			return list, fmt.Errorf("Cannot find specified pod %s", podId)
+		var err error
+		if pod, err = daemon.ResolvePod(podId); err != nil {
+			return list, err
 		}
 	}
 
 	if vmId != "" {
-		var ok bool
-		vm, ok = daemon.VmList[vmId]
-		if !ok || (vm == nil) {
-			return list, fmt.Errorf("Cannot find specified vm %s", vmId)
+		var err error
+		if vm, err = daemon.ResolveVm(vmId); err != nil {
+			return list, err
 		}
+		vmId = vm.Id
 	}
 
 	if item == "vm" {
 		if podId == "" && vmId == "" {
-			for v, info := range daemon.VmList {
-				vmJsonResponse = append(vmJsonResponse, v+":"+showVM(info))
-			}
+			daemon.VmList.Foreach(func(v *hypervisor.Vm) error {
+				if matchVm(v) {
+					vmJsonResponse = append(vmJsonResponse, v.Id+":"+showVM(v))
+				}
+				return nil
+			})
 		} else if podId != "" && vmId == "" {
-			if v, ok := daemon.VmList[pod.status.Vm]; ok {
-				vmJsonResponse = append(vmJsonResponse, pod.status.Vm+":"+showVM(v))
+			if v, ok := daemon.VmList.Get(vmOf(pod)); ok && match(pod) {
+				vmJsonResponse = append(vmJsonResponse, v.Id+":"+showVM(v))
 			}
 		} else if podId == "" && vmId != "" {
-			vmJsonResponse = append(vmJsonResponse, vmId+":"+showVM(vm))
+			if matchVm(vm) {
+				vmJsonResponse = append(vmJsonResponse, vmId+":"+showVM(vm))
+			}
 		} else {
-			if pod.status.Vm == vmId {
+			if vmOf(pod) == vmId && match(pod) {
 				vmJsonResponse = append(vmJsonResponse, vmId+":"+showVM(vm))
 			}
 		}
@@ -65,21 +103,25 @@ func (daemon *Daemon) List(item, podId, vmId string, auxiliary bool) (map[string
 	if item == "pod" {
 		if podId == "" && vmId == "" {
 			daemon.PodList.Foreach(func(p *Pod) error {
-				podJsonResponse = append(podJsonResponse, p.id+":"+showPod(p.status))
+				if match(p) {
+					podJsonResponse = append(podJsonResponse, showPodOf(p))
+				}
 				return nil
 			})
 		} else if podId != "" && vmId == "" {
-			podJsonResponse = append(podJsonResponse, pod.id+":"+showPod(pod.status))
+			if match(pod) {
+				podJsonResponse = append(podJsonResponse, showPodOf(pod))
+			}
 		} else if podId == "" && vmId != "" {
 			daemon.PodList.Foreach(func(p *Pod) error {
-				if p.status.Vm == vmId {
-					podJsonResponse = append(podJsonResponse, p.id+":"+showPod(p.status))
+				if vmOf(p) == vmId && match(p) {
+					podJsonResponse = append(podJsonResponse, showPodOf(p))
 				}
 				return nil
 			})
 		} else {
-			if pod.status.Vm == vmId {
-				podJsonResponse = append(podJsonResponse, pod.id+":"+showPod(pod.status))
+			if vmOf(pod) == vmId && match(pod) {
+				podJsonResponse = append(podJsonResponse, showPodOf(pod))
 			}
 		}
 		list["podData"] = podJsonResponse
@@ -88,21 +130,25 @@ func (daemon *Daemon) List(item, podId, vmId string, auxiliary bool) (map[string
 	if item == "container" {
 		if podId == "" && vmId == "" {
 			daemon.PodList.Foreach(func(p *Pod) error {
-				containerJsonResponse = append(containerJsonResponse, showPodContainers(p.status, auxiliary)...)
+				if match(p) {
+					containerJsonResponse = append(containerJsonResponse, showContainersOf(p)...)
+				}
 				return nil
 			})
 		} else if podId != "" && vmId == "" {
-			containerJsonResponse = append(containerJsonResponse, showPodContainers(pod.status, auxiliary)...)
+			if match(pod) {
+				containerJsonResponse = append(containerJsonResponse, showContainersOf(pod)...)
+			}
 		} else if podId == "" && vmId != "" {
 			daemon.PodList.Foreach(func(p *Pod) error {
-				if p.status.Vm == vmId {
-					containerJsonResponse = append(containerJsonResponse, showPodContainers(p.status, auxiliary)...)
+				if vmOf(p) == vmId && match(p) {
+					containerJsonResponse = append(containerJsonResponse, showContainersOf(p)...)
 				}
 				return nil
 			})
 		} else {
-			if pod.status.Vm == vmId {
-				containerJsonResponse = append(containerJsonResponse, showPodContainers(pod.status, auxiliary)...)
+			if vmOf(pod) == vmId && match(pod) {
+				containerJsonResponse = append(containerJsonResponse, showContainersOf(pod)...)
 			}
 		}
 		list["cData"] = containerJsonResponse
@@ -196,3 +242,22 @@ func showContainer(c *hypervisor.Container) string {
 
 	return c.Id + ":" + c.Name + ":" + c.PodId + ":" + status
 }
+
+// PodLabels returns the labels of the pods whose labels match the selector.
+func (daemon *Daemon) PodLabels(selector string) (map[string]map[string]string, error) {
+	sel, err := labelselector.Parse(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make(map[string]map[string]string)
+	daemon.PodList.Foreach(func(p *Pod) error {
+		p.RLock()
+		defer p.RUnlock()
+		if sel.Matches(p.spec.Labels) {
+			labels[p.id] = p.spec.Labels
+		}
+		return nil
+	})
+	return labels, nil
+}
@@ -0,0 +1,58 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperhq/runv/hypervisor/pod"
+)
+
+func TestListSelector(t *testing.T) {
+	d := &Daemon{PodList: NewPodList(), VmList: NewVmList()}
+	for id, labels := range map[string]map[string]string{
+		"pod-web": {"app": "web", "env": "prod"},
+		"pod-db":  {"app": "db", "env": "prod"},
+		"pod-qa":  {"app": "web", "env": "qa"},
+	} {
+		p := newTestPod(id)
+		p.spec = &pod.UserPod{Labels: labels}
+		d.PodList.Put(p)
+	}
+
+	cases := map[string][]string{
+		"":                      {"pod-db", "pod-qa", "pod-web"},
+		"app=web":               {"pod-qa", "pod-web"},
+		"app=web,env!=qa":       {"pod-web"},
+		"env in (qa,staging)":   {"pod-qa"},
+		"app notin (web,db)":    {},
+		"app,!missing,env=prod": {"pod-db", "pod-web"},
+	}
+	for selector, expect := range cases {
+		list, err := d.List("pod", "", "", selector, false)
+		if err != nil {
+			t.Fatalf("failed to list %q: %v", selector, err)
+		}
+		got := map[string]bool{}
+		for _, p := range list["podData"] {
+			got[strings.Split(p, ":")[0]] = true
+		}
+		if len(got) != len(expect) {
+			t.Errorf("list %q, expect %v, got %v", selector, expect, list["podData"])
+			continue
+		}
+		for _, id := range expect {
+			if !got[id] {
+				t.Errorf("list %q, expect %v, got %v", selector, expect, list["podData"])
+			}
+		}
+
+		containers, err := d.List("container", "", "", selector, false)
+		if err != nil || len(containers["cData"]) != len(expect) {
+			t.Errorf("list containers %q, expect %d, got %v, %v", selector, len(expect), containers["cData"], err)
+		}
+	}
+
+	if _, err := d.List("pod", "", "", "app in (web", false); err == nil {
+		t.Error("expect the invalid selector to fail")
+	}
+}
@@ -38,7 +38,7 @@ func (daemon *Daemon) GetContainerLogs(container string, config *ContainerLogsCo
 	outStream := config.OutStream
 	errStream := outStream
 
-	pod, cidx, err = daemon.GetPodByContainerIdOrName(container)
+	pod, cidx, err = daemon.ResolveContainer(container)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,164 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/golang/glog"
+	"github.com/hyperhq/hyper/daemon/metrics"
+	"github.com/hyperhq/runv/hypervisor"
+	"github.com/hyperhq/runv/hypervisor/types"
+)
+
+var (
+	podStartDuration = metrics.NewHistogram("hyperd_pod_start_duration_seconds",
+		"The time to start a pod, including the time to get its VM.", metrics.DefBuckets)
+	vmBootDuration = metrics.NewHistogram("hyperd_vm_boot_duration_seconds",
+		"The time to launch a VM.", metrics.DefBuckets)
+)
+
+func init() {
+	metrics.Register(podStartDuration, vmBootDuration)
+}
+
+var podStatusNames = map[uint]string{
+	types.S_POD_CREATED:   "pending",
+	types.S_POD_RUNNING:   "running",
+	types.S_POD_FAILED:    "failed",
+	types.S_POD_SUCCEEDED: "succeeded",
+	types.S_POD_PAUSED:    "paused",
+}
+
+var vmStatusNames = map[uint]string{
+	types.S_VM_IDLE:       "idle",
+	types.S_VM_ASSOCIATED: "associated",
+	types.S_VM_PAUSED:     "paused",
+}
+
+// EnableMetrics turns the metrics endpoint on or off.
+func (daemon *Daemon) EnableMetrics(enable bool) {
+	var v int32
+	if enable {
+		v = 1
+	}
+	atomic.StoreInt32(&daemon.metricsEnabled, v)
+}
+
+func (daemon *Daemon) MetricsEnabled() bool {
+	return atomic.LoadInt32(&daemon.metricsEnabled) == 1
+}
+
+// WriteMetrics writes the metrics of the daemon in the text format of
+// Prometheus. The pods, the VMs and the resource usage of the running pods
+// are collected at the moment, the latencies and the API requests are
+// accumulated since hyperd started.
+func (daemon *Daemon) WriteMetrics(w io.Writer) error {
+	if !daemon.MetricsEnabled() {
+		return fmt.Errorf("The metrics endpoint hasn't been activated, set EnableMetrics in the config of hyperd")
+	}
+
+	families := daemon.collectMetrics()
+	for _, f := range families {
+		if err := f.Write(w); err != nil {
+			return err
+		}
+	}
+	return metrics.WriteRegistered(w)
+}
+
+func (daemon *Daemon) collectMetrics() []*metrics.Family {
+	pods := metrics.NewGaugeFamily("hyperd_pods", "The number of pods by status.", "status")
+	for status, name := range podStatusNames {
+		pods.Set(float64(daemon.PodList.CountStatus(status)), name)
+	}
+
+	vms := metrics.NewGaugeFamily("hyperd_vms", "The number of VMs by status.", "status")
+	for _, name := range vmStatusNames {
+		vms.Set(0, name)
+	}
+	daemon.VmList.Foreach(func(vm *hypervisor.Vm) error {
+		if name, ok := vmStatusNames[vm.Status]; ok {
+			vms.Add(1, name)
+		}
+		return nil
+	})
+
+	families := []*metrics.Family{pods, vms}
+	families = append(families, daemon.vmCacheMetrics()...)
+	return append(families, daemon.podUsageMetrics()...)
+}
+
+func (daemon *Daemon) vmCacheMetrics() []*metrics.Family {
+	var (
+		idle      = metrics.NewGaugeFamily("hyperd_vm_cache_idle_vms", "The number of booted VMs idle in the pools of the cache policy.")
+		templates = metrics.NewGaugeFamily("hyperd_vm_cache_templates", "The number of template VMs of the clone policy.")
+		requests  = metrics.NewCounterFamily("hyperd_vm_cache_requests_total", "The VMs requested from the pools, by whether a cached VM is taken.", "result")
+		hitRatio  = metrics.NewGaugeFamily("hyperd_vm_cache_hit_ratio", "The ratio of the VM requests served by the pools.")
+	)
+
+	_, pool, tmpls := daemon.VmCacheInfo()
+	templates.Set(float64(len(tmpls)))
+	if pool == nil {
+		idle.Set(0)
+		return []*metrics.Family{idle, templates}
+	}
+
+	var n int
+	for _, f := range pool.Flavors {
+		n += f.Idle
+	}
+	idle.Set(float64(n))
+	requests.Set(float64(pool.Hits), "hit")
+	requests.Set(float64(pool.Misses), "miss")
+	families := []*metrics.Family{idle, templates, requests}
+	if total := pool.Hits + pool.Misses; total > 0 {
+		hitRatio.Set(float64(pool.Hits) / float64(total))
+		families = append(families, hitRatio)
+	}
+	return families
+}
+
+// podUsageMetrics collects the stats of the running pods, the pods whose
+// stats can't be got are skipped.
+func (daemon *Daemon) podUsageMetrics() []*metrics.Family {
+	var (
+		cpu        = metrics.NewCounterFamily("hyperd_container_cpu_seconds_total", "The cpu time used by the container.", "pod", "container")
+		memory     = metrics.NewGaugeFamily("hyperd_container_memory_usage_bytes", "The memory used by the container.", "pod", "container")
+		blkioRead  = metrics.NewCounterFamily("hyperd_container_blkio_read_bytes_total", "The bytes read from the block devices by the container.", "pod", "container")
+		blkioWrite = metrics.NewCounterFamily("hyperd_container_blkio_write_bytes_total", "The bytes written to the block devices by the container.", "pod", "container")
+		netRx      = metrics.NewCounterFamily("hyperd_pod_network_receive_bytes_total", "The bytes received by the pod.", "pod")
+		netTx      = metrics.NewCounterFamily("hyperd_pod_network_transmit_bytes_total", "The bytes sent by the pod.", "pod")
+	)
+
+	for _, p := range daemon.PodList.snapshot() {
+		if p.status.Status != types.S_POD_RUNNING {
+			continue
+		}
+		stats, err := daemon.GetPodStats(p.id)
+		if err != nil {
+			glog.V(1).Infof("Skip the metrics of pod %s: %s", p.id, err.Error())
+			continue
+		}
+
+		for i, c := range stats.Containers {
+			name := c.Name
+			if name == "" {
+				name = c.ContainerID
+			}
+			cpu.Set(float64(c.Cpu.Usage.Total)/1e9, p.id, name)
+			memory.Set(float64(c.Memory.Usage), p.id, name)
+			read, write := c.Blkio.Bytes()
+			blkioRead.Set(float64(read), p.id, name)
+			blkioWrite.Set(float64(write), p.id, name)
+
+			// the containers share the network of the pod
+			if i == 0 {
+				rx, tx := c.Network.Bytes()
+				netRx.Set(float64(rx), p.id)
+				netTx.Set(float64(tx), p.id)
+			}
+		}
+	}
+	return []*metrics.Family{cpu, memory, blkioRead, blkioWrite, netRx, netTx}
+}
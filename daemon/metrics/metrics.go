@@ -0,0 +1,276 @@
+// Package metrics keeps the counters and the histograms of hyperd, and
+// writes them in the text format of Prometheus, together with the samples
+// collected at the time of the scrape.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefBuckets are the upper bounds of the latency buckets, in seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+// Collector is a metric which writes itself in the text format.
+type Collector interface {
+	Write(w io.Writer) error
+}
+
+var (
+	mu         sync.Mutex
+	registered []Collector
+)
+
+// Register adds the collectors written by WriteRegistered.
+func Register(collectors ...Collector) {
+	mu.Lock()
+	defer mu.Unlock()
+	registered = append(registered, collectors...)
+}
+
+// WriteRegistered writes all the registered collectors.
+func WriteRegistered(w io.Writer) error {
+	mu.Lock()
+	collectors := append([]Collector{}, registered...)
+	mu.Unlock()
+
+	for _, c := range collectors {
+		if err := c.Write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// desc is the name, the help and the label names of a metric.
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d values", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+func (d *desc) writeHeader(w io.Writer) error {
+	help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help)
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, help, d.name, d.kind)
+	return err
+}
+
+// writeSample writes a sample, extra is a label appended to the ones of
+// the metric, e.g. the bucket of a histogram.
+func (d *desc) writeSample(w io.Writer, suffix string, values []string, extra string, value float64) error {
+	var buf bytes.Buffer
+	buf.WriteString(d.name + suffix)
+
+	pairs := []string{}
+	for i, name := range d.labels {
+		pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	if len(pairs) > 0 {
+		buf.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	buf.WriteString(" " + formatFloat(value) + "\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type sample struct {
+	values []string
+	value  float64
+}
+
+// Family is the samples of a gauge or a counter collected at the time of
+// the scrape, e.g. from the state of the daemon.
+type Family struct {
+	desc
+	samples map[string]*sample
+}
+
+// NewGaugeFamily returns an empty family of a gauge.
+func NewGaugeFamily(name, help string, labels ...string) *Family {
+	return &Family{
+		desc:    desc{name: name, help: help, kind: "gauge", labels: labels},
+		samples: make(map[string]*sample),
+	}
+}
+
+// NewCounterFamily returns an empty family of a counter whose values are
+// kept elsewhere.
+func NewCounterFamily(name, help string, labels ...string) *Family {
+	f := NewGaugeFamily(name, help, labels...)
+	f.kind = "counter"
+	return f
+}
+
+// Set sets the sample of the label values.
+func (f *Family) Set(value float64, values ...string) {
+	f.samples[f.key(values)] = &sample{values: values, value: value}
+}
+
+// Add adds to the sample of the label values.
+func (f *Family) Add(value float64, values ...string) {
+	key := f.key(values)
+	if s, ok := f.samples[key]; ok {
+		s.value += value
+		return
+	}
+	f.samples[key] = &sample{values: values, value: value}
+}
+
+func (f *Family) Write(w io.Writer) error {
+	if err := f.writeHeader(w); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(f.samples))
+	for key := range f.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := f.samples[key]
+		if err := f.writeSample(w, "", s.values, "", s.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Counter is a counter kept by the daemon, it is safe for concurrent use.
+type Counter struct {
+	mu     sync.Mutex
+	family *Family
+}
+
+// NewCounter returns a counter starting from zero.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{family: NewCounterFamily(name, help, labels...)}
+}
+
+// Inc increases the counter of the label values by one.
+func (c *Counter) Inc(values ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.family.Add(1, values...)
+}
+
+func (c *Counter) Write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.family.Write(w)
+}
+
+type histogram struct {
+	values []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Histogram counts the observations in buckets, it is safe for concurrent
+// use.
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogram
+}
+
+// NewHistogram returns a histogram with the upper bounds of the buckets in
+// increasing order.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return &Histogram{
+		desc:    desc{name: name, help: help, kind: "histogram", labels: labels},
+		buckets: buckets,
+		series:  make(map[string]*histogram),
+	}
+}
+
+// Observe adds the value to the histogram of the label values.
+func (h *Histogram) Observe(value float64, values ...string) {
+	key := h.key(values)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{values: values, counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += value
+}
+
+// Since observes the seconds since start.
+func (h *Histogram) Since(start time.Time, values ...string) {
+	h.Observe(time.Since(start).Seconds(), values...)
+}
+
+func (h *Histogram) Write(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.writeHeader(w); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := h.series[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			if err := h.writeSample(w, "_bucket", s.values, `le="`+formatFloat(bound)+`"`, float64(cumulative)); err != nil {
+				return err
+			}
+		}
+		if err := h.writeSample(w, "_bucket", s.values, `le="+Inf"`, float64(s.count)); err != nil {
+			return err
+		}
+		if err := h.writeSample(w, "_sum", s.values, "", s.sum); err != nil {
+			return err
+		}
+		if err := h.writeSample(w, "_count", s.values, "", float64(s.count)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
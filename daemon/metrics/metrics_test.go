@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFamily(t *testing.T) {
+	f := NewGaugeFamily("test_pods", "The pods.\nBy status.", "status")
+	f.Set(2, "running")
+	f.Add(1, `a"b`)
+	f.Add(2, `a"b`)
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP test_pods The pods.\nBy status.
+# TYPE test_pods gauge
+test_pods{status="a\"b"} 3
+test_pods{status="running"} 2
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestCounter(t *testing.T) {
+	c := NewCounter("test_requests_total", "The requests.", "route")
+	c.Inc("/info")
+	c.Inc("/info")
+
+	var buf bytes.Buffer
+	if err := c.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP test_requests_total The requests.
+# TYPE test_requests_total counter
+test_requests_total{route="/info"} 2
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram("test_duration_seconds", "The durations.", []float64{0.5, 1})
+	h.Observe(0.5)
+	h.Observe(0.75)
+	h.Observe(3)
+
+	var buf bytes.Buffer
+	if err := h.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP test_duration_seconds The durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.5"} 1
+test_duration_seconds_bucket{le="1"} 2
+test_duration_seconds_bucket{le="+Inf"} 3
+test_duration_seconds_sum 4.25
+test_duration_seconds_count 3
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
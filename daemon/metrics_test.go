@@ -0,0 +1,42 @@
+package daemon
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hyperhq/runv/hypervisor"
+	"github.com/hyperhq/runv/hypervisor/types"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	d := &Daemon{PodList: NewPodList(), VmList: NewVmList()}
+	d.vmCache.daemon = d
+
+	var buf bytes.Buffer
+	if err := d.WriteMetrics(&buf); err == nil {
+		t.Fatal("expect the metrics to be disabled by default")
+	}
+
+	created := newTestPod("pod-created")
+	created.status.Status = types.S_POD_CREATED
+	d.PodList.Put(created)
+	d.VmList.Put(&hypervisor.Vm{Id: "vm-idle", Status: types.S_VM_IDLE})
+	podStartDuration.Observe(1.5)
+
+	d.EnableMetrics(true)
+	if err := d.WriteMetrics(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`hyperd_pods{status="pending"} 1`,
+		`hyperd_pods{status="running"} 0`,
+		`hyperd_vms{status="idle"} 1`,
+		`hyperd_vm_cache_idle_vms 0`,
+		`hyperd_pod_start_duration_seconds_bucket{le="2.5"} 1`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("expect %s in the metrics:\n%s", line, buf.String())
+		}
+	}
+}
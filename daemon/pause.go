@@ -7,20 +7,22 @@ import (
 	"github.com/hyperhq/runv/hypervisor/types"
 )
 
-func (daemon Daemon) pausePod(podId string) error {
-	daemon.PodList.RLock()
-	glog.V(2).Infof("lock read of PodList")
-	pod, ok := daemon.PodList.Get(podId)
-	if !ok {
-		glog.V(2).Infof("unlock read of PodList")
-		daemon.PodList.RUnlock()
-		return fmt.Errorf("Can not get Pod info with pod ID(%s)", podId)
+func (daemon *Daemon) pausePod(podId string) error {
+	pod, err := daemon.ResolvePod(podId)
+	if err != nil {
+		return err
 	}
+
+	if err := daemon.lockPod(pod); err != nil {
+		return err
+	}
+	glog.V(2).Infof("lock pod %s", pod.id)
+	defer glog.V(2).Infof("unlock pod %s", pod.id)
+	defer pod.opLock.Unlock()
+
 	vmId := pod.status.Vm
-	glog.V(2).Infof("unlock read of PodList")
-	daemon.PodList.RUnlock()
 
-	vm, ok := daemon.VmList[vmId]
+	vm, ok := daemon.VmList.Get(vmId)
 	if !ok {
 		return fmt.Errorf("Can not find VM whose Id is %s!", vmId)
 	}
@@ -29,41 +31,46 @@ func (daemon Daemon) pausePod(podId string) error {
 		return err
 	}
 
-	pod.status.SetContainerStatus(types.S_POD_PAUSED)
-	pod.status.Status = types.S_POD_PAUSED
+	pod.updateStatus(func() {
+		pod.status.SetContainerStatus(types.S_POD_PAUSED)
+		pod.status.Status = types.S_POD_PAUSED
+	})
 	vm.Status = types.S_VM_PAUSED
+	daemon.LogPodEvent(pod, "pause")
 
 	return nil
 }
 
-func (daemon Daemon) PauseContainer(container string) error {
+func (daemon *Daemon) PauseContainer(container string) error {
 	glog.V(1).Infof("Get container id is %s", container)
-	podId, err := daemon.GetPodByContainer(container)
+	pod, _, err := daemon.ResolveContainerOrPod(container)
 	if err != nil {
 		return err
 	}
 
-	return daemon.pausePod(podId)
+	return daemon.pausePod(pod.id)
 }
 
 func (daemon *Daemon) unpausePod(podId string) error {
-	daemon.PodList.RLock()
-	glog.V(2).Infof("lock read of PodList")
-	pod, ok := daemon.PodList.Get(podId)
-	if !ok {
-		glog.V(2).Infof("unlock read of PodList")
-		daemon.PodList.RUnlock()
-		return fmt.Errorf("Can not get Pod info with pod ID(%s)", podId)
+	pod, err := daemon.ResolvePod(podId)
+	if err != nil {
+		return err
 	}
+
+	if err := daemon.lockPod(pod); err != nil {
+		return err
+	}
+	glog.V(2).Infof("lock pod %s", pod.id)
+	defer glog.V(2).Infof("unlock pod %s", pod.id)
+	defer pod.opLock.Unlock()
+
 	vmId := pod.status.Vm
-	glog.V(2).Infof("unlock read of PodList")
-	daemon.PodList.RUnlock()
 
 	if pod.status.Status != types.S_POD_PAUSED {
 		return fmt.Errorf("pod is not paused")
 	}
 
-	vm, ok := daemon.VmList[vmId]
+	vm, ok := daemon.VmList.Get(vmId)
 	if !ok {
 		return fmt.Errorf("Can not find VM whose Id is %s!", vmId)
 	}
@@ -72,19 +79,22 @@ func (daemon *Daemon) unpausePod(podId string) error {
 		return err
 	}
 
-	pod.status.SetContainerStatus(types.S_POD_RUNNING)
-	pod.status.Status = types.S_POD_RUNNING
+	pod.updateStatus(func() {
+		pod.status.SetContainerStatus(types.S_POD_RUNNING)
+		pod.status.Status = types.S_POD_RUNNING
+	})
 	vm.Status = types.S_VM_ASSOCIATED
+	daemon.LogPodEvent(pod, "unpause")
 
 	return nil
 }
 
 func (daemon *Daemon) UnpauseContainer(container string) error {
 	glog.V(1).Infof("Get container id is %s", container)
-	podId, err := daemon.GetPodByContainer(container)
+	pod, _, err := daemon.ResolveContainerOrPod(container)
 	if err != nil {
 		return err
 	}
 
-	return daemon.unpausePod(podId)
+	return daemon.unpausePod(pod.id)
 }
@@ -2,7 +2,6 @@ package daemon
 
 import (
 	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -23,6 +22,7 @@ import (
 
 	"github.com/golang/glog"
 	"github.com/hyperhq/hyper/servicediscovery"
+	apitypes "github.com/hyperhq/hyper/types"
 	"github.com/hyperhq/hyper/utils"
 	"github.com/hyperhq/runv/hypervisor"
 	"github.com/hyperhq/runv/hypervisor/pod"
@@ -30,11 +30,6 @@ import (
 )
 
 func (daemon *Daemon) StartPod(stdin io.ReadCloser, stdout io.WriteCloser, podId, vmId, tag string) (int, string, error) {
-	// we can only support 1024 Pods
-	if daemon.GetRunningPodNum() >= 1024 {
-		return -1, "", fmt.Errorf("Pod full, the maximum Pod is 1024!")
-	}
-
 	var ttys []*hypervisor.TtyIO = []*hypervisor.TtyIO{}
 
 	if tag != "" {
@@ -49,28 +44,36 @@ func (daemon *Daemon) StartPod(stdin io.ReadCloser, stdout io.WriteCloser, podId
 
 	glog.Infof("pod:%s, vm:%s", podId, vmId)
 	// Do the status check for the given pod
-	daemon.PodList.Lock()
-	glog.V(2).Infof("lock PodList")
+	p, err := daemon.ResolvePod(podId)
+	if err != nil {
+		return -1, "", err
+	}
+	if vmId != "" {
+		vm, err := daemon.ResolveVm(vmId)
+		if err != nil {
+			return -1, "", err
+		}
+		vmId = vm.Id
+	}
 
-	p, ok := daemon.PodList.Get(podId)
-	if !ok {
-		glog.V(2).Infof("unlock PodList")
-		daemon.PodList.Unlock()
-		return -1, "", fmt.Errorf("The pod(%s) can not be found, please create it first", podId)
+	if err := daemon.lockPod(p); err != nil {
+		return -1, "", err
 	}
+	glog.V(2).Infof("lock pod %s", p.id)
 	var lazy bool = hypervisor.HDriver.SupportLazyMode() && vmId == ""
 
+	// the pod is started by the user, the restart policies apply again and
+	// the pending restart is dropped
+	p.setStopped(false)
+
 	code, cause, err := daemon.StartPodWithLock(p, vmId, nil, lazy, types.VM_KEEP_NONE, ttys)
+	glog.V(2).Infof("unlock pod %s", p.id)
+	p.opLock.Unlock()
 	if err != nil {
 		glog.Error(err.Error())
-		glog.V(2).Infof("unlock PodList")
-		daemon.PodList.Unlock()
 		return -1, "", err
 	}
 
-	glog.V(2).Infof("unlock PodList")
-	daemon.PodList.Unlock()
-
 	if len(ttys) > 0 {
 		p.RLock()
 		tty, ok := p.ttyList[tag]
@@ -86,29 +89,42 @@ func (daemon *Daemon) StartPod(stdin io.ReadCloser, stdout io.WriteCloser, podId
 
 //create pod if not exist
 func (daemon *Daemon) RunPod(podId, podArgs, vmId string, config interface{}, lazy, autoremove bool, keep int, streams []*hypervisor.TtyIO) (int, string, error) {
-	daemon.PodList.Lock()
-	glog.V(2).Infof("lock PodList")
-	defer glog.V(2).Infof("unlock PodList")
-	defer daemon.PodList.Unlock()
 	glog.V(1).Infof("podArgs: %s", podArgs)
 
 	p, err := daemon.GetPod(podId, podArgs, autoremove)
 	if err != nil {
 		return -1, "", err
 	}
+	glog.V(2).Infof("lock pod %s", p.id)
+	defer glog.V(2).Infof("unlock pod %s", p.id)
+	defer p.opLock.Unlock()
 
 	return daemon.StartPodWithLock(p, vmId, config, lazy, keep, streams)
 }
 
+// The caller must hold the lock of the pod
 func (daemon *Daemon) StartPodWithLock(p *Pod, vmId string, config interface{}, lazy bool, keep int, streams []*hypervisor.TtyIO) (int, string, error) {
 	if p.vm != nil {
 		return -1, "", fmt.Errorf("pod %s is already running", p.id)
 	}
 
+	req := podAllocation(p.spec.Resource)
+	if vmId != "" {
+		// the pod runs in an existing VM, no more resource is allocated
+		req.cpus, req.mem = 0, 0
+	}
+	release, err := daemon.admit(p.id, req)
+	if err != nil {
+		return -1, "", err
+	}
+	defer release()
+
+	start := time.Now()
 	vmResponse, err := p.Start(daemon, vmId, lazy, keep, streams)
 	if err != nil {
 		return -1, "", err
 	}
+	podStartDuration.Since(start)
 
 	return vmResponse.Code, vmResponse.Cause, nil
 }
@@ -122,6 +138,21 @@ type Pod struct {
 	ctnStartInfo []*hypervisor.ContainerInfo
 	volumes      []*hypervisor.VolumeInfo
 	ttyList      map[string]*hypervisor.TtyIO
+	// opLock serializes the lifecycle operations (start, stop, remove...)
+	// of the pod, the embedded RWMutex protects the fields like ttyList, and
+	// the status changed by hyperd, which is read by list and info
+	opLock       sync.Mutex
+	restartDelay time.Duration
+	restartTimer *time.Timer
+	// stopped is set when the pod is stopped by the user, it is not
+	// restarted by the restart policies until it is started again
+	stopped     bool
+	probers     []*prober
+	probeStop   chan struct{}
+	unhealthy   bool
+	preStop     map[string]*apitypes.ExecAction
+	annotations map[string]string
+	finished    chan struct{}
 	sync.RWMutex
 }
 
@@ -131,6 +162,8 @@ func NewPod(rawSpec []byte, id string, data interface{}, autoremove bool) (*Pod,
 	p := &Pod{
 		id:      id,
 		ttyList: make(map[string]*hypervisor.TtyIO),
+		// the pod is waited for from its creation
+		finished: make(chan struct{}),
 	}
 
 	if p.spec, err = pod.ProcessPodBytes(rawSpec); err != nil {
@@ -138,6 +171,32 @@ func NewPod(rawSpec []byte, id string, data interface{}, autoremove bool) (*Pod,
 		return nil, err
 	}
 
+	cspec, err := parseHyperSpec(rawSpec)
+	if err != nil {
+		glog.V(1).Infof("Process POD file error: %s", err.Error())
+		return nil, err
+	}
+
+	p.annotations = cspec.Annotations
+	// the containers without names in the raw spec are named by runv
+	for idx := range cspec.Containers {
+		if idx < len(p.spec.Containers) {
+			cspec.Containers[idx].Name = p.spec.Containers[idx].Name
+		}
+	}
+
+	if p.probers, err = newProbers(cspec); err != nil {
+		glog.V(1).Infof("Process POD probes error: %s", err.Error())
+		return nil, err
+	}
+
+	p.preStop = map[string]*apitypes.ExecAction{}
+	for _, c := range cspec.Containers {
+		if c.PreStop != nil {
+			p.preStop[c.Name] = c.PreStop
+		}
+	}
+
 	if err = p.init(data, autoremove); err != nil {
 		return nil, err
 	}
@@ -169,6 +228,19 @@ func (p *Pod) Status() *hypervisor.PodStatus {
 	return p.status
 }
 
+// updateStatus runs fn to change the status of the pod with the RWMutex
+// held, fn is run without lock on a nil pod, i.e. the one which has been
+// removed.
+func (p *Pod) updateStatus(fn func()) {
+	if p == nil {
+		fn()
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	fn()
+}
+
 func (p *Pod) DoCreate(daemon *Daemon) error {
 	jsons, err := p.tryLoadContainers(daemon)
 	if err != nil {
@@ -195,19 +267,34 @@ func (p *Pod) DoCreate(daemon *Daemon) error {
 }
 
 func (daemon *Daemon) CreatePod(podId, podArgs string, autoremove bool) (*Pod, error) {
-	// we can only support 1024 Pods
-	if daemon.GetRunningPodNum() >= 1024 {
-		return nil, fmt.Errorf("Pod full, the maximum Pod is 1024!")
+	release, err := daemon.admit(podId, allocation{pods: 1})
+	if err != nil {
+		return nil, err
 	}
+	release()
 
 	if podId == "" {
 		podId = fmt.Sprintf("pod-%s", pod.RandStr(10, "alpha"))
 	}
 
-	return daemon.createPodInternal(podId, podArgs, autoremove, false)
+	return daemon.createPodInternal(podId, podArgs, autoremove)
+}
+
+func (daemon *Daemon) createPodInternal(podId, podArgs string, autoremove bool) (*Pod, error) {
+	pod, err := daemon.newPod(podId, podArgs, autoremove)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = daemon.addNewPod(pod, podArgs); err != nil {
+		return nil, err
+	}
+	return pod, nil
 }
 
-func (daemon *Daemon) createPodInternal(podId, podArgs string, autoremove, withinLock bool) (*Pod, error) {
+// newPod creates the pod and its containers, the pod is not added to the
+// PodList yet.
+func (daemon *Daemon) newPod(podId, podArgs string, autoremove bool) (*Pod, error) {
 	glog.V(2).Infof("podArgs: %s", podArgs)
 
 	pod, err := NewPod([]byte(podArgs), podId, daemon, autoremove)
@@ -219,67 +306,111 @@ func (daemon *Daemon) createPodInternal(podId, podArgs string, autoremove, withi
 	if err = pod.DoCreate(daemon); err != nil {
 		return nil, err
 	}
+	return pod, nil
+}
 
-	if !withinLock {
-		daemon.PodList.Lock()
-		glog.V(2).Infof("lock PodList")
-		defer glog.V(2).Infof("unlock PodList")
-		defer daemon.PodList.Unlock()
+func (daemon *Daemon) addNewPod(pod *Pod, podArgs string) error {
+	if err := daemon.AddPod(pod, podArgs); err != nil {
+		return err
 	}
+	daemon.LogPodEvent(pod, "create")
+	return nil
+}
 
-	if err = daemon.AddPod(pod, podArgs); err != nil {
-		return nil, err
+// SetPodLabels adds the labels to the pod and removes the ones in remove,
+// the existing labels are only changed with override.
+func (daemon *Daemon) SetPodLabels(podId string, override bool, labels map[string]string, remove []string) error {
+	pod, err := daemon.ResolvePod(podId)
+	if err != nil {
+		return err
 	}
 
-	return pod, nil
-}
-
-func (daemon *Daemon) SetPodLabels(podId string, override bool, labels map[string]string) error {
-	daemon.PodList.RLock()
-	glog.V(2).Infof("lock read of PodList")
-	defer daemon.PodList.RUnlock()
-	defer glog.V(2).Infof("unlock read of PodList")
+	pod.opLock.Lock()
+	glog.V(2).Infof("lock pod %s", pod.id)
+	defer glog.V(2).Infof("unlock pod %s", pod.id)
+	defer pod.opLock.Unlock()
 
-	var pod *Pod
-	if strings.Contains(podId, "pod-") {
-		var ok bool
-		pod, ok = daemon.PodList.Get(podId)
-		if !ok {
-			return fmt.Errorf("Can not get Pod info with pod ID(%s)", podId)
-		}
-	} else {
-		pod = daemon.PodList.GetByName(podId)
-		if pod == nil {
-			return fmt.Errorf("Can not get Pod info with pod name(%s)", podId)
-		}
+	merged, err := mergeMetadata("label", pod.spec.Labels, override, labels, remove)
+	if err != nil {
+		return err
 	}
 
-	if pod.spec.Labels == nil {
-		pod.spec.Labels = make(map[string]string)
+	err = daemon.updatePodSpec(pod.id, func(spec map[string]interface{}) {
+		spec["labels"] = merged
+	})
+	if err != nil {
+		return err
 	}
 
-	for k := range labels {
-		if _, ok := pod.spec.Labels[k]; ok && !override {
-			return fmt.Errorf("Can't update label %s without override", k)
-		}
-	}
+	pod.Lock()
+	pod.spec.Labels = merged
+	pod.Unlock()
+	return nil
+}
 
-	for k, v := range labels {
-		pod.spec.Labels[k] = v
+// SetPodAnnotations adds the annotations to the pod and removes the ones in
+// remove, the existing annotations are only changed with override.
+func (daemon *Daemon) SetPodAnnotations(podId string, override bool, annotations map[string]string, remove []string) error {
+	pod, err := daemon.ResolvePod(podId)
+	if err != nil {
+		return err
 	}
 
-	spec, err := json.Marshal(pod.spec)
+	pod.opLock.Lock()
+	glog.V(2).Infof("lock pod %s", pod.id)
+	defer glog.V(2).Infof("unlock pod %s", pod.id)
+	defer pod.opLock.Unlock()
+
+	merged, err := mergeMetadata("annotation", pod.annotations, override, annotations, remove)
 	if err != nil {
 		return err
 	}
 
-	if err := daemon.WritePodToDB(pod.id, spec); err != nil {
+	err = daemon.updatePodSpec(pod.id, func(spec map[string]interface{}) {
+		spec["annotations"] = merged
+	})
+	if err != nil {
 		return err
 	}
 
+	pod.Lock()
+	pod.annotations = merged
+	pod.Unlock()
 	return nil
 }
 
+// mergeMetadata returns a copy of the labels or annotations with the
+// changes applied, the original map is kept untouched since it may be read
+// by others.
+func mergeMetadata(kind string, current map[string]string, override bool, set map[string]string, remove []string) (map[string]string, error) {
+	merged := make(map[string]string)
+	for k, v := range current {
+		merged[k] = v
+	}
+
+	for _, k := range remove {
+		if _, ok := merged[k]; !ok {
+			return nil, fmt.Errorf("Can't remove %s %s, it doesn't exist", kind, k)
+		}
+		if _, ok := set[k]; ok {
+			return nil, fmt.Errorf("Can't both update and remove %s %s", kind, k)
+		}
+		delete(merged, k)
+	}
+
+	for k, v := range set {
+		if k == "" {
+			return nil, fmt.Errorf("The %s key can't be empty", kind)
+		}
+		if _, ok := merged[k]; ok && !override {
+			return nil, fmt.Errorf("Can't update %s %s without override", kind, k)
+		}
+		merged[k] = v
+	}
+
+	return merged, nil
+}
+
 func (p *Pod) init(data interface{}, autoremove bool) error {
 	if err := p.spec.Validate(); err != nil {
 		return err
@@ -345,7 +476,7 @@ func (p *Pod) tryLoadContainers(daemon *Daemon) ([]*dockertypes.ContainerJSON, e
 			containerNames[c.Name] = idx
 		}
 		for _, id := range ids {
-			if r, err := daemon.ContainerInspect(id, false, version.Version("1.21")); err == nil {
+			if r, err := daemon.engine.ContainerInspect(id, false, version.Version("1.21")); err == nil {
 				rsp, ok = r.(*dockertypes.ContainerJSON)
 				if !ok {
 					if glog.V(1) {
@@ -380,7 +511,7 @@ func (p *Pod) createNewContainers(daemon *Daemon, jsons []*dockertypes.Container
 		cleanup = func(id string) {
 			if err != nil {
 				glog.V(1).Infof("rollback container %s of %s", id, p.id)
-				daemon.Daemon.ContainerRm(id, &dockertypes.ContainerRmConfig{})
+				daemon.engine.ContainerRm(id, &dockertypes.ContainerRmConfig{})
 			}
 		}
 	)
@@ -401,7 +532,7 @@ func (p *Pod) createNewContainers(daemon *Daemon, jsons []*dockertypes.Container
 			config.Entrypoint = strslice.New(c.Entrypoint...)
 		}
 
-		ccs, err = daemon.Daemon.ContainerCreate(dockertypes.ContainerCreateConfig{
+		ccs, err = daemon.engine.ContainerCreate(dockertypes.ContainerCreateConfig{
 			Name:   c.Name,
 			Config: config,
 		})
@@ -413,7 +544,7 @@ func (p *Pod) createNewContainers(daemon *Daemon, jsons []*dockertypes.Container
 		defer cleanup(ccs.ID)
 
 		glog.Infof("create container %s", ccs.ID)
-		if r, err = daemon.ContainerInspect(ccs.ID, false, version.Version("1.21")); err != nil {
+		if r, err = daemon.engine.ContainerInspect(ccs.ID, false, version.Version("1.21")); err != nil {
 			return err
 		}
 
@@ -850,8 +981,9 @@ func stopLogger(mypod *hypervisor.PodStatus) {
 
 func (p *Pod) getLogger(daemon *Daemon) (err error) {
 	if p.spec.LogConfig.Type == "" {
-		p.spec.LogConfig.Type = daemon.DefaultLog.Type
-		p.spec.LogConfig.Config = daemon.DefaultLog.Config
+		defaultLog := daemon.GetDefaultLog()
+		p.spec.LogConfig.Type = defaultLog.Type
+		p.spec.LogConfig.Config = defaultLog.Config
 	}
 
 	if p.spec.LogConfig.Type == "none" {
@@ -1010,9 +1142,11 @@ func (p *Pod) Start(daemon *Daemon, vmId string, lazy bool, keep int, streams []
 
 	// now start, the pod handler will deal with the vm
 	preparing = false
+	p.resetFinished()
 
 	vmResponse := p.vm.StartPod(p.status, p.spec, p.ctnStartInfo, p.volumes)
 	if vmResponse.Data == nil {
+		p.notifyFinished()
 		err = fmt.Errorf("VM response data is nil")
 		return vmResponse, err
 	}
@@ -1028,27 +1162,67 @@ func (p *Pod) Start(daemon *Daemon, vmId string, lazy bool, keep int, streams []
 		glog.Error(err.Error())
 		return nil, err
 	}
+	daemon.LogPodEvent(p, "start")
+	daemon.LogPodContainerEvents(p, "start")
+
+	p.unhealthy = false
+	daemon.startProbes(p)
 
 	return vmResponse, nil
 }
 
 // The caller must make sure that the restart policy and the status is right to restart
 func (daemon *Daemon) RestartPod(mypod *hypervisor.PodStatus) error {
-	// Remove the pod
-	// The pod is stopped, the vm is gone
-	daemon.CleanUpContainer(mypod)
-	daemon.RemovePod(mypod.Id)
-	daemon.DeleteVolumeId(mypod.Id)
+	var lazy bool = hypervisor.HDriver.SupportLazyMode()
 
-	podData, err := daemon.GetPodByName(mypod.Id)
-	if err != nil {
-		return err
+	p, ok := daemon.PodList.Get(mypod.Id)
+	if !ok {
+		return fmt.Errorf("The pod(%s) has been removed", mypod.Id)
+	}
+
+	p.opLock.Lock()
+	glog.V(2).Infof("lock pod %s", p.id)
+	defer glog.V(2).Infof("unlock pod %s", p.id)
+	defer p.opLock.Unlock()
+
+	if _, ok := daemon.PodList.Get(mypod.Id); !ok {
+		return fmt.Errorf("The pod(%s) has been removed", mypod.Id)
+	}
+	p.Lock()
+	p.restartTimer = nil
+	delay := p.restartDelay
+	// the containers whose restart policies ask for the restart, only their
+	// restart counts are increased
+	restarting := p.restartingContainers()
+	p.Unlock()
+	if p.status.Status == types.S_POD_RUNNING || len(restarting) == 0 {
+		return nil
+	}
+
+	if mypod.Type == "kubernetes" {
+		// Remove the pod
+		// The pod is stopped, the vm is gone
+		daemon.CleanUpContainer(mypod)
+		daemon.RemovePod(mypod.Id)
+		daemon.DeleteVolumeId(mypod.Id)
+
+		podData, err := daemon.GetPodByName(mypod.Id)
+		if err != nil {
+			return err
+		}
+
+		if p, err = daemon.GetPod(mypod.Id, string(podData), false); err != nil {
+			glog.Error(err.Error())
+			return err
+		}
+		defer p.opLock.Unlock()
+		p.Lock()
+		p.restartDelay = delay
+		p.Unlock()
 	}
-	var lazy bool = hypervisor.HDriver.SupportLazyMode()
 
 	// Start the pod
-	_, _, err = daemon.RunPod(mypod.Id, string(podData), "", nil, lazy, false, types.VM_KEEP_NONE, []*hypervisor.TtyIO{})
-	if err != nil {
+	if _, _, err := daemon.StartPodWithLock(p, "", nil, lazy, types.VM_KEEP_NONE, []*hypervisor.TtyIO{}); err != nil {
 		glog.Error(err.Error())
 		return err
 	}
@@ -1058,6 +1232,14 @@ func (daemon *Daemon) RestartPod(mypod *hypervisor.PodStatus) error {
 		return err
 	}
 
+	for _, idx := range restarting {
+		c := p.status.Containers[idx]
+		if err := daemon.IncreaseRestartCount(mypod.Id, c.Name); err != nil {
+			glog.Warningf("Failed to save the restart count of container %s: %s", c.Name, err.Error())
+		}
+	}
+	daemon.LogPodEvent(p, "restart")
+
 	return nil
 }
 
@@ -1071,43 +1253,57 @@ func hyperHandlePodEvent(vmResponse *types.VmResponse, data interface{},
 			return false
 		}
 		stopLogger(mypod)
-		mypod.SetPodContainerStatus(vmResponse.Data.([]uint32))
+		p, ok := daemon.PodList.Get(mypod.Id)
+		p.updateStatus(func() {
+			mypod.SetPodContainerStatus(vmResponse.Data.([]uint32))
+		})
 		vm.Status = types.S_VM_IDLE
+		if ok {
+			p.stopProbes()
+			p.notifyFinished()
+			daemon.LogPodContainerEvents(p, "finish")
+			daemon.LogPodEvent(p, "finish")
+		}
 		if mypod.Autoremove == true {
 			daemon.CleanPod(mypod.Id)
 			return false
 		}
 	} else if vmResponse.Code == types.E_VM_SHUTDOWN {
-		if mypod.Status == types.S_POD_RUNNING {
-			stopLogger(mypod)
-			mypod.Status = types.S_POD_SUCCEEDED
-			mypod.SetContainerStatus(types.S_POD_SUCCEEDED)
+		p, ok := daemon.PodList.Get(mypod.Id)
+		if ok {
+			p.stopProbes()
 		}
-		mypod.Vm = ""
-		daemon.PodStopped(mypod.Id)
-		if mypod.Type == "kubernetes" {
-			cleanup := false
-			switch mypod.Status {
-			case types.S_POD_SUCCEEDED:
-				if mypod.RestartPolicy == "always" {
-					daemon.RestartPod(mypod)
-					break
+		p.updateStatus(func() {
+			if mypod.Status == types.S_POD_RUNNING {
+				stopLogger(mypod)
+				if ok && p.unhealthy {
+					// killed because of the failure of liveness probe
+					mypod.Status = types.S_POD_FAILED
+					mypod.SetContainerStatus(types.S_POD_FAILED)
+				} else {
+					mypod.Status = types.S_POD_SUCCEEDED
+					mypod.SetContainerStatus(types.S_POD_SUCCEEDED)
 				}
-				cleanup = true
-			case types.S_POD_FAILED:
-				if mypod.RestartPolicy != "never" {
-					daemon.RestartPod(mypod)
-					break
-				}
-				cleanup = true
-			default:
-				break
 			}
-			if cleanup {
-				daemon.CleanUpContainer(mypod)
-				daemon.DeleteVolumeId(mypod.Id)
+			mypod.Vm = ""
+		})
+		if ok {
+			p.notifyFinished()
+		}
+		daemon.LogVmEvent(vm.Id, mypod.Id, "shutdown")
+		if ok {
+			if err := daemon.SavePodExit(p); err != nil {
+				glog.Warningf("Failed to save the exit status of pod %s: %s", mypod.Id, err.Error())
 			}
 		}
+		daemon.PodStopped(mypod.Id)
+		if daemon.ScheduleRestart(mypod) {
+			return true
+		}
+		if mypod.Type == "kubernetes" {
+			daemon.CleanUpContainer(mypod)
+			daemon.DeleteVolumeId(mypod.Id)
+		}
 		return true
 	}
 
@@ -3,9 +3,13 @@ package daemon
 import (
 	"encoding/json"
 	"fmt"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/hyperhq/runv/hypervisor/pod"
+	"github.com/hyperhq/runv/hypervisor/types"
 )
 
 func TestDNSInsertRegular(t *testing.T) {
@@ -97,3 +101,235 @@ func TestDNSInsertRegular(t *testing.T) {
 		}
 	}
 }
+
+func TestSetPodMetadata(t *testing.T) {
+	d, cleanup := newTestDBDaemon(t)
+	defer cleanup()
+
+	p := newTestPod("pod-meta")
+	p.spec = &pod.UserPod{Labels: map[string]string{"app": "web", "tier": "frontend"}}
+	d.PodList.Put(p)
+
+	raw := `{"labels":{"app":"web","tier":"frontend"},"containers":[{"name":"web","preStop":{"command":["sync"]}}]}`
+	if err := d.WritePodToDB(p.id, []byte(raw)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.SetPodLabels(p.id, false, map[string]string{"app": "db"}, nil); err == nil {
+		t.Error("expect updating a label without override to fail")
+	}
+	if err := d.SetPodLabels(p.id, false, nil, []string{"missing"}); err == nil {
+		t.Error("expect removing a missing label to fail")
+	}
+	if err := d.SetPodLabels(p.id, true, map[string]string{"app": "db", "env": "qa"}, []string{"tier"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetPodAnnotations(p.id, false, map[string]string{"commit": "abc", "owner": "ops"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetPodAnnotations(p.id, false, nil, []string{"owner"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(p.spec.Labels) != "map[app:db env:qa]" || fmt.Sprint(p.annotations) != "map[commit:abc]" {
+		t.Errorf("unexpected labels %v, annotations %v", p.spec.Labels, p.annotations)
+	}
+
+	data, err := d.GetPodByName(p.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := parseHyperSpec(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored struct {
+		Labels map[string]string `json:"labels"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(stored.Labels) != "map[app:db env:qa]" || fmt.Sprint(spec.Annotations) != "map[commit:abc]" {
+		t.Errorf("unexpected stored spec %s", data)
+	}
+	if len(spec.Containers) != 1 || spec.Containers[0].PreStop == nil {
+		t.Errorf("the preStop is lost in the stored spec %s", data)
+	}
+}
+
+func TestLockRemovedPod(t *testing.T) {
+	d := &Daemon{PodList: NewPodList()}
+	p := newTestPod("pod-locked")
+	d.PodList.Put(p)
+
+	if err := d.lockPod(p); err != nil {
+		t.Fatal(err)
+	}
+	// the pod is removed while the others are waiting for its lock
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		d.PodList.Delete(p.id)
+		p.opLock.Unlock()
+	}()
+	if err := d.lockPod(p); err == nil {
+		t.Fatal("expect the removed pod not to be locked")
+	}
+}
+
+func TestFakePodLifecycle(t *testing.T) {
+	fd, cleanup := newFakeDaemon(t)
+	defer cleanup()
+
+	p := fd.createPod("lifecycle", "")
+	if status, _ := fd.status(p); status != types.S_POD_CREATED {
+		t.Fatalf("expect the pod to be created, got %d", status)
+	}
+
+	fd.startPod(p)
+	status, vmId := fd.status(p)
+	if status != types.S_POD_RUNNING || vmId == "" {
+		t.Fatalf("expect the pod to be running in a VM, got %d in %q", status, vmId)
+	}
+	if ids := fd.driver.VmIds(); len(ids) != 1 || ids[0] != vmId {
+		t.Fatalf("expect the pod to run in fake VM %s, got %v", vmId, ids)
+	}
+
+	cid := p.status.Containers[0].Id
+	fd.driver.SetStats(func(id string) *types.PodStats {
+		return &types.PodStats{
+			Timestamp:       time.Now(),
+			ContainersStats: []types.ContainerStats{{ContainerID: cid}},
+		}
+	})
+	stats, err := fd.GetPodStats(p.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Containers) != 1 || stats.Containers[0].Name != "lifecycle-c" {
+		t.Errorf("unexpected stats %v", stats)
+	}
+
+	if _, _, err := fd.StopPod(p.id, "yes"); err != nil {
+		t.Fatal(err)
+	}
+	fd.waitStatus(p, types.S_POD_SUCCEEDED)
+	fd.waitFor(5*time.Second, "the VM to be stopped", func() bool {
+		return len(fd.driver.VmIds()) == 0
+	})
+
+	// the stopped pod starts again in a new VM
+	fd.startPod(p)
+	if status, newVm := fd.status(p); status != types.S_POD_RUNNING || newVm == vmId {
+		t.Fatalf("expect the pod to be running in a new VM, got %d in %q", status, newVm)
+	}
+	if _, _, err := fd.CleanPod(p.id); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fd.PodList.Get(p.id); ok {
+		t.Fatal("the pod is not removed")
+	}
+}
+
+func TestFakeGracefulStopPod(t *testing.T) {
+	fd, cleanup := newFakeDaemon(t)
+	defer cleanup()
+
+	p := fd.createPod("graceful", "")
+	fd.startPod(p)
+
+	// the fake containers exit on the signal
+	if _, _, err := fd.GracefulStopPod(p.id, "yes", syscall.SIGTERM, 5); err != nil {
+		t.Fatal(err)
+	}
+	fd.waitStatus(p, types.S_POD_SUCCEEDED)
+	if result := p.exitStatus(); result.Containers[0].ExitCode != 128+int(syscall.SIGTERM) {
+		t.Errorf("expect the container to be terminated, got %#v", result)
+	}
+
+	// the pod removed once it finishes
+	spec := `{"id": "autoremove", "containers": [{"name": "autoremove-c", "image": "busybox"}], "resource": {"vcpu": 1, "memory": 128}}`
+	removed, err := fd.CreatePod("", spec, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd.startPod(removed)
+	if code, _, err := fd.GracefulStopPod(removed.id, "yes", syscall.SIGTERM, 5); err != nil || code != types.E_VM_SHUTDOWN {
+		t.Fatalf("failed to stop the autoremove pod: %d, %v", code, err)
+	}
+	if _, ok := fd.PodList.Get(removed.id); ok {
+		t.Fatal("the autoremove pod is not removed")
+	}
+}
+
+func TestFakeConcurrentPods(t *testing.T) {
+	fd, cleanup := newFakeDaemon(t)
+	defer cleanup()
+
+	var pods []*Pod
+	for i := 0; i < 4; i++ {
+		pods = append(pods, fd.createPod(fmt.Sprintf("concurrent-%d", i), ""))
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	// list and inspect the pods while they are operated
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				for _, item := range []string{"pod", "container", "vm"} {
+					if _, err := fd.List(item, "", "", "", false); err != nil {
+						t.Errorf("failed to list %s: %v", item, err)
+					}
+				}
+				for _, p := range pods {
+					if _, err := fd.GetPodInfo(p.id); err != nil {
+						t.Errorf("failed to get the info of pod %s: %v", p.id, err)
+					}
+				}
+			}
+		}()
+	}
+
+	var ops sync.WaitGroup
+	for _, p := range pods {
+		// the same pod is started and stopped at the same time, the
+		// operations are serialized by the lock of the pod
+		for j := 0; j < 2; j++ {
+			ops.Add(1)
+			go func(p *Pod) {
+				defer ops.Done()
+				for k := 0; k < 3; k++ {
+					fd.StartPod(nil, nil, p.id, "", "")
+					fd.StopPod(p.id, "yes")
+				}
+			}(p)
+		}
+	}
+	ops.Wait()
+	close(done)
+	wg.Wait()
+
+	for _, p := range pods {
+		fd.StopPod(p.id, "yes")
+		fd.waitFor(10*time.Second, "pod "+p.id+" to be stopped", func() bool {
+			status, vm := fd.status(p)
+			return status != types.S_POD_RUNNING && vm == ""
+		})
+		if _, _, err := fd.CleanPod(p.id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fd.waitFor(10*time.Second, "all the VMs to be stopped", func() bool {
+		return len(fd.driver.VmIds()) == 0
+	})
+	if _, _, err := fd.StartPod(nil, nil, pods[0].id, "", ""); err == nil {
+		t.Error("expect the removed pod not to be started")
+	}
+}
@@ -1,17 +1,19 @@
 package daemon
 
 import (
-	"strings"
 	"sync"
 
 	"github.com/hyperhq/runv/hypervisor"
 	"github.com/hyperhq/runv/hypervisor/types"
 )
 
+// PodList is the index of the pods of the daemon. Its lock only protects
+// the maps and is never held while operating on a pod, the lifecycle
+// operations of a pod are serialized by the lock of the pod itself.
 type PodList struct {
 	pods       map[string]*Pod
 	containers map[string]string
-	sync.RWMutex
+	mu         sync.RWMutex
 }
 
 func NewPodList() *PodList {
@@ -22,6 +24,9 @@ func NewPodList() *PodList {
 }
 
 func (pl *PodList) Get(id string) (*Pod, bool) {
+	pl.mu.RLock()
+	defer pl.mu.RUnlock()
+
 	if pl.pods == nil {
 		return nil, false
 	}
@@ -30,6 +35,9 @@ func (pl *PodList) Get(id string) (*Pod, bool) {
 }
 
 func (pl *PodList) Put(p *Pod) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
 	if pl.pods == nil {
 		pl.pods = make(map[string]*Pod)
 	}
@@ -44,6 +52,9 @@ func (pl *PodList) Put(p *Pod) {
 }
 
 func (pl *PodList) Delete(id string) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
 	if p, ok := pl.pods[id]; ok {
 		for _, c := range p.status.Containers {
 			delete(pl.containers, c.Id)
@@ -62,13 +73,17 @@ func (pl *PodList) GetByName(name string) *Pod {
 }
 
 func (pl *PodList) GetByContainerId(cid string) (*Pod, bool) {
+	pl.mu.RLock()
 	if pl.pods == nil {
+		pl.mu.RUnlock()
 		return nil, false
 	}
 	if podid, ok := pl.containers[cid]; ok {
 		p, ok := pl.pods[podid]
+		pl.mu.RUnlock()
 		return p, ok
 	}
+	pl.mu.RUnlock()
 
 	pod := pl.Find(func(p *Pod) bool {
 		for _, c := range p.status.Containers {
@@ -80,70 +95,16 @@ func (pl *PodList) GetByContainerId(cid string) (*Pod, bool) {
 	})
 
 	if pod != nil {
-		pl.containers[cid] = pod.id
+		pl.mu.Lock()
+		if _, ok := pl.pods[pod.id]; ok {
+			pl.containers[cid] = pod.id
+		}
+		pl.mu.Unlock()
 		return pod, true
 	}
 	return nil, false
 }
 
-func (pl *PodList) GetByContainerIdOrName(cid string) (*Pod, int, bool) {
-	if pl.pods == nil {
-		return nil, 0, false
-	}
-	if podid, ok := pl.containers[cid]; ok {
-		if p, ok := pl.pods[podid]; ok {
-			for idx, c := range p.status.Containers {
-				if c.Id == cid {
-					return p, idx, true
-				}
-			}
-		}
-		return nil, -1, false
-	}
-
-	matchPods := []string{}
-	fullId := ""
-	for c, p := range pl.containers {
-		if strings.HasPrefix(c, cid) {
-			matchPods = append(matchPods, p)
-			fullId = c
-		}
-	}
-	if len(matchPods) > 1 {
-		return nil, -1, false
-	} else if len(matchPods) == 1 {
-		if p, ok := pl.pods[matchPods[0]]; ok {
-			for idx, c := range p.status.Containers {
-				if c.Id == fullId {
-					return p, idx, true
-				}
-			}
-		}
-		return nil, -1, false
-	}
-
-	var idx int
-	wslash := cid
-	if cid[0] != '/' {
-		wslash = "/" + cid
-	}
-
-	pod := pl.Find(func(p *Pod) bool {
-		for i, c := range p.status.Containers {
-			if c.Id == cid || c.Name == wslash {
-				idx = i
-				return true
-			}
-		}
-		return false
-	})
-
-	if pod != nil {
-		return pod, idx, true
-	}
-	return nil, -1, false
-}
-
 func (pl *PodList) GetStatus(id string) (*hypervisor.PodStatus, bool) {
 	p, ok := pl.Get(id)
 	if !ok {
@@ -159,14 +120,7 @@ func (pl *PodList) CountRunning() int64 {
 func (pl *PodList) CountStatus(status uint) (num int64) {
 	num = 0
 
-	pl.RLock()
-	defer pl.RUnlock()
-
-	if pl.pods == nil {
-		return
-	}
-
-	for _, pod := range pl.pods {
+	for _, pod := range pl.snapshot() {
 		if pod.status.Status == status {
 			num++
 		}
@@ -177,25 +131,35 @@ func (pl *PodList) CountStatus(status uint) (num int64) {
 
 func (pl *PodList) CountContainers() (num int64) {
 	num = 0
-	pl.RLock()
-	defer pl.RUnlock()
-
-	if pl.pods == nil {
-		return
-	}
 
-	for _, pod := range pl.pods {
+	for _, pod := range pl.snapshot() {
 		num += int64(len(pod.status.Containers))
 	}
 
 	return
 }
 
+// snapshot returns the pods in the list at the moment, so that they could
+// be iterated without holding the lock of the list.
+func (pl *PodList) snapshot() []*Pod {
+	pl.mu.RLock()
+	defer pl.mu.RUnlock()
+
+	pods := make([]*Pod, 0, len(pl.pods))
+	for _, p := range pl.pods {
+		pods = append(pods, p)
+	}
+	return pods
+}
+
 type PodOp func(*Pod) error
 type PodFilterOp func(*Pod) bool
 
+// Foreach calls fn on every pod in the list, fn is called without holding
+// the lock of the list, and the pods added or removed in the meantime
+// may or may not be visited.
 func (pl *PodList) Foreach(fn PodOp) error {
-	for _, p := range pl.pods {
+	for _, p := range pl.snapshot() {
 		if err := fn(p); err != nil {
 			return err
 		}
@@ -204,7 +168,7 @@ func (pl *PodList) Foreach(fn PodOp) error {
 }
 
 func (pl *PodList) Find(fn PodFilterOp) *Pod {
-	for _, p := range pl.pods {
+	for _, p := range pl.snapshot() {
 		if fn(p) {
 			return p
 		}
@@ -0,0 +1,114 @@
+package daemon
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperhq/runv/hypervisor"
+	"github.com/hyperhq/runv/hypervisor/types"
+)
+
+func newTestPod(id string) *Pod {
+	return &Pod{
+		id: id,
+		status: &hypervisor.PodStatus{
+			Id:     id,
+			Name:   "name-" + id,
+			Status: types.S_POD_RUNNING,
+			Containers: []*hypervisor.Container{
+				{Id: "container-" + id, Name: "/c-" + id, Status: types.S_POD_RUNNING},
+			},
+		},
+	}
+}
+
+func TestPodListConcurrent(t *testing.T) {
+	pl := NewPodList()
+	daemon := &Daemon{PodList: pl}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				id := fmt.Sprintf("pod-%d-%d", i, j)
+				pl.Put(newTestPod(id))
+				if _, ok := pl.Get(id); !ok {
+					t.Errorf("can not get pod %s", id)
+				}
+				if p, ok := pl.GetByContainerId("container-" + id); !ok || p.id != id {
+					t.Errorf("can not get pod %s by container id", id)
+				}
+				if p := pl.GetByName("name-" + id); p == nil {
+					t.Errorf("can not get pod %s by name", id)
+				}
+				if j%2 == 0 {
+					pl.Delete(id)
+				}
+			}
+		}(i)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				pl.CountRunning()
+				pl.CountContainers()
+				pl.Foreach(func(p *Pod) error {
+					return nil
+				})
+				if _, err := daemon.List("pod", "", "", "", false); err != nil {
+					t.Errorf("failed to list pods: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := pl.CountRunning(); n != 8*25 {
+		t.Fatalf("expect %d running pods, got %d", 8*25, n)
+	}
+	if _, ok := pl.GetByContainerId("container-pod-0-0"); ok {
+		t.Fatalf("container of the deleted pod should be removed from the index")
+	}
+}
+
+func TestPodLockIndependent(t *testing.T) {
+	pl := NewPodList()
+	busy, idle := newTestPod("pod-busy"), newTestPod("pod-idle")
+	pl.Put(busy)
+	pl.Put(idle)
+
+	// a long operation on one pod blocks neither the list nor the others
+	busy.opLock.Lock()
+	defer busy.opLock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pl.Foreach(func(p *Pod) error {
+			return nil
+		})
+		p, ok := pl.Get("pod-idle")
+		if !ok {
+			t.Errorf("can not get pod-idle")
+			return
+		}
+		p.opLock.Lock()
+		p.status.Status = types.S_POD_SUCCEEDED
+		p.opLock.Unlock()
+		pl.Delete("pod-idle")
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("operations on other pods are blocked by the lock of pod-busy")
+	}
+	if _, ok := pl.Get("pod-idle"); ok {
+		t.Fatalf("pod-idle should be deleted")
+	}
+}
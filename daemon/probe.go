@@ -0,0 +1,320 @@
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	apitypes "github.com/hyperhq/hyper/types"
+	"github.com/hyperhq/runv/hypervisor"
+	"github.com/hyperhq/runv/hypervisor/types"
+)
+
+const (
+	ProbeLiveness  = "liveness"
+	ProbeReadiness = "readiness"
+
+	ProbeSuccess = "success"
+	ProbeFailure = "failure"
+	ProbeUnknown = "unknown"
+
+	defaultProbeInterval         = 10
+	defaultProbeTimeout          = 1
+	defaultProbeFailureThreshold = 3
+)
+
+// prober runs one probe of a container periodically and keeps the result.
+type prober struct {
+	kind      string
+	container string
+	probe     *apitypes.Probe
+
+	sync.Mutex
+	status apitypes.ProbeStatus
+}
+
+func newProbers(spec *hyperSpec) ([]*prober, error) {
+	var probers []*prober
+
+	for _, c := range spec.Containers {
+		for kind, probe := range map[string]*apitypes.Probe{
+			ProbeLiveness:  c.LivenessProbe,
+			ProbeReadiness: c.ReadinessProbe,
+		} {
+			if probe == nil {
+				continue
+			}
+			if err := validateProbe(probe); err != nil {
+				return nil, fmt.Errorf("Invalid %s probe of container %s: %s", kind, c.Name, err.Error())
+			}
+			probers = append(probers, &prober{
+				kind:      kind,
+				container: c.Name,
+				probe:     probe,
+				status:    apitypes.ProbeStatus{Result: ProbeUnknown},
+			})
+		}
+	}
+
+	return probers, nil
+}
+
+func validateProbe(probe *apitypes.Probe) error {
+	actions := 0
+	if probe.Exec != nil {
+		if len(probe.Exec.Command) == 0 {
+			return fmt.Errorf("exec probe without command")
+		}
+		actions++
+	}
+	if probe.TCPSocket != nil {
+		if probe.TCPSocket.Port <= 0 || probe.TCPSocket.Port > 65535 {
+			return fmt.Errorf("invalid port %d", probe.TCPSocket.Port)
+		}
+		actions++
+	}
+	if probe.HTTPGet != nil {
+		if probe.HTTPGet.Port <= 0 || probe.HTTPGet.Port > 65535 {
+			return fmt.Errorf("invalid port %d", probe.HTTPGet.Port)
+		}
+		if s := strings.ToLower(probe.HTTPGet.Scheme); s != "" && s != "http" && s != "https" {
+			return fmt.Errorf("unsupported scheme %s", probe.HTTPGet.Scheme)
+		}
+		actions++
+	}
+	if actions != 1 {
+		return fmt.Errorf("exactly one of exec, tcpSocket and httpGet should be specified")
+	}
+	if probe.InitialDelay < 0 || probe.Interval < 0 || probe.Timeout < 0 || probe.FailureThreshold < 0 {
+		return fmt.Errorf("negative time or threshold")
+	}
+
+	if probe.Interval == 0 {
+		probe.Interval = defaultProbeInterval
+	}
+	if probe.Timeout == 0 {
+		probe.Timeout = defaultProbeTimeout
+	}
+	if probe.FailureThreshold == 0 {
+		probe.FailureThreshold = defaultProbeFailureThreshold
+	}
+	return nil
+}
+
+func (pr *prober) reset() {
+	pr.Lock()
+	pr.status = apitypes.ProbeStatus{Result: ProbeUnknown}
+	pr.Unlock()
+}
+
+// update records the result of a probe, and returns the number of the
+// consecutive failures.
+func (pr *prober) update(err error) int {
+	pr.Lock()
+	defer pr.Unlock()
+
+	pr.status.LastProbeTime = time.Now().UTC().Format(time.RFC3339)
+	if err == nil {
+		pr.status.Result = ProbeSuccess
+		pr.status.Failures = 0
+		pr.status.Message = ""
+	} else {
+		pr.status.Result = ProbeFailure
+		pr.status.Failures++
+		pr.status.Message = err.Error()
+	}
+	return pr.status.Failures
+}
+
+func (pr *prober) Status() *apitypes.ProbeStatus {
+	pr.Lock()
+	defer pr.Unlock()
+
+	status := pr.status
+	return &status
+}
+
+func (pr *prober) check(vm *hypervisor.Vm, container string, podIPs []string) error {
+	timeout := time.Duration(pr.probe.Timeout) * time.Second
+
+	switch {
+	case pr.probe.Exec != nil:
+		return execInContainer(vm, container, pr.probe.Exec.Command, timeout)
+	case pr.probe.TCPSocket != nil:
+		if len(podIPs) == 0 {
+			return fmt.Errorf("no IP address available")
+		}
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(podIPs[0], strconv.Itoa(pr.probe.TCPSocket.Port)), timeout)
+		if err != nil {
+			return err
+		}
+		conn.Close()
+		return nil
+	case pr.probe.HTTPGet != nil:
+		return httpProbe(pr.probe.HTTPGet, podIPs, timeout)
+	}
+
+	return fmt.Errorf("no probe action")
+}
+
+func httpProbe(action *apitypes.HTTPGetAction, podIPs []string, timeout time.Duration) error {
+	host := action.Host
+	if host == "" {
+		if len(podIPs) == 0 {
+			return fmt.Errorf("no IP address available")
+		}
+		host = podIPs[0]
+	}
+	scheme := strings.ToLower(action.Scheme)
+	if scheme == "" {
+		scheme = "http"
+	}
+	path := action.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(host, strconv.Itoa(action.Port)), path))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("HTTP probe failed with status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// getProber returns the prober of the given kind for the idx-th container.
+// The probers are looked up by the name of the container, as the service
+// discovery container is inserted before the containers of the spec.
+func (p *Pod) getProber(idx int, kind string) *prober {
+	name := strings.TrimPrefix(p.status.Containers[idx].Name, "/")
+	for _, pr := range p.probers {
+		if pr.container == name && pr.kind == kind {
+			return pr
+		}
+	}
+	return nil
+}
+
+func (p *Pod) probe(idx int, kind string) *apitypes.Probe {
+	if pr := p.getProber(idx, kind); pr != nil {
+		return pr.probe
+	}
+	return nil
+}
+
+// probeStatus returns the liveness and readiness probe results of the
+// idx-th container, nil if the container has no such probe.
+func (p *Pod) probeStatus(idx int) (liveness, readiness *apitypes.ProbeStatus) {
+	if pr := p.getProber(idx, ProbeLiveness); pr != nil {
+		liveness = pr.Status()
+	}
+	if pr := p.getProber(idx, ProbeReadiness); pr != nil {
+		readiness = pr.Status()
+	}
+	return
+}
+
+// containerReady reports whether the idx-th container is ready to serve,
+// that is, it is running and its readiness probe, if any, succeeded.
+func (p *Pod) containerReady(idx int) bool {
+	if p.status.Containers[idx].Status != types.S_POD_RUNNING {
+		return false
+	}
+	if pr := p.getProber(idx, ProbeReadiness); pr != nil {
+		return pr.Status().Result == ProbeSuccess
+	}
+	return true
+}
+
+func (daemon *Daemon) startProbes(p *Pod) {
+	p.stopProbes()
+	if len(p.probers) == 0 || p.vm == nil {
+		return
+	}
+
+	stop := make(chan struct{})
+	p.probeStop = stop
+	for idx, c := range p.status.Containers {
+		for _, kind := range []string{ProbeLiveness, ProbeReadiness} {
+			if pr := p.getProber(idx, kind); pr != nil {
+				pr.reset()
+				go daemon.runProber(p, p.vm, pr, c.Id, stop)
+			}
+		}
+	}
+}
+
+func (p *Pod) stopProbes() {
+	if p.probeStop != nil {
+		close(p.probeStop)
+		p.probeStop = nil
+	}
+}
+
+func (daemon *Daemon) runProber(p *Pod, vm *hypervisor.Vm, pr *prober, container string, stop chan struct{}) {
+	select {
+	case <-time.After(time.Duration(pr.probe.InitialDelay) * time.Second):
+	case <-stop:
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(pr.probe.Interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		err := pr.check(vm, container, p.status.GetPodIP(vm))
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		failures := pr.update(err)
+		if err != nil {
+			glog.V(1).Infof("%s probe of container %s failed (%d/%d): %s", pr.kind, container, failures, pr.probe.FailureThreshold, err.Error())
+			if pr.kind == ProbeLiveness && failures >= pr.probe.FailureThreshold {
+				daemon.killUnhealthyPod(p, vm, container)
+				return
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// killUnhealthyPod stops the VM of a pod whose liveness probe failed, the
+// pod is then marked as failed and the restart policy takes over.
+func (daemon *Daemon) killUnhealthyPod(p *Pod, vm *hypervisor.Vm, container string) {
+	p.opLock.Lock()
+	glog.V(2).Infof("lock pod %s", p.id)
+	defer glog.V(2).Infof("unlock pod %s", p.id)
+	defer p.opLock.Unlock()
+
+	if p.vm != vm || p.status.Status != types.S_POD_RUNNING {
+		return
+	}
+
+	glog.Warningf("Container %s of pod %s is unhealthy, stop the pod", container, p.id)
+	p.unhealthy = true
+	daemon.LogPodEvent(p, "unhealthy")
+
+	vmResponse := vm.StopPod(p.status, "yes")
+	daemon.DeleteVmByPod(p.id)
+	if vmResponse.Code == types.E_VM_SHUTDOWN {
+		daemon.RemoveVm(vm.Id)
+	}
+}
@@ -0,0 +1,154 @@
+package daemon
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hyperhq/runv/hypervisor"
+	"github.com/hyperhq/runv/hypervisor/types"
+)
+
+var errTest = errors.New("test error")
+
+func parseTestProbes(spec string) ([]*prober, error) {
+	cspec, err := parseHyperSpec([]byte(spec))
+	if err != nil {
+		return nil, err
+	}
+	return newProbers(cspec)
+}
+
+// newProbeTestPod returns a pod with the probers and the containers of the
+// given names.
+func newProbeTestPod(probers []*prober, names ...string) *Pod {
+	p := &Pod{probers: probers, status: &hypervisor.PodStatus{}}
+	for _, name := range names {
+		p.status.Containers = append(p.status.Containers, &hypervisor.Container{Id: "id-" + name, Name: "/" + name})
+	}
+	return p
+}
+
+func TestParseProbes(t *testing.T) {
+	spec := `{"containers":[
+		{"name":"web","livenessProbe":{"httpGet":{"path":"/healthz","port":8080},"interval":5}},
+		{"name":"db","readinessProbe":{"tcpSocket":{"port":3306},"timeout":3,"failureThreshold":1}},
+		{"name":"worker"}]}`
+
+	probers, err := parseTestProbes(spec)
+	if err != nil {
+		t.Fatalf("failed to parse probes: %v", err)
+	}
+	if len(probers) != 2 {
+		t.Fatalf("expect 2 probes, got %d", len(probers))
+	}
+
+	p := newProbeTestPod(probers, "web", "db", "worker")
+	live := p.probe(0, ProbeLiveness)
+	if live == nil || live.HTTPGet == nil || live.Interval != 5 ||
+		live.Timeout != defaultProbeTimeout || live.FailureThreshold != defaultProbeFailureThreshold {
+		t.Errorf("unexpected liveness probe of web: %#v", live)
+	}
+	ready := p.probe(1, ProbeReadiness)
+	if ready == nil || ready.TCPSocket == nil || ready.Interval != defaultProbeInterval ||
+		ready.Timeout != 3 || ready.FailureThreshold != 1 {
+		t.Errorf("unexpected readiness probe of db: %#v", ready)
+	}
+	if p.probe(2, ProbeLiveness) != nil || p.probe(2, ProbeReadiness) != nil {
+		t.Errorf("worker should not have probes")
+	}
+
+	invalid := []string{
+		`{"containers":[{"name":"a","livenessProbe":{}}]}`,
+		`{"containers":[{"name":"a","livenessProbe":{"exec":{"command":[]}}}]}`,
+		`{"containers":[{"name":"a","livenessProbe":{"tcpSocket":{"port":0}}}]}`,
+		`{"containers":[{"name":"a","livenessProbe":{"exec":{"command":["true"]},"tcpSocket":{"port":80}}}]}`,
+		`{"containers":[{"name":"a","readinessProbe":{"httpGet":{"port":80,"scheme":"ftp"}}}]}`,
+		`{"containers":[{"name":"a","readinessProbe":{"exec":{"command":["true"]},"interval":-1}}]}`,
+	}
+	for _, s := range invalid {
+		if _, err := parseTestProbes(s); err == nil {
+			t.Errorf("expect error for %s", s)
+		}
+	}
+}
+
+func TestProbesOfServicePod(t *testing.T) {
+	probers, err := parseTestProbes(`{"containers":[
+		{"name":"web","livenessProbe":{"exec":{"command":["true"]}},"readinessProbe":{"tcpSocket":{"port":80}}}],
+		"services":[{"serviceip":"10.254.0.1","serviceport":80,"protocol":"TCP"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the service discovery container is the first one of the pod
+	p := newProbeTestPod(probers, ServiceDiscoveryContainerName("pod"), "web")
+	if p.probe(0, ProbeLiveness) != nil || p.probe(0, ProbeReadiness) != nil {
+		t.Error("the service discovery container should not have probes")
+	}
+	if p.probe(1, ProbeLiveness) == nil || p.probe(1, ProbeReadiness) == nil {
+		t.Fatal("expect the probes of web")
+	}
+	p.status.Containers[0].Status = types.S_POD_RUNNING
+	p.status.Containers[1].Status = types.S_POD_RUNNING
+	if !p.containerReady(0) {
+		t.Error("the service discovery container should be ready once it is running")
+	}
+	if p.containerReady(1) {
+		t.Error("web should not be ready before its readiness probe succeeds")
+	}
+	p.getProber(1, ProbeReadiness).update(nil)
+	if !p.containerReady(1) {
+		t.Error("web should be ready once its readiness probe succeeds")
+	}
+}
+
+func TestProberUpdate(t *testing.T) {
+	pr := &prober{}
+	pr.reset()
+	if pr.Status().Result != ProbeUnknown {
+		t.Fatalf("expect unknown result after reset")
+	}
+	if n := pr.update(errTest); n != 1 {
+		t.Fatalf("expect 1 failure, got %d", n)
+	}
+	if n := pr.update(errTest); n != 2 {
+		t.Fatalf("expect 2 failures, got %d", n)
+	}
+	if n := pr.update(nil); n != 0 || pr.Status().Result != ProbeSuccess {
+		t.Fatalf("expect failures reset on success, got %d", n)
+	}
+}
+
+func TestContainerInfoProbeStatus(t *testing.T) {
+	d, cleanup := newTestDBDaemon(t)
+	defer cleanup()
+
+	probers, err := parseTestProbes(`{"containers":[{"name":"c-pod-probe",
+		"livenessProbe":{"exec":{"command":["true"]}},"readinessProbe":{"tcpSocket":{"port":80}}}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newTestPod("pod-probe")
+	p.probers = probers
+	d.PodList.Put(p)
+
+	// the status in the container info
+	live, ready := p.getProber(0, ProbeLiveness), p.getProber(0, ProbeReadiness)
+	live.update(nil)
+	ready.update(errTest)
+	s := d.containerStatus(p, 0)
+	if s.Liveness == nil || s.Liveness.Result != ProbeSuccess {
+		t.Errorf("expect the liveness probe succeeded, got %#v", s.Liveness)
+	}
+	if s.Readiness == nil || s.Readiness.Result != ProbeFailure || s.Readiness.Failures != 1 {
+		t.Errorf("expect the readiness probe failed once, got %#v", s.Readiness)
+	}
+	if s.Ready {
+		t.Error("the container should not be ready before its readiness probe succeeds")
+	}
+
+	ready.update(nil)
+	if !d.containerStatus(p, 0).Ready {
+		t.Error("the container should be ready once its readiness probe succeeds")
+	}
+}
@@ -0,0 +1,229 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	dockertypes "github.com/docker/engine-api/types"
+	"github.com/golang/glog"
+	apitypes "github.com/hyperhq/hyper/types"
+	"github.com/hyperhq/hyper/utils"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// PruneGracePeriod protects the resources of the in-flight operations from
+// the prune on demand, e.g. the containers of a pod are created before the
+// pod is added to the pod list.
+const PruneGracePeriod = 10 * time.Minute
+
+// PruneOrphans reclaims the resources left behind by the previous hyperd,
+// it runs at startup after the pods are restored.
+func (daemon *Daemon) PruneOrphans() (*apitypes.PruneReport, error) {
+	return daemon.prune(daemon.started, false)
+}
+
+// Prune reclaims the resources which belong to no pod or VM, only lists
+// them if dryRun.
+func (daemon *Daemon) Prune(dryRun bool) (*apitypes.PruneReport, error) {
+	return daemon.prune(time.Now().Add(-PruneGracePeriod), dryRun)
+}
+
+// prune collects the orphans, the files and the containers are only taken
+// if they are created before the cutoff.
+func (daemon *Daemon) prune(cutoff time.Time, dryRun bool) (*apitypes.PruneReport, error) {
+	report := &apitypes.PruneReport{DryRun: dryRun}
+
+	if err := daemon.pruneVmKeys(report); err != nil {
+		return nil, err
+	}
+	if err := daemon.pruneVolumes(report); err != nil {
+		return nil, err
+	}
+	referred, err := daemon.prunePodContainerKeys(report)
+	if err != nil {
+		return nil, err
+	}
+	daemon.pruneContainers(referred, cutoff, report)
+
+	for _, dir := range []string{
+		path.Join(utils.HYPER_ROOT, "services"),
+		path.Join(utils.HYPER_ROOT, "hosts"),
+		DefaultResourcePath,
+	} {
+		daemon.prunePodDirs(dir, cutoff, report)
+	}
+	sort.Strings(report.Keys)
+	sort.Strings(report.Volumes)
+
+	glog.Infof("Pruned %d dirs, %d volumes, %d containers and %d db keys, %d errors, dry run: %v",
+		len(report.Dirs), len(report.Volumes), len(report.Containers), len(report.Keys), len(report.Errors), dryRun)
+	return report, nil
+}
+
+func (daemon *Daemon) podExists(podId string) bool {
+	_, ok := daemon.PodList.Get(podId)
+	return ok
+}
+
+// pruneVmKeys removes the "vm-<pod>" keys whose pod is gone or whose VM is
+// not alive, and the data of the VM.
+func (daemon *Daemon) pruneVmKeys(report *apitypes.PruneReport) error {
+	iter := daemon.db.NewIterator(util.BytesPrefix([]byte("vm-")), nil)
+	orphans := map[string]string{}
+	for iter.Next() {
+		podId := string(iter.Key())[3:]
+		vmId := string(iter.Value())
+		if _, ok := daemon.VmList.Get(vmId); ok && daemon.podExists(podId) {
+			continue
+		}
+		orphans[string(iter.Key())] = vmId
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	for key, vmId := range orphans {
+		report.Keys = append(report.Keys, key)
+		if report.DryRun {
+			continue
+		}
+		if err := daemon.db.Delete([]byte(key), nil); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		daemon.DeleteVmData(vmId)
+	}
+	return nil
+}
+
+// pruneVolumes removes the volumes created by the storage whose pod is
+// gone, such as the thin devices of devicemapper. The keys of the volumes
+// are "vol-<pod>-<dev id>".
+func (daemon *Daemon) pruneVolumes(report *apitypes.PruneReport) error {
+	iter := daemon.db.NewIterator(util.BytesPrefix([]byte("vol-")), nil)
+	orphans := map[string][]byte{}
+	for iter.Next() {
+		key := string(iter.Key())
+		i := strings.LastIndex(key, "-")
+		if i <= len("vol-") || daemon.podExists(key[len("vol-"):i]) {
+			continue
+		}
+		orphans[key] = append([]byte{}, iter.Value()...)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	for key, record := range orphans {
+		podId := key[len("vol-"):strings.LastIndex(key, "-")]
+		report.Volumes = append(report.Volumes, fmt.Sprintf("%s/%s", podId, record))
+		if report.DryRun {
+			continue
+		}
+		if daemon.Storage != nil {
+			if err := daemon.Storage.RemoveVolume(podId, record); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("Failed to remove volume %s of pod %s: %s", record, podId, err.Error()))
+				continue
+			}
+		}
+		if err := daemon.db.Delete([]byte(key), nil); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+	}
+	return nil
+}
+
+// prunePodContainerKeys removes the "pod-container-<pod>" keys whose pod is
+// gone, and returns the containers referred by the remaining keys and the
+// pods.
+func (daemon *Daemon) prunePodContainerKeys(report *apitypes.PruneReport) (map[string]bool, error) {
+	referred := map[string]bool{}
+	orphans := []string{}
+
+	iter := daemon.db.NewIterator(util.BytesPrefix([]byte("pod-container-")), nil)
+	for iter.Next() {
+		key := string(iter.Key())
+		if !daemon.podExists(key[len("pod-container-"):]) {
+			orphans = append(orphans, key)
+			continue
+		}
+		for _, id := range strings.Split(string(iter.Value()), ":") {
+			referred[id] = true
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	for _, p := range daemon.PodList.snapshot() {
+		for _, c := range p.status.Containers {
+			referred[c.Id] = true
+		}
+	}
+
+	for _, key := range orphans {
+		report.Keys = append(report.Keys, key)
+		if report.DryRun {
+			continue
+		}
+		if err := daemon.db.Delete([]byte(key), nil); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+	}
+	return referred, nil
+}
+
+// pruneContainers removes the docker containers referred by no pod.
+func (daemon *Daemon) pruneContainers(referred map[string]bool, cutoff time.Time, report *apitypes.PruneReport) {
+	if daemon.Daemon == nil {
+		return
+	}
+
+	for _, c := range daemon.Daemon.List() {
+		if referred[c.ID] || !c.Created.Before(cutoff) {
+			continue
+		}
+		report.Containers = append(report.Containers, c.ID)
+		if report.DryRun {
+			continue
+		}
+		glog.V(1).Infof("Remove the orphaned container %s", c.ID)
+		if err := daemon.Daemon.ContainerRm(c.ID, &dockertypes.ContainerRmConfig{}); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("Failed to remove container %s: %s", c.ID, err.Error()))
+		}
+	}
+}
+
+// prunePodDirs removes the sub directories of dir, which are named by the
+// ids of the pods which are gone.
+func (daemon *Daemon) prunePodDirs(dir string, cutoff time.Time, report *apitypes.PruneReport) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			report.Errors = append(report.Errors, err.Error())
+		}
+		return
+	}
+
+	for _, fi := range entries {
+		if !fi.IsDir() || daemon.podExists(fi.Name()) || !fi.ModTime().Before(cutoff) {
+			continue
+		}
+		orphan := path.Join(dir, fi.Name())
+		report.Dirs = append(report.Dirs, orphan)
+		if report.DryRun {
+			continue
+		}
+		if err := os.RemoveAll(orphan); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hyperhq/hyper/utils"
+	"github.com/hyperhq/runv/hypervisor"
+)
+
+func TestPrune(t *testing.T) {
+	d, cleanup := newTestDBDaemon(t)
+	defer cleanup()
+
+	root, err := ioutil.TempDir("", "hyper-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	oldRoot, oldResPath := utils.HYPER_ROOT, DefaultResourcePath
+	utils.HYPER_ROOT, DefaultResourcePath = root, path.Join(root, "Pods")
+	defer func() { utils.HYPER_ROOT, DefaultResourcePath = oldRoot, oldResPath }()
+
+	p := newTestPod("pod-live")
+	p.status.Vm = "vm-live"
+	d.PodList.Put(p)
+	d.VmList.Put(&hypervisor.Vm{Id: "vm-live"})
+
+	for key, value := range map[string]string{
+		"vm-pod-live":            "vm-live",
+		"vm-pod-gone":            "vm-gone",
+		"vmdata-vm-gone":         "data",
+		"vol-pod-live-1":         "data:1",
+		"vol-pod-gone-2":         "data:2",
+		"pod-container-pod-live": "container-pod-live",
+		"pod-container-pod-gone": "container-pod-gone",
+	} {
+		if err := d.db.Put([]byte(key), []byte(value), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, dir := range []string{"services/pod-live", "services/pod-gone", "hosts/pod-gone", "Pods/pod-gone"} {
+		if err := os.MkdirAll(path.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the dirs just created are protected by the cutoff
+	report, err := d.prune(time.Now().Add(-time.Minute), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Dirs) != 0 {
+		t.Errorf("unexpected dirs to prune %v", report.Dirs)
+	}
+
+	report, err = d.prune(time.Now().Add(time.Minute), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.Keys, []string{"pod-container-pod-gone", "vm-pod-gone"}) {
+		t.Errorf("unexpected keys to prune %v", report.Keys)
+	}
+	if !reflect.DeepEqual(report.Volumes, []string{"pod-gone/data:2"}) {
+		t.Errorf("unexpected volumes to prune %v", report.Volumes)
+	}
+	if len(report.Dirs) != 3 {
+		t.Errorf("unexpected dirs to prune %v", report.Dirs)
+	}
+	if _, err := d.db.Get([]byte("vm-pod-gone"), nil); err != nil {
+		t.Error("the dry run should keep the orphans")
+	}
+
+	if _, err := d.prune(time.Now().Add(time.Minute), false); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"vm-pod-gone", "vmdata-vm-gone", "vol-pod-gone-2", "pod-container-pod-gone"} {
+		if _, err := d.db.Get([]byte(key), nil); err == nil {
+			t.Errorf("%s is not pruned", key)
+		}
+	}
+	for _, key := range []string{"vm-pod-live", "vol-pod-live-1", "pod-container-pod-live"} {
+		if _, err := d.db.Get([]byte(key), nil); err != nil {
+			t.Errorf("%s is pruned", key)
+		}
+	}
+	if _, err := os.Stat(path.Join(root, "services/pod-gone")); !os.IsNotExist(err) {
+		t.Error("services/pod-gone is not pruned")
+	}
+	if _, err := os.Stat(path.Join(root, "services/pod-live")); err != nil {
+		t.Error("services/pod-live is pruned")
+	}
+}
@@ -0,0 +1,44 @@
+package daemon
+
+import (
+	"io"
+
+	"github.com/docker/docker/reference"
+	"github.com/docker/docker/registry"
+	dockertypes "github.com/docker/engine-api/types"
+)
+
+// The methods accessing the registries hold registryLock, so that the
+// registry config is not replaced by a reload while they are running.
+
+func (daemon *Daemon) PullImage(ref reference.Named, metaHeaders map[string][]string, authConfig *dockertypes.AuthConfig, outStream io.Writer) error {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	return daemon.Daemon.PullImage(ref, metaHeaders, authConfig, outStream)
+}
+
+func (daemon *Daemon) PushImage(ref reference.Named, metaHeaders map[string][]string, authConfig *dockertypes.AuthConfig, outStream io.Writer) error {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	return daemon.Daemon.PushImage(ref, metaHeaders, authConfig, outStream)
+}
+
+func (daemon *Daemon) AuthenticateToRegistry(authConfig *dockertypes.AuthConfig) (string, error) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	return daemon.Daemon.AuthenticateToRegistry(authConfig)
+}
+
+func (daemon *Daemon) SystemInfo() (*dockertypes.Info, error) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	return daemon.Daemon.SystemInfo()
+}
+
+// ResolveRepository returns the repository info of the image with the
+// registry config in use.
+func (daemon *Daemon) ResolveRepository(ref reference.Named) (*registry.RepositoryInfo, error) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	return daemon.Daemon.RegistryService.ResolveRepository(ref)
+}
@@ -0,0 +1,181 @@
+package daemon
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperhq/runv/hypervisor"
+	"github.com/hyperhq/runv/hypervisor/types"
+)
+
+// AmbiguousPrefixError is returned when an ID prefix matches more than one
+// pod, container or VM.
+type AmbiguousPrefixError struct {
+	Kind    string
+	Prefix  string
+	Matches []string
+}
+
+func (e *AmbiguousPrefixError) Error() string {
+	return fmt.Sprintf("Ambiguous %s prefix %s, it matches %s", e.Kind, e.Prefix, strings.Join(e.Matches, ", "))
+}
+
+func isAmbiguous(err error) bool {
+	_, ok := err.(*AmbiguousPrefixError)
+	return ok
+}
+
+// matchPrefix returns the only ID which begins with the prefix, the kind
+// prefix of the IDs like "pod-" could be omitted.
+func matchPrefix(kind, prefix string, ids []string) (string, error) {
+	matches := []string{}
+	for _, id := range ids {
+		if strings.HasPrefix(id, prefix) || strings.HasPrefix(id, kind+"-"+prefix) {
+			matches = append(matches, id)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("No such %s: %s", kind, prefix)
+	case 1:
+		return matches[0], nil
+	}
+	sort.Strings(matches)
+	return "", &AmbiguousPrefixError{Kind: kind, Prefix: prefix, Matches: matches}
+}
+
+// ResolvePod finds the pod by its full ID, its name, or a unique prefix of
+// its ID, in that order.
+func (daemon *Daemon) ResolvePod(ref string) (*Pod, error) {
+	if ref == "" {
+		return nil, fmt.Errorf("No pod is specified")
+	}
+	if p, ok := daemon.PodList.Get(ref); ok {
+		return p, nil
+	}
+
+	pods := daemon.PodList.snapshot()
+	ids := make([]string, 0, len(pods))
+	for _, p := range pods {
+		if p.status.Name == ref {
+			return p, nil
+		}
+		ids = append(ids, p.id)
+	}
+
+	id, err := matchPrefix("pod", ref, ids)
+	if err != nil {
+		return nil, err
+	}
+	if p, ok := daemon.PodList.Get(id); ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("No such pod: %s", ref)
+}
+
+// ResolveContainer finds the container by its full ID, its name, or a
+// unique prefix of its ID, in that order. It returns the pod and the index
+// of the container in the pod.
+func (daemon *Daemon) ResolveContainer(ref string) (*Pod, int, error) {
+	if ref == "" {
+		return nil, -1, fmt.Errorf("No container is specified")
+	}
+	if p, ok := daemon.PodList.GetByContainerId(ref); ok {
+		for idx, c := range p.status.Containers {
+			if c.Id == ref {
+				return p, idx, nil
+			}
+		}
+	}
+
+	name := ref
+	if name[0] != '/' {
+		name = "/" + name
+	}
+
+	ids := []string{}
+	owners := make(map[string]*Pod)
+	for _, p := range daemon.PodList.snapshot() {
+		for idx, c := range p.status.Containers {
+			if c.Name == name {
+				return p, idx, nil
+			}
+			ids = append(ids, c.Id)
+			owners[c.Id] = p
+		}
+	}
+
+	id, err := matchPrefix("container", ref, ids)
+	if err != nil {
+		return nil, -1, err
+	}
+	p := owners[id]
+	for idx, c := range p.status.Containers {
+		if c.Id == id {
+			return p, idx, nil
+		}
+	}
+	return nil, -1, fmt.Errorf("No such container: %s", ref)
+}
+
+// ResolveContainerOrPod finds the container referred by ref, or the first
+// container of the pod referred by ref if there is no such container.
+func (daemon *Daemon) ResolveContainerOrPod(ref string) (*Pod, int, error) {
+	p, idx, err := daemon.ResolveContainer(ref)
+	if err == nil || isAmbiguous(err) {
+		return p, idx, err
+	}
+
+	p, err = daemon.ResolvePod(ref)
+	if err != nil {
+		if isAmbiguous(err) {
+			return nil, -1, err
+		}
+		return nil, -1, fmt.Errorf("No such container or pod: %s", ref)
+	}
+	if len(p.status.Containers) == 0 {
+		return nil, -1, fmt.Errorf("Pod %s has no container", p.id)
+	}
+	return p, 0, nil
+}
+
+// ResolveVm finds the VM by its full ID or a unique prefix of its ID.
+func (daemon *Daemon) ResolveVm(ref string) (*hypervisor.Vm, error) {
+	if ref == "" {
+		return nil, fmt.Errorf("No VM is specified")
+	}
+	if vm, ok := daemon.VmList.Get(ref); ok {
+		return vm, nil
+	}
+
+	ids := []string{}
+	daemon.VmList.Foreach(func(vm *hypervisor.Vm) error {
+		ids = append(ids, vm.Id)
+		return nil
+	})
+
+	id, err := matchPrefix("vm", ref, ids)
+	if err != nil {
+		return nil, err
+	}
+	if vm, ok := daemon.VmList.Get(id); ok {
+		return vm, nil
+	}
+	return nil, fmt.Errorf("No such vm: %s", ref)
+}
+
+// runningContainer returns the VM and the ID of the running container.
+func (daemon *Daemon) runningContainer(name string) (*hypervisor.Vm, string, error) {
+	p, idx, err := daemon.ResolveContainer(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	c := p.status.Containers[idx]
+	if p.vm == nil || p.status.Status != types.S_POD_RUNNING || c.Status != types.S_POD_RUNNING {
+		return nil, "", fmt.Errorf("Container %s is not running", name)
+	}
+	return p.vm, c.Id, nil
+}
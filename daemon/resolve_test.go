@@ -0,0 +1,104 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/hyperhq/runv/hypervisor"
+)
+
+func TestResolvePod(t *testing.T) {
+	d := &Daemon{PodList: NewPodList()}
+	d.PodList.Put(newTestPod("pod-abc123"))
+	d.PodList.Put(newTestPod("pod-abd456"))
+
+	for ref, id := range map[string]string{
+		"pod-abc123":      "pod-abc123",
+		"name-pod-abd456": "pod-abd456",
+		"pod-abc":         "pod-abc123",
+		"abd":             "pod-abd456",
+	} {
+		p, err := d.ResolvePod(ref)
+		if err != nil {
+			t.Errorf("failed to resolve %s: %v", ref, err)
+			continue
+		}
+		if p.id != id {
+			t.Errorf("%s is resolved to %s, expect %s", ref, p.id, id)
+		}
+	}
+
+	_, err := d.ResolvePod("pod-ab")
+	if e, ok := err.(*AmbiguousPrefixError); !ok || len(e.Matches) != 2 {
+		t.Errorf("expect the prefix to be ambiguous, got %v", err)
+	}
+	if _, err := d.ResolvePod("pod-x"); err == nil {
+		t.Error("expect no pod to be found")
+	}
+}
+
+func TestResolveContainer(t *testing.T) {
+	d := &Daemon{PodList: NewPodList()}
+	d.PodList.Put(newTestPod("pod-abc123"))
+	d.PodList.Put(newTestPod("pod-abd456"))
+
+	for ref, id := range map[string]string{
+		"container-pod-abc123": "container-pod-abc123",
+		"c-pod-abd456":         "container-pod-abd456",
+		"/c-pod-abc123":        "container-pod-abc123",
+		"container-pod-abd":    "container-pod-abd456",
+	} {
+		p, idx, err := d.ResolveContainer(ref)
+		if err != nil {
+			t.Errorf("failed to resolve %s: %v", ref, err)
+			continue
+		}
+		if c := p.status.Containers[idx]; c.Id != id {
+			t.Errorf("%s is resolved to %s, expect %s", ref, c.Id, id)
+		}
+	}
+
+	if _, _, err := d.ResolveContainer("container-pod-ab"); err == nil {
+		t.Error("expect the prefix to be ambiguous")
+	}
+
+	// the pod is resolved to its first container
+	if p, idx, err := d.ResolveContainerOrPod("pod-abc"); err != nil || p.id != "pod-abc123" || idx != 0 {
+		t.Errorf("failed to resolve the container of pod-abc: %v", err)
+	}
+	if _, _, err := d.ResolveContainerOrPod("container-pod-ab"); !isAmbiguous(err) {
+		t.Errorf("expect the container prefix to be ambiguous, got %v", err)
+	}
+	if _, _, err := d.ResolveContainerOrPod("pod-ab"); !isAmbiguous(err) {
+		t.Errorf("expect the pod prefix to be ambiguous, got %v", err)
+	}
+}
+
+func TestResolveVm(t *testing.T) {
+	d := &Daemon{VmList: NewVmList()}
+	d.VmList.Put(&hypervisor.Vm{Id: "vm-abc123"})
+	d.VmList.Put(&hypervisor.Vm{Id: "vm-abd456"})
+
+	if vm, err := d.ResolveVm("abc"); err != nil || vm.Id != "vm-abc123" {
+		t.Errorf("failed to resolve abc: %v", err)
+	}
+	if vm, err := d.ResolveVm("vm-abd456"); err != nil || vm.Id != "vm-abd456" {
+		t.Errorf("failed to resolve vm-abd456: %v", err)
+	}
+	if _, err := d.ResolveVm("vm-ab"); err == nil {
+		t.Error("expect the prefix to be ambiguous")
+	}
+}
+
+func TestTtyResizeAmbiguous(t *testing.T) {
+	d := &Daemon{PodList: NewPodList(), VmList: NewVmList()}
+	d.PodList.Put(newTestPod("pod-abc123"))
+	d.PodList.Put(newTestPod("pod-abd456"))
+	d.VmList.Put(&hypervisor.Vm{Id: "vm-abc123"})
+	d.VmList.Put(&hypervisor.Vm{Id: "vm-abd456"})
+
+	for _, ref := range []string{"pod-ab", "container-pod-ab", "vm-ab"} {
+		if err := d.TtyResize(ref, "tag", 24, 80); !isAmbiguous(err) {
+			t.Errorf("expect %s to be ambiguous, got %v", ref, err)
+		}
+	}
+}
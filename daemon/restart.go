@@ -0,0 +1,168 @@
+package daemon
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/hyperhq/runv/hypervisor"
+	"github.com/hyperhq/runv/hypervisor/types"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	RestartPolicyNever     = "never"
+	RestartPolicyOnFailure = "onFailure"
+	RestartPolicyAlways    = "always"
+
+	// the delay before the first restart, doubled for every consecutive
+	// restart until it reaches restartBackoffMax
+	restartBackoffBase = 10 * time.Second
+	restartBackoffMax  = 5 * time.Minute
+	// a pod which has been running longer than this before it exits is
+	// considered healthy again, and the backoff starts over
+	restartBackoffReset = 2 * restartBackoffMax
+)
+
+// containerRestartPolicy returns the restart policy applied to the idx-th
+// container of the pod. The policy of the container comes first, the one of
+// the pod is the default of the containers without their own.
+func (p *Pod) containerRestartPolicy(idx int) string {
+	if p.spec == nil {
+		return RestartPolicyNever
+	}
+	if idx < len(p.spec.Containers) && p.spec.Containers[idx].RestartPolicy != "" {
+		return p.spec.Containers[idx].RestartPolicy
+	}
+	if p.spec.RestartPolicy != "" {
+		return p.spec.RestartPolicy
+	}
+	return RestartPolicyNever
+}
+
+// restartingContainers checks the exit status of every container against
+// its restart policy, and returns the indexes of the containers asking for
+// a restart. The whole pod is restarted if there is any.
+func (p *Pod) restartingContainers() []int {
+	var restarting []int
+	if p.status.Autoremove || p.stopped {
+		return restarting
+	}
+	for idx, c := range p.status.Containers {
+		switch p.containerRestartPolicy(idx) {
+		case RestartPolicyAlways:
+			restarting = append(restarting, idx)
+		case RestartPolicyOnFailure:
+			if p.unhealthy || p.status.Status == types.S_POD_FAILED ||
+				c.Status == types.S_POD_FAILED || c.ExitCode != 0 {
+				restarting = append(restarting, idx)
+			}
+		}
+	}
+	return restarting
+}
+
+func (p *Pod) needRestart() bool {
+	return len(p.restartingContainers()) > 0
+}
+
+// nextRestartDelay returns the exponential backoff delay before the next
+// restart of the pod, the caller must hold the RWMutex of the pod.
+func (p *Pod) nextRestartDelay() time.Duration {
+	delay := p.restartDelay * 2
+	if started, err := time.Parse(time.RFC3339, p.status.StartedAt); err == nil &&
+		time.Since(started) > restartBackoffReset {
+		delay = 0
+	}
+	if delay < restartBackoffBase {
+		delay = restartBackoffBase
+	}
+	if delay > restartBackoffMax {
+		delay = restartBackoffMax
+	}
+	p.restartDelay = delay
+	return delay
+}
+
+// cancelRestart stops the pending restart of the pod, if any.
+func (p *Pod) cancelRestart() {
+	p.Lock()
+	defer p.Unlock()
+	p.stopRestartTimer()
+}
+
+// The caller must hold the RWMutex of the pod
+func (p *Pod) stopRestartTimer() {
+	if p.restartTimer != nil {
+		p.restartTimer.Stop()
+		p.restartTimer = nil
+	}
+}
+
+// setStopped records whether the pod is stopped by the user, the restart
+// policies don't apply to a stopped pod until it is started again. The
+// pending restart is dropped either way.
+func (p *Pod) setStopped(stopped bool) {
+	p.Lock()
+	defer p.Unlock()
+	p.stopped = stopped
+	p.stopRestartTimer()
+}
+
+// ScheduleRestart restarts the pod after the backoff delay if its restart
+// policy asks so, it returns false if the pod will not be restarted.
+func (daemon *Daemon) ScheduleRestart(mypod *hypervisor.PodStatus) bool {
+	p, ok := daemon.PodList.Get(mypod.Id)
+	if !ok {
+		return false
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	if !p.needRestart() {
+		return false
+	}
+
+	p.stopRestartTimer()
+	delay := p.nextRestartDelay()
+	glog.Infof("Pod %s will be restarted in %v", mypod.Id, delay)
+	p.restartTimer = time.AfterFunc(delay, func() {
+		if err := daemon.RestartPod(mypod); err != nil {
+			glog.Errorf("Failed to restart pod %s: %s", mypod.Id, err.Error())
+		}
+	})
+	return true
+}
+
+func restartCountKey(podId, container string) string {
+	return fmt.Sprintf("restart-%s-%s", podId, container)
+}
+
+func (daemon *Daemon) GetRestartCount(podId, container string) int {
+	data, err := daemon.db.Get([]byte(restartCountKey(podId, container)), nil)
+	if err != nil {
+		return 0
+	}
+	count, err := strconv.Atoi(string(data))
+	if err != nil {
+		return 0
+	}
+	return count
+}
+
+func (daemon *Daemon) IncreaseRestartCount(podId, container string) error {
+	count := daemon.GetRestartCount(podId, container) + 1
+	return daemon.db.Put([]byte(restartCountKey(podId, container)), []byte(strconv.Itoa(count)), nil)
+}
+
+func (daemon *Daemon) DeleteRestartCount(podId string) error {
+	iter := daemon.db.NewIterator(util.BytesPrefix([]byte(fmt.Sprintf("restart-%s-", podId))), nil)
+	for iter.Next() {
+		if err := daemon.db.Delete(iter.Key(), nil); err != nil {
+			return err
+		}
+	}
+	iter.Release()
+	return iter.Error()
+}
@@ -0,0 +1,189 @@
+package daemon
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperhq/hyper/daemon/events"
+	"github.com/hyperhq/runv/hypervisor"
+	"github.com/hyperhq/runv/hypervisor/pod"
+	"github.com/hyperhq/runv/hypervisor/types"
+)
+
+func newRestartTestPod(podPolicy string, policies []string, codes []int) *Pod {
+	p := &Pod{
+		status: &hypervisor.PodStatus{
+			RestartPolicy: podPolicy,
+			Status:        types.S_POD_SUCCEEDED,
+		},
+		spec: &pod.UserPod{RestartPolicy: podPolicy},
+	}
+	for i, policy := range policies {
+		status := uint32(types.S_POD_SUCCEEDED)
+		if codes[i] != 0 {
+			status = types.S_POD_FAILED
+		}
+		p.spec.Containers = append(p.spec.Containers, pod.UserContainer{RestartPolicy: policy})
+		p.status.Containers = append(p.status.Containers, &hypervisor.Container{
+			Status:   status,
+			ExitCode: codes[i],
+		})
+	}
+	return p
+}
+
+func TestRestartingContainers(t *testing.T) {
+	cases := []struct {
+		podPolicy string
+		policies  []string
+		codes     []int
+		expect    []int
+	}{
+		{"", []string{""}, []int{1}, nil},
+		{"", []string{"never"}, []int{1}, nil},
+		{"", []string{"always"}, []int{0}, []int{0}},
+		{"", []string{"onFailure"}, []int{0}, nil},
+		{"", []string{"onFailure"}, []int{2}, []int{0}},
+		{"", []string{"never", "onFailure"}, []int{0, 1}, []int{1}},
+		{"", []string{"always", "onFailure", "onFailure"}, []int{0, 0, 1}, []int{0, 2}},
+		// the policy of the container comes before the one of the pod
+		{"never", []string{"always"}, []int{0}, []int{0}},
+		{"onFailure", []string{"never"}, []int{3}, nil},
+		{"always", []string{"never", ""}, []int{0, 0}, []int{1}},
+		{"onFailure", []string{"", ""}, []int{0, 1}, []int{1}},
+	}
+
+	for i, c := range cases {
+		p := newRestartTestPod(c.podPolicy, c.policies, c.codes)
+		if r := p.restartingContainers(); !reflect.DeepEqual(r, c.expect) {
+			t.Errorf("case %d: expect restarting containers %v, got %v", i, c.expect, r)
+		}
+		if p.needRestart() != (len(c.expect) > 0) {
+			t.Errorf("case %d: needRestart doesn't match the restarting containers", i)
+		}
+	}
+
+	p := newRestartTestPod("", []string{"always"}, []int{0})
+	p.status.Autoremove = true
+	if p.needRestart() {
+		t.Errorf("autoremove pod should not be restarted")
+	}
+
+	p = newRestartTestPod("", []string{"always"}, []int{0})
+	p.setStopped(true)
+	if p.needRestart() {
+		t.Errorf("the pod stopped by the user should not be restarted")
+	}
+	p.setStopped(false)
+	if !p.needRestart() {
+		t.Errorf("the restart policy should apply again once the pod is started")
+	}
+}
+
+func TestScheduleRestart(t *testing.T) {
+	d := &Daemon{PodList: NewPodList()}
+	p := newRestartTestPod("", []string{"always"}, []int{0})
+	p.id = "pod-restart"
+	p.status.Id = p.id
+	d.PodList.Put(p)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !d.ScheduleRestart(p.status) {
+				t.Error("expect the pod to be restarted")
+			}
+		}()
+	}
+	wg.Wait()
+
+	p.setStopped(true)
+	p.RLock()
+	defer p.RUnlock()
+	if p.restartTimer != nil {
+		t.Error("the pending restart is not dropped when the pod is stopped")
+	}
+}
+
+func TestCleanRestartingPod(t *testing.T) {
+	d, cleanup := newTestDBDaemon(t)
+	defer cleanup()
+	d.events = events.New()
+
+	p := newTestPod("pod-backoff")
+	p.spec = &pod.UserPod{}
+	p.status.Type = "kubernetes"
+	p.status.Status = types.S_POD_FAILED
+	fired := make(chan struct{})
+	p.restartTimer = time.AfterFunc(100*time.Millisecond, func() { close(fired) })
+	d.PodList.Put(p)
+
+	if _, _, err := d.CleanPodWithLock(p.id); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-fired:
+		t.Fatal("the removed pod is restarted")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestNextRestartDelay(t *testing.T) {
+	p := newRestartTestPod("always", []string{""}, []int{0})
+	p.status.StartedAt = time.Now().Format(time.RFC3339)
+
+	expect := restartBackoffBase
+	for i := 0; i < 10; i++ {
+		if d := p.nextRestartDelay(); d != expect {
+			t.Fatalf("restart %d: expect delay %v, got %v", i, expect, d)
+		}
+		expect *= 2
+		if expect > restartBackoffMax {
+			expect = restartBackoffMax
+		}
+	}
+
+	p.status.StartedAt = time.Now().Add(-2 * restartBackoffReset).Format(time.RFC3339)
+	if d := p.nextRestartDelay(); d != restartBackoffBase {
+		t.Fatalf("expect delay reset to %v after a long run, got %v", restartBackoffBase, d)
+	}
+}
+
+func TestFakePodRestartPolicy(t *testing.T) {
+	fd, cleanup := newFakeDaemon(t)
+	defer cleanup()
+
+	p := fd.createPod("restart", `, "restartPolicy": "onFailure"`)
+	fd.startPod(p)
+	_, vmId := fd.status(p)
+	c := p.status.Containers[0]
+
+	if err := fd.driver.FinishContainer(vmId, c.Id, 1); err != nil {
+		t.Fatal(err)
+	}
+	// the pod is restarted in a new VM after the backoff delay
+	fd.waitFor(restartBackoffBase+10*time.Second, "the failed pod to be restarted", func() bool {
+		status, vm := fd.status(p)
+		return status == types.S_POD_RUNNING && vm != "" && vm != vmId
+	})
+	if count := fd.GetRestartCount(p.id, c.Name); count != 1 {
+		t.Errorf("expect the container to be restarted once, got %d", count)
+	}
+
+	// the pod succeeded is not restarted
+	_, vmId = fd.status(p)
+	if err := fd.driver.FinishContainer(vmId, c.Id, 0); err != nil {
+		t.Fatal(err)
+	}
+	fd.waitStatus(p, types.S_POD_SUCCEEDED)
+	p.RLock()
+	restart := p.needRestart()
+	p.RUnlock()
+	if restart {
+		t.Error("the succeeded pod is going to be restarted")
+	}
+}
@@ -0,0 +1,216 @@
+package daemon
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/hyperhq/hyper/servicediscovery"
+	"github.com/hyperhq/runv/hypervisor"
+	"github.com/hyperhq/runv/hypervisor/pod"
+	"github.com/hyperhq/runv/hypervisor/types"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// lostExitCode is the exit code of the container which exited when hyperd
+// was down, the real one is not kept by the VM.
+const lostExitCode = 255
+
+// Restore recovers the pods stored in the db after hyperd restarts. The
+// pods whose VMs are kept running by the previous hyperd are reconnected,
+// the pods which can't be recovered are kept as failed instead of being
+// dropped, so that the user could inspect and remove them.
+func (daemon *Daemon) Restore() error {
+	if daemon.GetPodNum() == 0 {
+		return nil
+	}
+
+	podList := map[string][]byte{}
+
+	iter := daemon.db.NewIterator(util.BytesPrefix([]byte("pod-")), nil)
+	for iter.Next() {
+		key := string(iter.Key())
+		if strings.HasPrefix(key, "pod-container-") {
+			continue
+		}
+		glog.V(1).Infof("Get the pod item, pod is %s!", key)
+		podList[key[len("pod-"):]] = append([]byte{}, iter.Value()...)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	for podId, spec := range podList {
+		p, err := daemon.loadPod(podId, spec)
+		if err != nil {
+			daemon.PodList.Put(p)
+			daemon.failRestoredPod(p, err)
+			continue
+		}
+		daemon.restorePod(p)
+	}
+
+	return nil
+}
+
+// loadPod creates the pod from its spec and its containers, the returned
+// pod is always usable, it is a placeholder of the broken one on error.
+func (daemon *Daemon) loadPod(podId string, spec []byte) (*Pod, error) {
+	p, err := NewPod(spec, podId, daemon, false)
+	if err != nil {
+		return brokenPod(podId), err
+	}
+
+	if err = p.DoCreate(daemon); err != nil {
+		return p, err
+	}
+	daemon.loadPodExit(p)
+
+	if err = daemon.WritePodAndContainers(p.id); err != nil {
+		glog.Warningf("Failed to save the containers of pod %s: %s", p.id, err.Error())
+	}
+	return p, nil
+}
+
+// brokenPod is the placeholder of the pod whose spec can't be parsed.
+func brokenPod(podId string) *Pod {
+	return &Pod{
+		id:      podId,
+		spec:    &pod.UserPod{Name: podId},
+		ttyList: make(map[string]*hypervisor.TtyIO),
+		status: &hypervisor.PodStatus{
+			Id:           podId,
+			Name:         podId,
+			ResourcePath: filepath.Join(DefaultResourcePath, podId),
+		},
+	}
+}
+
+// restorePod adds the pod to the pod list and reconnects it to the VM it
+// was running in, if any. The status is rebuilt from the VM, the log
+// copiers, the probes and the services of the running pod are restored, and
+// the pod finished when hyperd was down is stopped.
+func (daemon *Daemon) restorePod(p *Pod) {
+	daemon.PodList.Put(p)
+
+	vmId, err := daemon.DbGetVmByPod(p.id)
+	if err != nil {
+		glog.V(1).Infof("Pod %s is not running: %s", p.id, err.Error())
+		return
+	}
+
+	p.opLock.Lock()
+	running, err := daemon.reconnectPod(p, vmId)
+	vm := p.vm
+	p.opLock.Unlock()
+	if err != nil {
+		daemon.failRestoredPod(p, fmt.Errorf("Failed to reconnect to vm %s: %s", vmId, err.Error()))
+		return
+	}
+
+	if !running {
+		// the VM is stopped as if the pod finished now, the handler of
+		// the VM saves the exit status and restarts the pod if its
+		// restart policy says so
+		glog.Infof("Pod %s finished when hyperd was down, stop vm %s", p.id, vmId)
+		daemon.LogPodEvent(p, "finish")
+		vm.StopPod(p.status, "yes")
+		return
+	}
+
+	glog.Infof("Pod %s is restored in vm %s", p.id, vmId)
+	daemon.LogPodEvent(p, "restore")
+}
+
+// reconnectPod associates the pod with its VM and rebuilds the status of
+// the containers from the VM, the caller must hold the lock of the pod. It
+// returns whether any container of the pod is still running.
+func (daemon *Daemon) reconnectPod(p *Pod, vmId string) (bool, error) {
+	if err := p.AssociateVm(daemon, vmId); err != nil {
+		return false, err
+	}
+	p.vm.Status = types.S_VM_ASSOCIATED
+
+	// the containers are probed with signal 0, the exit codes of the ones
+	// exited when hyperd was down are lost
+	alive := make([]bool, len(p.status.Containers))
+	running := false
+	for i, c := range p.status.Containers {
+		if err := p.vm.KillContainer(c.Id, 0); err != nil {
+			glog.V(1).Infof("Container %s of pod %s is not running: %s", c.Id, p.id, err.Error())
+			continue
+		}
+		alive[i], running = true, true
+	}
+	p.updateStatus(func() {
+		for i, c := range p.status.Containers {
+			if alive[i] {
+				c.Status = types.S_POD_RUNNING
+			} else if c.Status == types.S_POD_RUNNING || c.Status == types.S_POD_CREATED {
+				c.Status = types.S_POD_FAILED
+				c.ExitCode = lostExitCode
+			}
+		}
+		if running {
+			p.status.Status = types.S_POD_RUNNING
+		} else {
+			p.status.Status = types.S_POD_FAILED
+		}
+	})
+	if !running {
+		return false, nil
+	}
+
+	// the sessions attached by the clients of the previous hyperd are gone
+	// with their connections
+	p.Lock()
+	p.ttyList = make(map[string]*hypervisor.TtyIO)
+	p.Unlock()
+
+	if err := p.startLogging(daemon); err != nil {
+		glog.Warningf("Failed to restart the logging of pod %s: %s", p.id, err.Error())
+	}
+	daemon.startProbes(p)
+	daemon.restoreServices(p)
+	return true, nil
+}
+
+// restoreServices reads the services applied to the service discovery
+// container back from the VM, they may be changed after the pod is created.
+func (daemon *Daemon) restoreServices(p *Pod) {
+	if p.status.Type != "service-discovery" || len(p.status.Containers) == 0 {
+		return
+	}
+
+	services, err := servicediscovery.GetServices(p.vm, p.status.Containers[0].Id)
+	if err != nil {
+		glog.Warningf("Failed to restore the services of pod %s: %s", p.id, err.Error())
+		return
+	}
+	p.Lock()
+	p.spec.Services = services
+	p.Unlock()
+}
+
+// failRestoredPod marks the pod which can't be recovered as failed, the
+// VM info of the pod is removed.
+func (daemon *Daemon) failRestoredPod(p *Pod, cause error) {
+	glog.Warningf("Pod %s can not be restored, mark it as failed: %s", p.id, cause.Error())
+
+	p.vm = nil
+	p.updateStatus(func() {
+		p.status.Vm = ""
+		p.status.Status = types.S_POD_FAILED
+		p.status.SetContainerStatus(types.S_POD_FAILED)
+	})
+	p.notifyFinished()
+
+	if _, err := daemon.DbGetVmByPod(p.id); err == nil {
+		if err := daemon.DeleteVmByPod(p.id); err != nil {
+			glog.Warningf("Failed to delete the vm info of pod %s: %s", p.id, err.Error())
+		}
+	}
+	daemon.LogPodEvent(p, "fail")
+}
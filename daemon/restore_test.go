@@ -0,0 +1,165 @@
+package daemon
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/hyperhq/hyper/daemon/events"
+	"github.com/hyperhq/hyper/servicediscovery"
+	"github.com/hyperhq/runv/hypervisor/pod"
+	"github.com/hyperhq/runv/hypervisor/types"
+)
+
+func TestRestoreBrokenPod(t *testing.T) {
+	d, cleanup := newTestDBDaemon(t)
+	defer cleanup()
+	d.events = events.New()
+
+	if err := d.db.Put([]byte("pod-pod-broken"), []byte("{"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Restore(); err != nil {
+		t.Fatal(err)
+	}
+
+	p, ok := d.PodList.Get("pod-broken")
+	if !ok {
+		t.Fatal("the broken pod is dropped")
+	}
+	if p.status.Status != types.S_POD_FAILED {
+		t.Errorf("expect the broken pod to be failed, got %d", p.status.Status)
+	}
+	if _, err := d.db.Get([]byte("pod-pod-broken"), nil); err != nil {
+		t.Error("the spec of the broken pod is deleted")
+	}
+}
+
+func TestRestorePod(t *testing.T) {
+	d, cleanup := newTestDBDaemon(t)
+	defer cleanup()
+	d.events = events.New()
+
+	// the VM of the pod is gone with the previous hyperd
+	lost := newTestPod("pod-lost")
+	lost.spec = &pod.UserPod{}
+	if err := d.UpdateVmByPod(lost.id, "vm-lost"); err != nil {
+		t.Fatal(err)
+	}
+	d.restorePod(lost)
+
+	if p, ok := d.PodList.Get("pod-lost"); !ok || p.status.Status != types.S_POD_FAILED || p.vm != nil {
+		t.Error("expect the pod whose VM is lost to be failed")
+	}
+	if _, err := d.DbGetVmByPod(lost.id); err == nil {
+		t.Error("the VM of the failed pod is not removed")
+	}
+
+	created := newTestPod("pod-created")
+	created.spec = &pod.UserPod{}
+	created.status.Status = types.S_POD_CREATED
+	d.restorePod(created)
+
+	if p, ok := d.PodList.Get("pod-created"); !ok || p.status.Status != types.S_POD_CREATED {
+		t.Error("expect the pod not running to be restored as it is")
+	}
+}
+
+func TestFakeRestoreRunningPod(t *testing.T) {
+	fd, cleanup := newFakeDaemon(t)
+	defer cleanup()
+
+	p := fd.createPod("restore", `, "log": {"type": "json-file"}`)
+	fd.startPod(p)
+	_, vmId := fd.status(p)
+	cid := p.status.Containers[0].Id
+
+	fd.restart(nil)
+	restored, ok := fd.PodList.Get(p.id)
+	if !ok {
+		t.Fatal("the pod is not restored")
+	}
+	if status, vm := fd.status(restored); status != types.S_POD_RUNNING || vm != vmId {
+		t.Fatalf("expect the pod to be running in VM %s, got %d in %q", vmId, status, vm)
+	}
+	if c := restored.status.Containers[0]; c.Status != types.S_POD_RUNNING || c.Logs.Copier == nil || c.Logs.LogPath == "" {
+		t.Errorf("expect the container to be running and logged, got %#v", c)
+	}
+
+	// the restored pod is handled by the new daemon
+	if err := fd.driver.FinishContainer(vmId, cid, 3); err != nil {
+		t.Fatal(err)
+	}
+	fd.waitStatus(restored, types.S_POD_FAILED)
+	if result := restored.exitStatus(); result.Containers[0].ExitCode != 3 {
+		t.Errorf("unexpected exit status %#v", result)
+	}
+}
+
+func TestFakeRestoreFinishedPod(t *testing.T) {
+	fd, cleanup := newFakeDaemon(t)
+	defer cleanup()
+
+	p := fd.createPod("finished", "")
+	fd.startPod(p)
+	_, vmId := fd.status(p)
+	cid := p.status.Containers[0].Id
+
+	fd.restart(func() {
+		if err := fd.driver.FinishContainer(vmId, cid, 0); err != nil {
+			t.Fatal(err)
+		}
+	})
+	restored, ok := fd.PodList.Get(p.id)
+	if !ok {
+		t.Fatal("the pod is not restored")
+	}
+
+	// the status is rebuilt from the VM, and the VM is stopped
+	fd.waitFor(10*time.Second, "the VM of the finished pod to stop", func() bool {
+		status, vm := fd.status(restored)
+		return status == types.S_POD_FAILED && vm == ""
+	})
+	if result := restored.exitStatus(); result.Containers[0].ExitCode != lostExitCode {
+		t.Errorf("unexpected exit status %#v", result)
+	}
+	for _, id := range fd.driver.VmIds() {
+		if id == vmId {
+			t.Errorf("VM %s of the finished pod is still running", vmId)
+		}
+	}
+	if _, err := fd.DbGetVmByPod(p.id); err == nil {
+		t.Error("the VM of the finished pod is kept in the db")
+	}
+}
+
+func TestFakeRestoreServices(t *testing.T) {
+	fd, cleanup := newFakeDaemon(t)
+	defer cleanup()
+
+	p := fd.createPod("services", `, "services": [{"serviceip": "10.254.0.2", "serviceport": 80, "protocol": "TCP",
+		"hosts": [{"hostip": "192.168.123.2", "hostport": 8080}]}]`)
+	fd.startPod(p)
+	// the config in the service volume is not visible in the fake VM
+	config := path.Join(servicediscovery.ServiceVolume, servicediscovery.ServiceConfig)
+	if err := p.vm.WriteFile(p.status.Containers[0].Id, config, servicediscovery.GenerateServiceConfig(p.spec.Services)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fd.AddService(p.id, `[{"serviceip": "10.254.0.3", "serviceport": 80, "protocol": "TCP",
+		"hosts": [{"hostip": "192.168.123.3", "hostport": 8080}]}]`); err != nil {
+		t.Fatal(err)
+	}
+
+	fd.restart(nil)
+	restored, ok := fd.PodList.Get(p.id)
+	if !ok {
+		t.Fatal("the pod is not restored")
+	}
+	services, err := fd.GetServices(p.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 2 || len(restored.spec.Services) != 2 || restored.spec.Services[1].ServiceIP != "10.254.0.3" {
+		t.Errorf("expect the services added at runtime to be restored, got %v and %v", services, restored.spec.Services)
+	}
+}
@@ -13,14 +13,21 @@ import (
 )
 
 func (daemon *Daemon) CleanPod(podId string) (int, string, error) {
-	daemon.PodList.Lock()
-	glog.V(2).Infof("lock PodList")
-	defer glog.V(2).Infof("unlock PodList")
-	defer daemon.PodList.Unlock()
+	pod, err := daemon.ResolvePod(podId)
+	if err != nil {
+		return -1, "", err
+	}
+	podId = pod.id
+
+	pod.opLock.Lock()
+	glog.V(2).Infof("lock pod %s", podId)
+	defer glog.V(2).Infof("unlock pod %s", podId)
+	defer pod.opLock.Unlock()
 
 	return daemon.CleanPodWithLock(podId)
 }
 
+// CleanPodWithLock removes the pod, the caller must hold the lock of the pod.
 func (daemon *Daemon) CleanPodWithLock(podId string) (int, string, error) {
 	var (
 		code  = 0
@@ -34,11 +41,17 @@ func (daemon *Daemon) CleanPodWithLock(podId string) (int, string, error) {
 	if !ok {
 		return -1, "", fmt.Errorf("Can not find that Pod(%s)", podId)
 	}
+	// the pod waiting for the restart backoff is not restarted once removed
+	pod.cancelRestart()
 
 	if pod.status.Status == types.S_POD_RUNNING {
-		code, cause, err = daemon.StopPodWithLock(podId, "yes")
+		// the VM is kept and returned to the pool if it is not full
+		vm := pod.vm
+		code, cause, err = daemon.StopPodWithLock(podId, "no")
 		if err != nil {
 			glog.Errorf("failed to stop pod %s", podId)
+		} else if vm != nil && code != types.E_VM_SHUTDOWN {
+			daemon.recycleVm(vm.Id)
 		}
 	}
 
@@ -48,6 +61,10 @@ func (daemon *Daemon) CleanPodWithLock(podId string) (int, string, error) {
 		daemon.CleanUpContainer(pod.status)
 	}
 	daemon.DeleteVolumeId(podId)
+	daemon.DeleteRestartCount(podId)
+	daemon.DeletePodExit(podId)
+	pod.notifyFinished()
+	daemon.LogPodEvent(pod, "remove")
 	code = types.E_OK
 
 	return code, cause, nil
@@ -56,7 +73,7 @@ func (daemon *Daemon) CleanPodWithLock(podId string) (int, string, error) {
 func (daemon *Daemon) CleanUpContainer(ps *hypervisor.PodStatus) {
 	for _, c := range ps.Containers {
 		glog.V(1).Infof("Ready to rm container: %s", c.Id)
-		if err := daemon.Daemon.ContainerRm(c.Id, &dockertypes.ContainerRmConfig{}); err != nil {
+		if err := daemon.engine.ContainerRm(c.Id, &dockertypes.ContainerRmConfig{}); err != nil {
 			glog.Warningf("Error to rm container: %s", err.Error())
 		}
 	}
@@ -5,11 +5,14 @@ import (
 	"io"
 	"os"
 	"strings"
+	"syscall"
 
 	"github.com/docker/engine-api/types"
 	"github.com/golang/glog"
+	"github.com/hyperhq/hyper/daemon/events"
 	"github.com/hyperhq/hyper/engine"
 	"github.com/hyperhq/hyper/lib/sysinfo"
+	hypertypes "github.com/hyperhq/hyper/types"
 	"github.com/hyperhq/hyper/utils"
 	"github.com/hyperhq/runv/hypervisor/pod"
 )
@@ -41,7 +44,7 @@ func (daemon *Daemon) CmdImages(args, filter string, all bool) (*engine.Env, err
 }
 
 func (daemon *Daemon) CmdAuthenticateToRegistry(config *types.AuthConfig) (string, error) {
-	return daemon.Daemon.AuthenticateToRegistry(config)
+	return daemon.AuthenticateToRegistry(config)
 }
 
 func (daemon *Daemon) CmdAttach(stdin io.ReadCloser, stdout io.WriteCloser, key, id, tag string) error {
@@ -61,6 +64,10 @@ func (daemon *Daemon) CmdCommitImage(name string, cfg *types.ContainerCommitConf
 	return v, nil
 }
 
+func (daemon *Daemon) CmdCommitPod(podId, prefix string, cfg *types.ContainerCommitConfig) (interface{}, error) {
+	return daemon.CommitPod(podId, prefix, cfg)
+}
+
 func (daemon *Daemon) CmdCreateContainer(params types.ContainerCreateConfig) (*engine.Env, error) {
 	res, err := daemon.Daemon.ContainerCreate(params)
 	if err != nil {
@@ -84,7 +91,7 @@ func (daemon *Daemon) CmdExitCode(container, tag string) (int, error) {
 }
 
 func (daemon *Daemon) CmdSystemInfo() (*engine.Env, error) {
-	sys, err := daemon.Daemon.SystemInfo()
+	sys, err := daemon.SystemInfo()
 	if err != nil {
 		return nil, err
 	}
@@ -111,6 +118,13 @@ func (daemon *Daemon) CmdSystemInfo() (*engine.Env, error) {
 	}
 	v.SetInt64("MemTotal", int64(meminfo.MemTotal))
 	v.SetInt64("Pods", daemon.GetPodNum())
+	policy, pool, templates := daemon.VmCacheInfo()
+	v.Set("VmCachePolicy", policy)
+	if pool != nil {
+		v.SetJson("VmPool", pool)
+	}
+	v.SetJson("VmTemplates", templates)
+	v.SetJson("Resource", daemon.ResourceInfo())
 	v.Set("Operating System", osinfo.PrettyName)
 	if hostname, err := os.Hostname(); err == nil {
 		v.SetJson("Name", hostname)
@@ -128,6 +142,22 @@ func (daemon *Daemon) CmdSystemVersion() *engine.Env {
 	return v
 }
 
+func (daemon *Daemon) CmdSubscribeToEvents(since, sinceNano, until, untilNano int64, ef *events.Filter) ([]hypertypes.Event, chan interface{}) {
+	return daemon.SubscribeToEvents(since, sinceNano, until, untilNano, ef)
+}
+
+func (daemon *Daemon) CmdUnsubscribeFromEvents(listener chan interface{}) {
+	daemon.UnsubscribeFromEvents(listener)
+}
+
+func (daemon *Daemon) CmdMetrics(w io.Writer) error {
+	return daemon.WriteMetrics(w)
+}
+
+func (daemon *Daemon) CmdSystemPrune(dryRun bool) (interface{}, error) {
+	return daemon.Prune(dryRun)
+}
+
 func (daemon *Daemon) CmdGetPodInfo(podName string) (interface{}, error) {
 	return daemon.GetPodInfo(podName)
 }
@@ -140,8 +170,8 @@ func (daemon *Daemon) CmdGetContainerInfo(name string) (interface{}, error) {
 	return daemon.GetContainerInfo(name)
 }
 
-func (daemon *Daemon) CmdList(item, podId, vmId string, auxiliary bool) (*engine.Env, error) {
-	list, err := daemon.List(item, podId, vmId, auxiliary)
+func (daemon *Daemon) CmdList(item, podId, vmId, selector string, auxiliary bool) (*engine.Env, error) {
+	list, err := daemon.List(item, podId, vmId, selector, auxiliary)
 	if err != nil {
 		return nil, err
 	}
@@ -153,6 +183,14 @@ func (daemon *Daemon) CmdList(item, podId, vmId string, auxiliary bool) (*engine
 		v.SetList(key, value)
 	}
 
+	if item != "vm" {
+		labels, err := daemon.PodLabels(selector)
+		if err != nil {
+			return nil, err
+		}
+		v.SetJson("podLabels", labels)
+	}
+
 	return v, nil
 }
 
@@ -160,8 +198,8 @@ func (daemon *Daemon) CmdGetContainerLogs(container string, config *ContainerLog
 	return daemon.GetContainerLogs(container, config)
 }
 
-func (daemon *Daemon) CmdSetPodLabels(podId string, override bool, labels map[string]string) (*engine.Env, error) {
-	if err := daemon.SetPodLabels(podId, override, labels); err != nil {
+func (daemon *Daemon) CmdSetPodLabels(podId string, override bool, labels map[string]string, remove []string) (*engine.Env, error) {
+	if err := daemon.SetPodLabels(podId, override, labels, remove); err != nil {
 		return nil, err
 	}
 
@@ -173,6 +211,35 @@ func (daemon *Daemon) CmdSetPodLabels(podId string, override bool, labels map[st
 	return v, nil
 }
 
+func (daemon *Daemon) CmdSetPodAnnotations(podId string, override bool, annotations map[string]string, remove []string) (*engine.Env, error) {
+	if err := daemon.SetPodAnnotations(podId, override, annotations, remove); err != nil {
+		return nil, err
+	}
+
+	v := &engine.Env{}
+	v.Set("ID", podId)
+	v.SetInt("Code", 0)
+	v.Set("Cause", "")
+
+	return v, nil
+}
+
+func (daemon *Daemon) CmdUpdatePod(podId string, cpu, mem int) (*engine.Env, error) {
+	resource, err := daemon.UpdatePodResource(podId, cpu, mem)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &engine.Env{}
+	v.Set("ID", podId)
+	v.SetInt("Vcpu", resource.Vcpu)
+	v.SetInt("Memory", resource.Memory)
+	v.SetInt("Code", 0)
+	v.Set("Cause", "")
+
+	return v, nil
+}
+
 func (daemon *Daemon) CmdStartPod(stdin io.ReadCloser, stdout io.WriteCloser, podId, vmId, tag string) (*engine.Env, error) {
 	code, cause, err := daemon.StartPod(stdin, stdout, podId, vmId, tag)
 	if err != nil {
@@ -215,6 +282,34 @@ func (daemon *Daemon) CmdContainerRename(oldname, newname string) (*engine.Env,
 	return v, nil
 }
 
+func (daemon *Daemon) CmdWaitPod(podName string, timeout int) (interface{}, error) {
+	return daemon.WaitPod(podName, timeout)
+}
+
+func (daemon *Daemon) CmdWaitContainer(name string, timeout int) (interface{}, error) {
+	return daemon.WaitContainer(name, timeout)
+}
+
+func (daemon *Daemon) CmdContainerChanges(name string) (interface{}, error) {
+	return daemon.ContainerChanges(name)
+}
+
+func (daemon *Daemon) CmdContainerTop(name string) (interface{}, error) {
+	return daemon.ContainerTop(name)
+}
+
+func (daemon *Daemon) CmdContainerArchive(name, path string, w io.Writer) error {
+	return daemon.ContainerArchivePath(name, path, w)
+}
+
+func (daemon *Daemon) CmdContainerExtract(name, path string, content io.Reader) error {
+	return daemon.ContainerExtractToPath(name, path, content)
+}
+
+func (daemon *Daemon) CmdKillContainer(name string, sig syscall.Signal) error {
+	return daemon.KillContainer(name, sig)
+}
+
 func (daemon *Daemon) CmdCleanPod(podId string) (*engine.Env, error) {
 	code, cause, err := daemon.CleanPod(podId)
 	if err != nil {
@@ -243,8 +338,11 @@ func (daemon *Daemon) CmdImageDelete(name string, force, prune bool) (*engine.En
 	return v, nil
 }
 
-func (daemon *Daemon) CmdStopPod(podId, stopVm string) (*engine.Env, error) {
-	code, cause, err := daemon.StopPod(podId, stopVm)
+func (daemon *Daemon) CmdStopPod(podId, stopVm string, sig syscall.Signal, timeout int) (*engine.Env, error) {
+	if timeout < 0 {
+		timeout = DefaultStopTimeout
+	}
+	code, cause, err := daemon.GracefulStopPod(podId, stopVm, sig, timeout)
 	if err != nil {
 		return nil, err
 	}
@@ -278,7 +376,18 @@ func (daemon *Daemon) CmdCreateVm(cpu, mem int, async bool) (*engine.Env, error)
 }
 
 func (daemon *Daemon) CmdKillVm(vmId string) (*engine.Env, error) {
-	code, cause, err := daemon.KillVm(vmId)
+	vm, err := daemon.ResolveVm(vmId)
+	if err != nil {
+		return nil, err
+	}
+	vmId = vm.Id
+
+	// the idle VM is kept for the new pods if its pool is not full
+	kill := daemon.recycleVm
+	if daemon.vmInUse(vmId) {
+		kill = daemon.KillVm
+	}
+	code, cause, err := kill(vmId)
 	if err != nil {
 		return nil, err
 	}
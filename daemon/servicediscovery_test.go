@@ -0,0 +1,62 @@
+package daemon
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/hyperhq/hyper/daemon/fakedriver"
+	"github.com/hyperhq/hyper/servicediscovery"
+)
+
+func TestFakeServiceDiscovery(t *testing.T) {
+	fd, cleanup := newFakeDaemon(t)
+	defer cleanup()
+
+	p := fd.createPod("services", `, "services": [{"serviceip": "10.254.0.1", "serviceport": 80, "protocol": "TCP",
+		"hosts": [{"hostip": "192.168.0.2", "hostport": 8080}]}]`)
+	if p.status.Type != "service-discovery" || len(p.status.Containers) != 2 {
+		t.Fatalf("expect the service container to be added, got %d containers", len(p.status.Containers))
+	}
+	fd.startPod(p)
+	_, vmId := fd.status(p)
+
+	// the config is shared from the host in the service volume, which is
+	// not visible in the fake VM
+	config := path.Join(servicediscovery.ServiceVolume, servicediscovery.ServiceConfig)
+	service := p.status.Containers[0].Id
+	if err := p.vm.WriteFile(service, config, servicediscovery.GenerateServiceConfig(p.spec.Services)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fd.AddService(p.id, `[{"serviceip": "10.254.0.2", "serviceport": 81, "protocol": "TCP"}]`); err != nil {
+		t.Fatal(err)
+	}
+	data, err := fd.driver.ReadFile(vmId, service, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "frontend front1 10.254.0.2:81") {
+		t.Errorf("the service is not added to the config:\n%s", data)
+	}
+	services, err := fd.GetServices(p.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 2 || services[0].ServiceIP != "10.254.0.1" || len(services[0].Hosts) != 1 {
+		t.Errorf("unexpected services %v", services)
+	}
+
+	// the failure of setting up the service IP in the VM is reported
+	fd.driver.SetExecResult("ip addr add dev lo 10.254.0.3/32", fakedriver.ExecResult{Output: "RTNETLINK answers: File exists", ExitCode: 2})
+	if err := fd.AddService(p.id, `[{"serviceip": "10.254.0.3", "serviceport": 80, "protocol": "TCP"}]`); err == nil {
+		t.Fatal("expect the failure of the service IP to be reported")
+	}
+
+	if err := fd.DeleteService(p.id, `[{"serviceip": "10.254.0.2", "serviceport": 81}]`); err != nil {
+		t.Fatal(err)
+	}
+	if services, err = fd.GetServices(p.id); err != nil || len(services) != 1 {
+		t.Errorf("expect the service to be deleted, got %v, %v", services, err)
+	}
+}
@@ -0,0 +1,51 @@
+package daemon
+
+import (
+	"encoding/json"
+
+	apitypes "github.com/hyperhq/hyper/types"
+)
+
+// hyperSpec holds the fields of the pod spec which are handled by hyper
+// itself and not part of the runv UserPod. The containers are in the same
+// order as the UserPod ones.
+type hyperSpec struct {
+	Annotations map[string]string `json:"annotations"`
+	Containers  []struct {
+		Name           string               `json:"name"`
+		LivenessProbe  *apitypes.Probe      `json:"livenessProbe"`
+		ReadinessProbe *apitypes.Probe      `json:"readinessProbe"`
+		PreStop        *apitypes.ExecAction `json:"preStop"`
+	} `json:"containers"`
+}
+
+func parseHyperSpec(rawSpec []byte) (*hyperSpec, error) {
+	var spec hyperSpec
+
+	if err := json.Unmarshal(rawSpec, &spec); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// updatePodSpec modifies the pod spec stored in the db. It works on the raw
+// spec rather than the runv UserPod, so that the fields handled by hyper
+// itself are kept.
+func (daemon *Daemon) updatePodSpec(podId string, update func(spec map[string]interface{})) error {
+	data, err := daemon.GetPodByName(podId)
+	if err != nil {
+		return err
+	}
+
+	spec := make(map[string]interface{})
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return err
+	}
+
+	update(spec)
+
+	if data, err = json.Marshal(spec); err != nil {
+		return err
+	}
+	return daemon.WritePodToDB(podId, data)
+}
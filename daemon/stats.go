@@ -0,0 +1,71 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperhq/hyper/types"
+	runvtypes "github.com/hyperhq/runv/hypervisor/types"
+)
+
+// GetPodStats returns the resource usage of the containers in the pod.
+func (daemon *Daemon) GetPodStats(podId string) (*types.PodStats, error) {
+	pod, err := daemon.ResolvePod(podId)
+	if err != nil {
+		return nil, err
+	}
+
+	pod.RLock()
+	vm, status := pod.vm, pod.status.Status
+	pod.RUnlock()
+	if vm == nil || status != runvtypes.S_POD_RUNNING {
+		return nil, fmt.Errorf("Can not get pod stats for non-running pod (%s)", podId)
+	}
+
+	response := vm.Stats()
+	if response == nil || response.Data == nil {
+		return nil, fmt.Errorf("Stats for pod %s is nil", podId)
+	}
+
+	pod.RLock()
+	defer pod.RUnlock()
+	return podStats(pod, response.Data)
+}
+
+// podStats converts the stats reported by runv to the typed ones by their
+// json. The containers are named, and given the network of the pod. The
+// caller must hold the RWMutex of the pod.
+func podStats(p *Pod, data interface{}) (*types.PodStats, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats struct {
+		types.PodStats
+		Network types.NetworkStats `json:"network"`
+	}
+	if err := json.Unmarshal(raw, &stats); err != nil {
+		return nil, fmt.Errorf("Invalid stats of pod %s: %s", p.id, err.Error())
+	}
+
+	names := make(map[string]string)
+	for _, c := range p.status.Containers {
+		names[c.Id] = strings.TrimPrefix(c.Name, "/")
+	}
+
+	result := &stats.PodStats
+	result.PodID, result.PodName = p.id, p.status.Name
+	for i := range result.Containers {
+		c := &result.Containers[i]
+		c.Name = names[c.ContainerID]
+		if c.Timestamp.IsZero() {
+			c.Timestamp = result.Timestamp
+		}
+		if len(c.Network.Interfaces) == 0 {
+			c.Network = stats.Network
+		}
+	}
+	return result, nil
+}
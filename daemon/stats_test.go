@@ -0,0 +1,54 @@
+package daemon
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestPodStats(t *testing.T) {
+	p := newTestPod("pod-stats")
+
+	// the stats of runv, the pod network is not in the containers
+	var data interface{}
+	if err := json.Unmarshal([]byte(`{
+		"timestamp": "2016-05-01T10:00:00Z",
+		"network": {"interfaces": [{"name": "eth0", "rxBytes": 100, "txBytes": 200}]},
+		"containersStats": [{
+			"containerId": "container-pod-stats",
+			"cpu": {"usage": {"total": 3000000000}},
+			"memory": {"usage": 1048576},
+			"blkio": {"ioServiceBytesRecursive": [
+				{"major": 8, "minor": 0, "stat": {"Read": 10, "Write": 20}},
+				{"major": 8, "minor": 16, "stat": {"Read": 1, "Write": 2}}
+			]}
+		}]
+	}`), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := podStats(p, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.PodID != "pod-stats" || stats.PodName != "name-pod-stats" || len(stats.Containers) != 1 {
+		t.Fatalf("unexpected stats %v", stats)
+	}
+
+	c := stats.Containers[0]
+	if c.Name != "c-pod-stats" {
+		t.Errorf("expect the container to be named, got %q", c.Name)
+	}
+	if !c.Timestamp.Equal(time.Date(2016, 5, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("expect the timestamp of the pod, got %v", c.Timestamp)
+	}
+	if c.Cpu.Usage.Total != 3000000000 || c.Memory.Usage != 1048576 {
+		t.Errorf("unexpected usage %v", c)
+	}
+	if read, write := c.Blkio.Bytes(); read != 11 || write != 22 {
+		t.Errorf("unexpected block I/O %d/%d", read, write)
+	}
+	if rx, tx := c.Network.Bytes(); rx != 100 || tx != 200 {
+		t.Errorf("expect the network of the pod, got %d/%d", rx, tx)
+	}
+}
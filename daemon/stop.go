@@ -2,10 +2,24 @@ package daemon
 
 import (
 	"fmt"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
 	"github.com/golang/glog"
+	"github.com/hyperhq/runv/hypervisor"
 	"github.com/hyperhq/runv/hypervisor/types"
 )
 
+// DefaultStopTimeout is the grace period in seconds given to the containers
+// to exit after the stop signal, before the VM is torn down.
+const DefaultStopTimeout = 10
+
+// killTimeout is how long the containers killed at the end of the grace
+// period are waited for to report their exit codes.
+const killTimeout = 5 * time.Second
+
 func (daemon *Daemon) PodStopped(podId string) {
 	// find the vm id which running POD, and stop it
 	pod, ok := daemon.PodList.Get(podId)
@@ -27,14 +41,21 @@ func (daemon *Daemon) PodStopped(podId string) {
 }
 
 func (daemon *Daemon) StopPod(podId, stopVm string) (int, string, error) {
-	daemon.PodList.Lock()
-	glog.V(2).Infof("lock PodList")
-	defer glog.V(2).Infof("unlock PodList")
-	defer daemon.PodList.Unlock()
+	pod, err := daemon.ResolvePod(podId)
+	if err != nil {
+		return -1, "", err
+	}
+	podId = pod.id
+
+	pod.opLock.Lock()
+	glog.V(2).Infof("lock pod %s", podId)
+	defer glog.V(2).Infof("unlock pod %s", podId)
+	defer pod.opLock.Unlock()
 
 	return daemon.StopPodWithLock(podId, stopVm)
 }
 
+// StopPodWithLock stops the pod, the caller must hold the lock of the pod.
 func (daemon *Daemon) StopPodWithLock(podId, stopVm string) (int, string, error) {
 	glog.Infof("Prepare to stop the POD: %s", podId)
 	// find the vm id which running POD, and stop it
@@ -43,11 +64,9 @@ func (daemon *Daemon) StopPodWithLock(podId, stopVm string) (int, string, error)
 		glog.Errorf("Can not find pod(%s)", podId)
 		return -1, "", fmt.Errorf("Can not find pod(%s)", podId)
 	}
-	// we need to set the 'RestartPolicy' of the pod to 'never' if stop command is invoked
-	// for kubernetes
-	if pod.status.Type == "kubernetes" {
-		pod.status.RestartPolicy = "never"
-	}
+	// the pod stopped by the user should not be restarted
+	pod.setStopped(true)
+	pod.stopProbes()
 
 	if pod.vm == nil {
 		return types.E_VM_SHUTDOWN, "", nil
@@ -62,6 +81,7 @@ func (daemon *Daemon) StopPodWithLock(podId, stopVm string) (int, string, error)
 	if vmResponse.Code == types.E_VM_SHUTDOWN {
 		daemon.RemoveVm(vmId)
 	}
+	daemon.LogPodEvent(pod, "stop")
 	if pod.status.Autoremove == true {
 		daemon.CleanPodWithLock(podId)
 	}
@@ -69,3 +89,146 @@ func (daemon *Daemon) StopPodWithLock(podId, stopVm string) (int, string, error)
 
 	return vmResponse.Code, vmResponse.Cause, nil
 }
+
+// GracefulStopPod runs the preStop hooks of the containers, sends them the
+// signal, and waits at most timeout seconds for them to exit before the pod
+// is stopped.
+func (daemon *Daemon) GracefulStopPod(podId, stopVm string, sig syscall.Signal, timeout int) (int, string, error) {
+	pod, err := daemon.ResolvePod(podId)
+	if err != nil {
+		return -1, "", err
+	}
+	podId = pod.id
+
+	pod.opLock.Lock()
+	glog.V(2).Infof("lock pod %s", podId)
+	// the pod should be neither restarted nor killed by the liveness probe
+	// during the grace period
+	pod.setStopped(true)
+	pod.stopProbes()
+	vm := pod.vm
+	running := vm != nil && pod.status.Status == types.S_POD_RUNNING
+	// the lock is released during the grace period, the handler of the VM
+	// needs it to clean up the pod once the containers exit
+	glog.V(2).Infof("unlock pod %s", podId)
+	pod.opLock.Unlock()
+
+	killed := false
+	if running {
+		deadline := time.Now().Add(time.Duration(timeout) * time.Second)
+		pod.runPreStopHooks(vm, deadline)
+		pod.signalContainers(vm, sig)
+
+		if !pod.waitFinished(deadline.Sub(time.Now())) {
+			glog.Infof("Pod %s is still running after %d seconds, kill it", podId, timeout)
+			pod.signalContainers(vm, syscall.SIGKILL)
+			killed = !pod.waitFinished(killTimeout)
+		}
+	}
+
+	pod.opLock.Lock()
+	glog.V(2).Infof("lock pod %s", podId)
+	defer glog.V(2).Infof("unlock pod %s", podId)
+	defer pod.opLock.Unlock()
+
+	if _, ok := daemon.PodList.Get(podId); !ok && running {
+		// the pod is removed by autoremove once its containers exit
+		glog.V(1).Infof("Pod %s is removed after it is stopped", podId)
+		return types.E_VM_SHUTDOWN, "", nil
+	}
+
+	if killed {
+		// the VM is torn down before the exit codes are reported
+		pod.updateStatus(func() {
+			for _, c := range pod.status.Containers {
+				if c.Status == types.S_POD_RUNNING {
+					c.ExitCode = 128 + int(syscall.SIGKILL)
+				}
+			}
+		})
+	}
+
+	code, cause, err := daemon.StopPodWithLock(podId, stopVm)
+	if err == nil && running {
+		daemon.LogPodContainerEvents(pod, "stop")
+	}
+	return code, cause, err
+}
+
+// signalContainers sends the signal to the running containers of the pod.
+func (p *Pod) signalContainers(vm *hypervisor.Vm, sig syscall.Signal) {
+	for _, c := range p.status.Containers {
+		if c.Status != types.S_POD_RUNNING {
+			continue
+		}
+		glog.V(1).Infof("Send signal %d to container %s", sig, c.Id)
+		if err := vm.KillContainer(c.Id, sig); err != nil {
+			glog.Warningf("Failed to send signal %d to container %s: %s", sig, c.Id, err.Error())
+		}
+	}
+}
+
+// runPreStopHooks executes the preStop hooks of the running containers in
+// parallel, and waits for them until the deadline. The hooks are looked up
+// by the name of the container.
+func (p *Pod) runPreStopHooks(vm *hypervisor.Vm, deadline time.Time) {
+	var wg sync.WaitGroup
+
+	for _, c := range p.status.Containers {
+		hook, ok := p.preStop[strings.TrimPrefix(c.Name, "/")]
+		if !ok || c.Status != types.S_POD_RUNNING {
+			continue
+		}
+
+		wg.Add(1)
+		go func(container string, command []string) {
+			defer wg.Done()
+			glog.V(1).Infof("Run preStop hook %v of container %s", command, container)
+			if err := execInContainer(vm, container, command, deadline.Sub(time.Now())); err != nil {
+				glog.Warningf("PreStop hook of container %s failed: %s", container, err.Error())
+			}
+		}(c.Id, hook.Command)
+	}
+
+	wg.Wait()
+}
+
+func (p *Pod) notifyFinished() {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.finished != nil {
+		close(p.finished)
+		p.finished = nil
+	}
+}
+
+// resetFinished prepares the pod to be waited for, the waiters of a pod
+// which is not started yet keep waiting.
+func (p *Pod) resetFinished() {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.finished == nil {
+		p.finished = make(chan struct{})
+	}
+}
+
+// waitFinished waits for all the containers of the pod to exit, it returns
+// false if they are still running after timeout.
+func (p *Pod) waitFinished(timeout time.Duration) bool {
+	p.RLock()
+	finished := p.finished
+	p.RUnlock()
+
+	if finished == nil {
+		return true
+	}
+
+	select {
+	case <-finished:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
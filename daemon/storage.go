@@ -4,12 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/rand"
 	"path"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/docker/docker/pkg/archive"
 	dockertypes "github.com/docker/engine-api/types"
 	"github.com/golang/glog"
 	"github.com/hyperhq/hyper/storage"
@@ -42,6 +44,7 @@ type Storage interface {
 	InjectFile(src io.Reader, containerId, target, rootDir string, perm, uid, gid int) error
 	CreateVolume(daemon *Daemon, podId, shortName string) (*hypervisor.VolumeInfo, error)
 	RemoveVolume(podId string, record []byte) error
+	Changes(daemon *Daemon, containerId string) ([]archive.Change, error)
 }
 
 var StorageDrivers map[string]func(*dockertypes.Info) (Storage, error) = map[string]func(*dockertypes.Info) (Storage, error){
@@ -226,6 +229,18 @@ func (dms *DevMapperStorage) RemoveVolume(podId string, record []byte) error {
 	return nil
 }
 
+func (dms *DevMapperStorage) Changes(daemon *Daemon, containerId string) ([]archive.Change, error) {
+	mountId, err := GetMountIdByContainer(dms.Type(), containerId)
+	if err != nil {
+		return nil, err
+	}
+	initId, err := getInitIdByContainer(dms.Type(), containerId)
+	if err != nil {
+		return nil, err
+	}
+	return dm.Changes(mountId, initId, dms.DevPrefix, dms.RootPath())
+}
+
 func (dms *DevMapperStorage) randDevId() int {
 	return rand.Intn(1<<24-1) + 1 // 0 reserved for pool device
 }
@@ -291,6 +306,14 @@ func (a *AufsStorage) RemoveVolume(podId string, record []byte) error {
 	return nil
 }
 
+func (a *AufsStorage) Changes(daemon *Daemon, containerId string) ([]archive.Change, error) {
+	mountId, err := GetMountIdByContainer(a.Type(), containerId)
+	if err != nil {
+		return nil, err
+	}
+	return aufs.Changes(mountId, a.RootPath())
+}
+
 type OverlayFsStorage struct {
 	rootPath string
 }
@@ -349,6 +372,14 @@ func (o *OverlayFsStorage) RemoveVolume(podId string, record []byte) error {
 	return nil
 }
 
+func (o *OverlayFsStorage) Changes(daemon *Daemon, containerId string) ([]archive.Change, error) {
+	mountId, err := GetMountIdByContainer(o.Type(), containerId)
+	if err != nil {
+		return nil, err
+	}
+	return overlay.Changes(mountId, o.RootPath())
+}
+
 type VBoxStorage struct {
 	rootPath string
 }
@@ -405,3 +436,19 @@ func (v *VBoxStorage) CreateVolume(daemon *Daemon, podId, shortName string) (*hy
 func (v *VBoxStorage) RemoveVolume(podId string, record []byte) error {
 	return nil
 }
+
+// Changes of vbox are from the graph driver, which diffs the disk of the
+// container in the VM.
+func (v *VBoxStorage) Changes(daemon *Daemon, containerId string) ([]archive.Change, error) {
+	return daemon.Daemon.ContainerChanges(containerId)
+}
+
+// getInitIdByContainer returns the id of the init layer of the container,
+// which is the parent of its writable layer.
+func getInitIdByContainer(driver, cid string) (string, error) {
+	id, err := ioutil.ReadFile(path.Join(utils.HYPER_ROOT, fmt.Sprintf("image/%s/layerdb/mounts/%s/init-id", driver, cid)))
+	if err != nil {
+		return "", err
+	}
+	return string(id), nil
+}
@@ -0,0 +1,192 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	apitypes "github.com/hyperhq/hyper/types"
+	"github.com/hyperhq/hyper/utils"
+	"github.com/hyperhq/runv/hypervisor"
+	"github.com/hyperhq/runv/hypervisor/pod"
+)
+
+const (
+	TemplateCreating = "creating"
+	TemplateReady    = "ready"
+	TemplateFailed   = "failed"
+)
+
+// vmTemplate is a VM booted once and saved with its memory and device
+// state, new VMs of the flavor are cloned from the saved state instead of
+// booting from scratch, and share the memory of the template until they
+// write to it.
+type vmTemplate struct {
+	flavor vmFlavor
+	dir    string
+
+	sync.Mutex
+	status  string
+	message string
+	created time.Time
+	clones  int
+}
+
+// templateRoot returns the directory holding the saved states of the VM
+// templates.
+func templateRoot() string {
+	return path.Join(utils.HYPER_ROOT, "template")
+}
+
+// newVmTemplate returns the template of the flavor, every template has its
+// own directory, so that the one being created is not removed by the
+// template of the same flavor which replaces it on reload.
+func newVmTemplate(flavor vmFlavor) *vmTemplate {
+	return &vmTemplate{
+		flavor: flavor,
+		dir:    path.Join(templateRoot(), fmt.Sprintf("%d-%d-%s", flavor.cpu, flavor.mem, pod.RandStr(10, "alphanum"))),
+		status: TemplateCreating,
+	}
+}
+
+func (t *vmTemplate) memoryPath() string {
+	return path.Join(t.dir, "memory")
+}
+
+func (t *vmTemplate) statePath() string {
+	return path.Join(t.dir, "state")
+}
+
+func (t *vmTemplate) setStatus(status, message string) {
+	t.Lock()
+	t.status = status
+	t.message = message
+	if status == TemplateReady {
+		t.created = time.Now()
+	}
+	t.Unlock()
+}
+
+func (t *vmTemplate) ready() bool {
+	t.Lock()
+	defer t.Unlock()
+	return t.status == TemplateReady
+}
+
+// create boots the template VM, pauses it and saves its state, the VM is
+// killed afterwards, only the saved state is kept.
+func (t *vmTemplate) create(daemon *Daemon) (err error) {
+	defer func() {
+		if err != nil {
+			glog.Errorf("Failed to create the VM template %s: %s", t.flavor, err.Error())
+			t.Lock()
+			if t.status == TemplateCreating {
+				t.status = TemplateFailed
+				t.message = err.Error()
+			}
+			t.Unlock()
+			os.RemoveAll(t.dir)
+		}
+	}()
+
+	if err = os.MkdirAll(t.dir, 0755); err != nil {
+		return err
+	}
+
+	b := daemon.BootConfig(t.flavor.cpu, t.flavor.mem, true)
+	b.BootToBeTemplate = true
+	b.MemoryPath = t.memoryPath()
+	b.DevicesStatePath = t.statePath()
+
+	vm, err := daemon.LaunchVm("", b, false, 0)
+	if err != nil {
+		return err
+	}
+	defer daemon.KillVm(vm.Id)
+
+	if err = daemon.WaitVmStart(vm); err != nil {
+		return err
+	}
+	if err = vm.Pause(true); err != nil {
+		return err
+	}
+	if err = vm.Save(t.statePath()); err != nil {
+		return err
+	}
+
+	// the template may be destroyed by the reload of the config while it
+	// is being created
+	t.Lock()
+	if t.status != TemplateCreating {
+		t.Unlock()
+		return fmt.Errorf("the template is %s", t.message)
+	}
+	t.status = TemplateReady
+	t.created = time.Now()
+	t.Unlock()
+
+	glog.V(1).Infof("VM template %s is created in %s", t.flavor, t.dir)
+	return nil
+}
+
+// clone creates a new VM from the template.
+func (t *vmTemplate) clone(daemon *Daemon) (*hypervisor.Vm, error) {
+	b := daemon.BootConfig(t.flavor.cpu, t.flavor.mem, true)
+	b.BootFromTemplate = true
+	b.MemoryPath = t.memoryPath()
+	b.DevicesStatePath = t.statePath()
+
+	vm, err := daemon.LaunchVm("", b, false, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Lock()
+	t.clones++
+	t.Unlock()
+	glog.V(1).Infof("Clone VM %s from template %s", vm.Id, t.flavor)
+	return vm, nil
+}
+
+func (t *vmTemplate) destroy() {
+	t.setStatus(TemplateFailed, "destroyed")
+	if err := os.RemoveAll(t.dir); err != nil {
+		glog.Warningf("Failed to remove the VM template %s: %s", t.dir, err.Error())
+	}
+}
+
+func (t *vmTemplate) info() apitypes.VmTemplateInfo {
+	t.Lock()
+	defer t.Unlock()
+
+	info := apitypes.VmTemplateInfo{
+		Cpu:     t.flavor.cpu,
+		Memory:  t.flavor.mem,
+		Status:  t.status,
+		Message: t.message,
+		Clones:  t.clones,
+	}
+	if !t.created.IsZero() {
+		info.Created = t.created.UTC().Format(time.RFC3339)
+	}
+	return info
+}
+
+// pickTemplate returns the largest ready template which is not larger than
+// the requested size, the VM cloned from it is then hot-added to the size.
+func pickTemplate(templates []*vmTemplate, cpu, mem int) *vmTemplate {
+	var best *vmTemplate
+
+	for _, t := range templates {
+		if !t.flavor.fits(cpu, mem) || !t.ready() {
+			continue
+		}
+		if best == nil || t.flavor.closerThan(best.flavor) {
+			best = t
+		}
+	}
+	return best
+}
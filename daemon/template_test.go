@@ -0,0 +1,100 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/hyperhq/hyper/utils"
+)
+
+func TestPickTemplate(t *testing.T) {
+	var templates []*vmTemplate
+	for _, f := range []vmFlavor{{1, 128}, {2, 512}, {4, 1024}} {
+		tpl := newVmTemplate(f)
+		tpl.setStatus(TemplateReady, "")
+		templates = append(templates, tpl)
+	}
+	templates[2].setStatus(TemplateFailed, "test")
+
+	cases := []struct {
+		cpu, mem int
+		expect   *vmTemplate
+	}{
+		{1, 128, templates[0]},
+		{1, 1024, templates[0]},
+		{2, 512, templates[1]},
+		{8, 4096, templates[1]},
+		{1, 64, nil},
+	}
+	for _, c := range cases {
+		if got := pickTemplate(templates, c.cpu, c.mem); got != c.expect {
+			t.Errorf("pick template for %d:%d, expect %v, got %v", c.cpu, c.mem, c.expect, got)
+		}
+	}
+}
+
+func TestCreateTemplateFailed(t *testing.T) {
+	f, err := ioutil.TempFile("", "hyper-template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	d := &Daemon{PodList: NewPodList(), VmList: NewVmList()}
+	c := &d.vmCache
+	c.daemon = d
+
+	// the template can't be saved under a file
+	failed := newVmTemplate(vmFlavor{1, 128})
+	failed.dir = path.Join(f.Name(), "1-128")
+	other := newVmTemplate(vmFlavor{2, 512})
+	c.templates = []*vmTemplate{failed, other}
+
+	c.createTemplate(failed)
+	if len(c.templates) != 1 || c.templates[0] != other {
+		t.Fatalf("expect the failed template to be dropped, got %v", c.templates)
+	}
+	if info := failed.info(); info.Status != TemplateFailed || info.Message == "" {
+		t.Errorf("unexpected status of the failed template %v", info)
+	}
+
+	// the template destroyed by the reload keeps its status
+	other.destroy()
+	other.dir = path.Join(f.Name(), "2-512")
+	c.createTemplate(other)
+	if info := other.info(); info.Status != TemplateFailed || info.Message != "destroyed" {
+		t.Errorf("unexpected status of the destroyed template %v", info)
+	}
+}
+
+func TestTemplateDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hyper-template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	old := utils.HYPER_ROOT
+	utils.HYPER_ROOT = dir
+	defer func() { utils.HYPER_ROOT = old }()
+
+	// the template replacing the one of the same flavor on reload
+	replaced, current := newVmTemplate(vmFlavor{1, 128}), newVmTemplate(vmFlavor{1, 128})
+	if replaced.dir == current.dir || path.Dir(current.dir) != templateRoot() {
+		t.Fatalf("expect the templates to have their own directories, got %s and %s", replaced.dir, current.dir)
+	}
+	for _, tpl := range []*vmTemplate{replaced, current} {
+		if err := os.MkdirAll(tpl.dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	replaced.destroy()
+	if _, err := os.Stat(replaced.dir); !os.IsNotExist(err) {
+		t.Fatalf("expect the destroyed template to be removed: %v", err)
+	}
+	if _, err := os.Stat(current.dir); err != nil {
+		t.Fatalf("the directory of the other template is removed: %v", err)
+	}
+}
@@ -0,0 +1,309 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	apitypes "github.com/hyperhq/hyper/types"
+)
+
+// The process table is read from the procfs of the container with the file
+// commands of hyperstart, so that no tool is needed in the image. The
+// processes are found by walking the children of every thread from the init
+// of the container, which needs the kernel of the VM to list the children
+// (CONFIG_PROC_CHILDREN).
+
+const (
+	// clockTicks is USER_HZ, in which the times in procfs are
+	clockTicks = 100
+	pageSize   = 4096
+	// maxTidMisses bounds the search of the threads of a process, every
+	// tid tried is a read through the VM
+	maxTidMisses = 256
+)
+
+// procReader reads a file in the container.
+type procReader func(path string) ([]byte, error)
+
+// ContainerTop returns the processes running in the container.
+func (daemon *Daemon) ContainerTop(name string) (*apitypes.ContainerTop, error) {
+	vm, container, err := daemon.runningContainer(name)
+	if err != nil {
+		return nil, err
+	}
+
+	procs, err := listProcesses(func(path string) ([]byte, error) {
+		return vm.ReadFile(container, path)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Can not list the processes of container %s: %s", name, err.Error())
+	}
+
+	return &apitypes.ContainerTop{
+		Container: container,
+		Processes: procs,
+	}, nil
+}
+
+func listProcesses(read procReader) ([]apitypes.ContainerProcess, error) {
+	uptime, err := readUptime(read)
+	if err != nil {
+		return nil, err
+	}
+	btime, err := readBootTime(read)
+	if err != nil {
+		return nil, err
+	}
+	pids, err := listPids(read)
+	if err != nil {
+		return nil, err
+	}
+	users := readUsers(read)
+
+	procs := []apitypes.ContainerProcess{}
+	for _, pid := range pids {
+		p, err := readProcess(read, pid, uptime, btime)
+		if err != nil {
+			// the process is gone or the pid is not used
+			glog.V(3).Infof("Skip process %d: %s", pid, err.Error())
+			continue
+		}
+		if p.User = users[p.Uid]; p.User == "" {
+			p.User = strconv.Itoa(p.Uid)
+		}
+		procs = append(procs, *p)
+	}
+
+	sort.Sort(processesByPid(procs))
+	return procs, nil
+}
+
+type processesByPid []apitypes.ContainerProcess
+
+func (p processesByPid) Len() int           { return len(p) }
+func (p processesByPid) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p processesByPid) Less(i, j int) bool { return p[i].Pid < p[j].Pid }
+
+// listPids returns the pids of the processes in the container.
+func listPids(read procReader) ([]int, error) {
+	data, err := read("/proc/sys/kernel/ns_last_pid")
+	if err != nil {
+		return nil, err
+	}
+	last, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid last pid %q", data)
+	}
+
+	var (
+		pids  []int
+		seen  = make(map[int]bool)
+		queue = []int{1}
+	)
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		if seen[pid] {
+			continue
+		}
+		seen[pid] = true
+
+		children, err := listChildren(read, pid, last)
+		if err != nil {
+			if pid == 1 {
+				return nil, err
+			}
+			// exited during the walk
+			glog.V(3).Infof("Skip process %d: %s", pid, err.Error())
+			continue
+		}
+		pids = append(pids, pid)
+		queue = append(queue, children...)
+	}
+	return pids, nil
+}
+
+// listChildren returns the children of all the threads of the process. The
+// children forked by a thread are only listed by the thread. The threads
+// can't be listed without reading the task directory, which the file
+// commands can't, so the tids of the other threads are searched from the
+// pid on, where they are allocated unless the pids wrap around at the last
+// one. The search gives up after maxTidMisses tids in a row which are not
+// the threads of the process, as the count of the threads may be stale.
+func listChildren(read procReader, pid, last int) ([]int, error) {
+	data, err := read(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return nil, err
+	}
+	threads, err := parseStatusInt(data, "Threads:", 1)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = read(fmt.Sprintf("/proc/%d/task/%d/children", pid, pid))
+	if err != nil {
+		if pid == 1 {
+			return nil, fmt.Errorf("The kernel of the VM doesn't list the children of the processes, CONFIG_PROC_CHILDREN is needed: %s", err.Error())
+		}
+		return nil, err
+	}
+	children := parsePids(data)
+
+	for tid, found, misses := pid, 1, 0; found < threads; {
+		if tid++; tid > last {
+			tid = 1
+		}
+		if tid == pid || misses >= maxTidMisses {
+			// some threads exited during the search
+			glog.V(3).Infof("Found %d of the %d threads of process %d", found, threads, pid)
+			break
+		}
+		if data, err := read(fmt.Sprintf("/proc/%d/task/%d/children", pid, tid)); err == nil {
+			children = append(children, parsePids(data)...)
+			found++
+			misses = 0
+		} else {
+			misses++
+		}
+	}
+	return children, nil
+}
+
+func parsePids(data []byte) []int {
+	var pids []int
+	for _, field := range strings.Fields(string(data)) {
+		if pid, err := strconv.Atoi(field); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// readProcess reads the process from its stat, status and cmdline.
+func readProcess(read procReader, pid int, uptime float64, btime int64) (*apitypes.ContainerProcess, error) {
+	data, err := read(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return nil, err
+	}
+
+	// the command may contain spaces and parentheses
+	stat := string(data)
+	start, end := strings.IndexByte(stat, '('), strings.LastIndexByte(stat, ')')
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("Invalid stat %q", stat)
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 22 {
+		return nil, fmt.Errorf("Invalid stat %q", stat)
+	}
+
+	var values [22]uint64
+	for _, i := range []int{1, 11, 12, 19, 21} {
+		if values[i], err = strconv.ParseUint(fields[i], 10, 64); err != nil {
+			return nil, fmt.Errorf("Invalid stat %q", stat)
+		}
+	}
+
+	p := &apitypes.ContainerProcess{
+		Pid:     pid,
+		Ppid:    int(values[1]),
+		State:   fields[0],
+		CpuTime: float64(values[11]+values[12]) / clockTicks,
+		Rss:     values[21] * pageSize,
+		Command: stat[start+1 : end],
+	}
+	started := float64(values[19]) / clockTicks
+	p.StartTime = btime + int64(started)
+	if elapsed := uptime - started; elapsed > 0 {
+		p.CpuPercent = p.CpuTime / elapsed * 100
+	}
+
+	if data, err = read(fmt.Sprintf("/proc/%d/status", pid)); err != nil {
+		return nil, err
+	}
+	// the effective uid
+	if p.Uid, err = parseStatusInt(data, "Uid:", 2); err != nil {
+		return nil, err
+	}
+
+	if data, err = read(fmt.Sprintf("/proc/%d/cmdline", pid)); err != nil {
+		return nil, err
+	}
+	if args := strings.Split(strings.TrimRight(string(data), "\x00"), "\x00"); args[0] != "" {
+		p.Args = strings.Join(args, " ")
+	} else {
+		// kernel threads and zombies have no command line
+		p.Args = "[" + p.Command + "]"
+	}
+
+	return p, nil
+}
+
+// parseStatusInt returns the integer in the column of the field in the
+// status of a process.
+func parseStatusInt(status []byte, field string, column int) (int, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(status))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > column && fields[0] == field {
+			return strconv.Atoi(fields[column])
+		}
+	}
+	return 0, fmt.Errorf("No %s in the status", strings.TrimSuffix(field, ":"))
+}
+
+func readUptime(read procReader) (float64, error) {
+	data, err := read("/proc/uptime")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("Invalid uptime %q", data)
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+func readBootTime(read procReader) (int64, error) {
+	data, err := read("/proc/stat")
+	if err != nil {
+		return 0, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "btime" {
+			return strconv.ParseInt(fields[1], 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("No boot time in /proc/stat")
+}
+
+// readUsers returns the user names in the passwd of the container, the
+// users are shown as the uids without it.
+func readUsers(read procReader) map[int]string {
+	users := make(map[int]string)
+	data, err := read("/etc/passwd")
+	if err != nil {
+		return users
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 3 {
+			continue
+		}
+		if uid, err := strconv.Atoi(fields[2]); err == nil {
+			if _, ok := users[uid]; !ok {
+				users[uid] = fields[0]
+			}
+		}
+	}
+	return users
+}
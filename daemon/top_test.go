@@ -0,0 +1,120 @@
+package daemon
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testProcFiles map[string]string
+
+func (f testProcFiles) read(path string) ([]byte, error) {
+	data, ok := f[path]
+	if !ok {
+		return nil, fmt.Errorf("%s: no such file", path)
+	}
+	return []byte(data), nil
+}
+
+func newTestProcFiles() testProcFiles {
+	return testProcFiles{
+		"/proc/sys/kernel/ns_last_pid": "9\n",
+		"/proc/uptime":                 "1000.50 1800.00\n",
+		"/proc/stat":                   "cpu  1 2 3 4\nbtime 1400000000\nprocesses 42\n",
+		"/etc/passwd":                  "root:x:0:0:root:/root:/bin/sh\nwww:x:33:33::/var/www:/bin/false\n",
+
+		"/proc/1/stat":    "1 (sh) S 0 1 1 0 -1 4194560 100 0 0 0 150 50 0 0 20 0 1 0 50000 4096000 200 18446744073709551615",
+		"/proc/1/status":  "Name:\tsh\nUid:\t0\t0\t0\t0\nThreads:\t1\n",
+		"/proc/1/cmdline": "/bin/sh\x00-c\x00run\x00",
+
+		"/proc/7/stat":    "7 (my (app)) R 1 7 1 0 -1 4194560 100 0 0 0 900 100 0 0 20 0 1 0 90000 4096000 1000 18446744073709551615",
+		"/proc/7/status":  "Name:\tapp\nUid:\t33\t33\t33\t33\nThreads:\t1\n",
+		"/proc/7/cmdline": "",
+	}
+}
+
+func TestListProcesses(t *testing.T) {
+	files := newTestProcFiles()
+	files["/proc/1/task/1/children"] = "7 "
+	files["/proc/7/task/7/children"] = ""
+
+	procs, err := listProcesses(files.read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(procs) != 2 {
+		t.Fatalf("expect 2 processes, got %v", procs)
+	}
+
+	sh, app := procs[0], procs[1]
+	if sh.Pid != 1 || sh.User != "root" || sh.Args != "/bin/sh -c run" || sh.Command != "sh" {
+		t.Errorf("unexpected process %v", sh)
+	}
+	if sh.CpuTime != 2 || sh.Rss != 200*pageSize || sh.StartTime != 1400000500 {
+		t.Errorf("unexpected usage of process %v", sh)
+	}
+	// started at 900s, 10s of cpu in the 100.5s since
+	if app.CpuPercent < 9.9 || app.CpuPercent > 10 {
+		t.Errorf("unexpected cpu percent %f", app.CpuPercent)
+	}
+	if app.Ppid != 1 || app.User != "www" || app.State != "R" || app.Command != "my (app)" || app.Args != "[my (app)]" {
+		t.Errorf("unexpected process %v", app)
+	}
+}
+
+func TestListPidsOfThreads(t *testing.T) {
+	files := newTestProcFiles()
+	files["/proc/1/task/1/children"] = "7"
+	// process 7 forks 3 from its thread 9, and 5 from its thread 2 created
+	// after the pids wrapped around
+	files["/proc/7/status"] = "Name:\tapp\nUid:\t33\t33\t33\t33\nThreads:\t3\n"
+	files["/proc/7/task/7/children"] = ""
+	files["/proc/7/task/9/children"] = "3 "
+	files["/proc/7/task/2/children"] = "5"
+	for _, pid := range []string{"3", "5"} {
+		files["/proc/"+pid+"/status"] = "Name:\tsleep\nThreads:\t1\n"
+		files["/proc/"+pid+"/task/"+pid+"/children"] = ""
+	}
+
+	pids, err := listPids(files.read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pids, []int{1, 7, 3, 5}) {
+		t.Errorf("unexpected pids %v", pids)
+	}
+}
+
+func TestListPidsOfStaleThreads(t *testing.T) {
+	files := newTestProcFiles()
+	files["/proc/sys/kernel/ns_last_pid"] = "4194304\n"
+	files["/proc/1/task/1/children"] = "7"
+	// the threads of process 7 exited after its status was read
+	files["/proc/7/status"] = "Name:\tapp\nUid:\t33\t33\t33\t33\nThreads:\t3\n"
+	files["/proc/7/task/7/children"] = ""
+	files["/proc/7/task/8/children"] = ""
+
+	reads := 0
+	pids, err := listPids(func(path string) ([]byte, error) {
+		reads++
+		return files.read(path)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pids, []int{1, 7}) {
+		t.Errorf("unexpected pids %v", pids)
+	}
+	if reads > 2*maxTidMisses {
+		t.Errorf("expect the search of the threads to be bounded, got %d reads", reads)
+	}
+}
+
+func TestListPidsWithoutChildren(t *testing.T) {
+	files := newTestProcFiles()
+
+	if _, err := listProcesses(files.read); err == nil || !strings.Contains(err.Error(), "CONFIG_PROC_CHILDREN") {
+		t.Fatalf("expect error for the kernel without the children lists, got %v", err)
+	}
+}
@@ -3,37 +3,31 @@ package daemon
 import (
 	"fmt"
 	"github.com/golang/glog"
-	"strings"
 )
 
-func (daemon *Daemon) TtyResize(podId, tag string, h, w int) error {
-	var (
-		container string
-		vmid      string
-		err       error
-	)
+// TtyResize resizes the tty of the pod, the container or the VM referred by
+// the id, which is resolved in that order. An ambiguous prefix fails at
+// once instead of being resolved as another kind.
+func (daemon *Daemon) TtyResize(id, tag string, h, w int) error {
+	var vmid string
 
-	if strings.Contains(podId, "pod-") {
-		container = ""
-		vmid, err = daemon.GetVmByPodId(podId)
-		if err != nil {
-			return err
-		}
-	} else if strings.Contains(podId, "vm-") {
-		vmid = podId
+	pod, err := daemon.ResolvePod(id)
+	if err != nil && !isAmbiguous(err) {
+		pod, _, err = daemon.ResolveContainer(id)
+	}
+	if err == nil {
+		vmid = pod.status.Vm
+	} else if isAmbiguous(err) {
+		return err
+	} else if vm, err := daemon.ResolveVm(id); err == nil {
+		vmid = vm.Id
+	} else if isAmbiguous(err) {
+		return err
 	} else {
-		container = podId
-		podId, err = daemon.GetPodByContainer(container)
-		if err != nil {
-			return err
-		}
-		vmid, err = daemon.GetVmByPodId(podId)
-		if err != nil {
-			return err
-		}
+		return fmt.Errorf("Can not find pod, container or vm %s", id)
 	}
 
-	vm, ok := daemon.VmList[vmid]
+	vm, ok := daemon.VmList.Get(vmid)
 	if !ok {
 		return fmt.Errorf("vm %s doesn't exist!", vmid)
 	}
@@ -0,0 +1,74 @@
+package daemon
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/hyperhq/runv/hypervisor/pod"
+	"github.com/hyperhq/runv/hypervisor/types"
+)
+
+// UpdatePodResource changes the vCPU number and the memory size (MB) of the
+// pod, 0 keeps the current value. The VM of a running pod is grown in place
+// by hot-adding, it can't be shrunk as the hypervisors don't support to
+// unplug the vCPUs or the memory. The new resource is stored in the pod
+// spec, it is also used when the pod is started next time.
+func (daemon *Daemon) UpdatePodResource(podId string, cpu, mem int) (*pod.UserResource, error) {
+	p, err := daemon.ResolvePod(podId)
+	if err != nil {
+		return nil, err
+	}
+
+	if cpu < 0 || mem < 0 {
+		return nil, fmt.Errorf("Invalid resource: %d vCPUs, %dMB memory", cpu, mem)
+	}
+
+	p.opLock.Lock()
+	glog.V(2).Infof("lock pod %s", p.id)
+	defer glog.V(2).Infof("unlock pod %s", p.id)
+	defer p.opLock.Unlock()
+
+	resource := p.spec.Resource
+	if cpu > 0 {
+		resource.Vcpu = cpu
+	}
+	if mem > 0 {
+		resource.Memory = mem
+	}
+
+	switch p.status.Status {
+	case types.S_POD_RUNNING:
+		if p.vm == nil {
+			return nil, fmt.Errorf("Pod %s has no VM", p.id)
+		}
+		if resource.Vcpu < p.vm.Cpu || resource.Memory < p.vm.Mem {
+			return nil, fmt.Errorf("Can not shrink the running pod %s from %d vCPUs %dMB to %d vCPUs %dMB",
+				p.id, p.vm.Cpu, p.vm.Mem, resource.Vcpu, resource.Memory)
+		}
+		cur, req := podAllocation(p.spec.Resource), podAllocation(resource)
+		release, err := daemon.admit("update-"+p.id, allocation{cpus: req.cpus - cur.cpus, mem: req.mem - cur.mem})
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		if err := hotAddCpuMem(p.vm, resource.Vcpu, resource.Memory); err != nil {
+			return nil, fmt.Errorf("Failed to grow the VM of pod %s: %s", p.id, err.Error())
+		}
+	case types.S_POD_PAUSED:
+		return nil, fmt.Errorf("Can not update the paused pod %s", p.id)
+	}
+
+	err = daemon.updatePodSpec(p.id, func(spec map[string]interface{}) {
+		spec["resource"] = resource
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	glog.V(1).Infof("Pod %s is updated to %d vCPUs %dMB memory", p.id, resource.Vcpu, resource.Memory)
+	// the resource of the pods is read by the admission of the others
+	p.Lock()
+	p.spec.Resource = resource
+	p.Unlock()
+	return &resource, nil
+}
@@ -0,0 +1,90 @@
+package daemon
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/hyperhq/runv/hypervisor"
+	"github.com/hyperhq/runv/hypervisor/pod"
+	"github.com/hyperhq/runv/hypervisor/types"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// newTestDBDaemon returns a daemon with the db in a temp dir, the returned
+// function cleans it up.
+func newTestDBDaemon(t *testing.T) (*Daemon, func()) {
+	dir, err := ioutil.TempDir("", "hyper-db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := leveldb.OpenFile(path.Join(dir, "hyper.db"), nil)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	d := &Daemon{PodList: NewPodList(), VmList: NewVmList(), db: db}
+	return d, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestUpdatePodResource(t *testing.T) {
+	d, cleanup := newTestDBDaemon(t)
+	defer cleanup()
+
+	p := newTestPod("pod-update")
+	p.spec = &pod.UserPod{Resource: pod.UserResource{Vcpu: 1, Memory: 128}}
+	p.status.Name = "web"
+	p.vm = &hypervisor.Vm{Id: "vm-update", Cpu: 1, Mem: 128}
+	d.PodList.Put(p)
+
+	raw := `{"resource":{"vcpu":1,"memory":128},"containers":[{"name":"web","livenessProbe":{"exec":{"command":["true"]}}}]}`
+	if err := d.WritePodToDB(p.id, []byte(raw)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.UpdatePodResource(p.id, 0, 64); err == nil {
+		t.Error("shrinking the memory of a running pod should fail")
+	}
+
+	resource, err := d.UpdatePodResource("web", 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resource.Vcpu != 2 || resource.Memory != 128 || p.spec.Resource != *resource {
+		t.Errorf("expect 2 vCPUs 128MB, got %#v, spec %#v", resource, p.spec.Resource)
+	}
+
+	data, err := d.GetPodByName(p.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored struct {
+		Resource   pod.UserResource `json:"resource"`
+		Containers []struct {
+			LivenessProbe interface{} `json:"livenessProbe"`
+		} `json:"containers"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Resource != *resource {
+		t.Errorf("expect stored resource %#v, got %#v", resource, stored.Resource)
+	}
+	if len(stored.Containers) != 1 || stored.Containers[0].LivenessProbe == nil {
+		t.Errorf("the probe is lost in the stored spec: %s", data)
+	}
+
+	// a stopped pod could be shrunk, the VM is created on next start
+	p.status.Status = types.S_POD_SUCCEEDED
+	p.vm = nil
+	if resource, err = d.UpdatePodResource(p.id, 1, 64); err != nil || resource.Memory != 64 {
+		t.Errorf("expect stopped pod updated to 64MB, got %#v, %v", resource, err)
+	}
+}
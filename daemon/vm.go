@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/hyperhq/runv/hypervisor"
@@ -36,7 +37,7 @@ func (daemon *Daemon) CreateVm(cpu, mem int, async bool) (*hypervisor.Vm, error)
 }
 
 func (daemon *Daemon) KillVm(vmId string) (int, string, error) {
-	vm, ok := daemon.VmList[vmId]
+	vm, ok := daemon.VmList.Get(vmId)
 	if !ok {
 		return 0, "", nil
 	}
@@ -48,6 +49,29 @@ func (daemon *Daemon) KillVm(vmId string) (int, string, error) {
 	return code, cause, err
 }
 
+// recycleVm returns the idle VM to the pool of its flavor, the VM is killed
+// if it is not pooled.
+func (daemon *Daemon) recycleVm(vmId string) (int, string, error) {
+	vm, ok := daemon.VmList.Get(vmId)
+	if !ok {
+		return 0, "", nil
+	}
+	if daemon.vmCache.put(vm) {
+		glog.V(1).Infof("VM %s is returned to the pool", vmId)
+		return types.E_OK, "", nil
+	}
+	return daemon.KillVm(vmId)
+}
+
+// vmInUse reports whether a pod is running in the VM.
+func (daemon *Daemon) vmInUse(vmId string) bool {
+	return daemon.PodList.Find(func(p *Pod) bool {
+		p.RLock()
+		defer p.RUnlock()
+		return p.status.Vm == vmId && p.status.Status == types.S_POD_RUNNING
+	}) != nil
+}
+
 func (p *Pod) AssociateVm(daemon *Daemon, vmId string) error {
 	if p.vm != nil && p.vm.Id != vmId {
 		return fmt.Errorf("pod %s already has vm %s, but trying to associate with %s", p.id, p.vm.Id, vmId)
@@ -62,12 +86,19 @@ func (p *Pod) AssociateVm(daemon *Daemon, vmId string) error {
 	glog.V(1).Infof("The data for vm(%s) is %v", vmId, vmData)
 
 	p.vm = daemon.NewVm(vmId, p.spec.Resource.Vcpu, p.spec.Resource.Memory, false, types.VM_KEEP_NONE)
-	p.status.Vm = vmId
+	p.updateStatus(func() {
+		p.status.Vm = vmId
+	})
+	p.resetFinished()
 
 	err = p.vm.AssociateVm(p.status, vmData)
 	if err != nil {
+		daemon.VmList.Release(vmId)
 		p.vm = nil
-		p.status.Vm = ""
+		p.updateStatus(func() {
+			p.status.Vm = ""
+		})
+		p.notifyFinished()
 		return err
 	}
 
@@ -81,14 +112,18 @@ func (daemon *Daemon) ReleaseAllVms() (int, error) {
 		err error = nil
 	)
 
-	for _, vm := range daemon.VmList {
-		ret, err = vm.ReleaseVm()
-		if err != nil {
-			/* FIXME: continue to release other vms? */
-			break
+	daemon.VmList.Foreach(func(vm *hypervisor.Vm) error {
+		code, e := vm.ReleaseVm()
+		if e != nil {
+			// go on releasing the other VMs, the failed ones are left in
+			// the list to retry
+			glog.Warningf("Failed to release vm %s: %s", vm.Id, e.Error())
+			ret, err = code, e
+			return nil
 		}
 		daemon.RemoveVm(vm.Id)
-	}
+		return nil
+	})
 
 	return ret, err
 }
@@ -106,24 +141,34 @@ func (daemon *Daemon) StartVm(vmId string, cpu, mem int, lazy, cache bool, keep
 		mem = DEFAULT_MEM
 	}
 
-	b := &hypervisor.BootConfig{
+	return daemon.LaunchVm(vmId, daemon.BootConfig(cpu, mem, cache), lazy, keep)
+}
+
+func (daemon *Daemon) BootConfig(cpu, mem int, hotAdd bool) *hypervisor.BootConfig {
+	return &hypervisor.BootConfig{
 		CPU:          cpu,
 		Memory:       mem,
-		HotAddCpuMem: cache,
+		HotAddCpuMem: hotAdd,
 		Kernel:       daemon.Kernel,
 		Initrd:       daemon.Initrd,
 		Bios:         daemon.Bios,
 		Cbfs:         daemon.Cbfs,
 		Vbox:         daemon.VboxImage,
 	}
+}
 
-	vm := daemon.NewVm(vmId, cpu, mem, lazy, keep)
+// LaunchVm boots a VM with the given boot config and adds it to the VmList.
+func (daemon *Daemon) LaunchVm(vmId string, b *hypervisor.BootConfig, lazy bool, keep int) (*hypervisor.Vm, error) {
+	vm := daemon.NewVm(vmId, b.CPU, b.Memory, lazy, keep)
 
 	glog.V(1).Infof("The config: kernel=%s, initrd=%s", daemon.Kernel, daemon.Initrd)
+	start := time.Now()
 	err := vm.Launch(b)
 	if err != nil {
+		daemon.VmList.Release(vm.Id)
 		return nil, err
 	}
+	vmBootDuration.Since(start)
 
 	daemon.AddVm(vm)
 	return vm, nil
@@ -146,10 +191,11 @@ func (daemon *Daemon) WaitVmStart(vm *hypervisor.Vm) error {
 
 func (daemon *Daemon) GetVM(vmId string, resource *pod.UserResource, lazy bool, keep int) (*hypervisor.Vm, error) {
 	if vmId == "" {
-		return daemon.StartVm("", resource.Vcpu, resource.Memory, lazy, false, keep)
+		// boot with hot-add enabled, so that the pod could be grown later
+		return daemon.StartVm("", resource.Vcpu, resource.Memory, lazy, true, keep)
 	}
 
-	vm, ok := daemon.VmList[vmId]
+	vm, ok := daemon.VmList.Get(vmId)
 	if !ok {
 		return nil, fmt.Errorf("The VM %s doesn't exist", vmId)
 	}
@@ -166,104 +212,14 @@ func (daemon *Daemon) GetVM(vmId string, resource *pod.UserResource, lazy bool,
 	return vm, nil
 }
 
+// NewVm creates a VM object, a new ID is reserved in the VmList if id is
+// empty. The reservation is dropped by AddVm, or by VmList.Release if the
+// VM fails to launch.
 func (daemon *Daemon) NewVm(id string, cpu, memory int, lazy bool, keep int) *hypervisor.Vm {
 	vmId := id
 
 	if vmId == "" {
-		for {
-			vmId = fmt.Sprintf("vm-%s", pod.RandStr(10, "alpha"))
-			if _, ok := daemon.VmList[vmId]; !ok {
-				break
-			}
-		}
+		vmId = daemon.VmList.NewId("vm-")
 	}
 	return hypervisor.NewVm(vmId, cpu, memory, lazy, keep)
 }
-
-type VmCache struct {
-	daemon     *Daemon
-	lo, hi, nr int
-	cached     []*hypervisor.Vm
-}
-
-func (c *VmCache) get(cpu, mem int) (*hypervisor.Vm, error) {
-	glog.V(2).Info("Try to get cached Vm")
-	defer c.fill()
-	if c.nr == 0 {
-		return c.daemon.StartVm("", cpu, mem, false, false, 0)
-	}
-	c.nr = c.nr - 1
-	vm := c.cached[c.nr]
-	c.cached[c.nr] = nil
-	glog.V(2).Info("Get cached Vm: %s", vm.Id)
-
-	// hotplug add cpu and memory
-	var err error
-	var needOnline bool = false
-	if vm.Cpu < cpu {
-		needOnline = true
-		glog.Info("HotAddCpu for cached Vm")
-		err = vm.AddCpu(cpu)
-		glog.Info("HotAddCpu result %v", err)
-	}
-	if vm.Mem < mem {
-		needOnline = true
-		glog.Info("HotAddMem for cached Vm")
-		err = vm.AddMem(mem)
-		glog.Info("HotAddMem result %v", err)
-	}
-	if needOnline {
-		glog.Info("OnlineCpuMem for cached Vm")
-		vm.OnlineCpuMem()
-	}
-	if err != nil {
-		c.daemon.KillVm(vm.Id)
-		vm = nil
-	}
-	return vm, err
-}
-
-func (c *VmCache) fill() {
-	for c.nr < c.lo {
-		vm, err := c.daemon.StartVm("", 0, 0, false, true, 0)
-		if err != nil {
-			glog.Info("VmCache fills failed:%v", err)
-			return
-		}
-		c.cached[c.nr] = vm
-		c.nr = c.nr + 1
-	}
-}
-
-func (c *VmCache) put(vm *hypervisor.Vm) {
-	if c.nr < c.hi && vm.Cpu == 1 && vm.Mem == 128 { // default VM
-		glog.V(2).Info("VmCache return one vm")
-		c.cached[c.nr] = vm
-		c.nr = c.nr + 1
-	} else {
-		c.daemon.KillVm(vm.Id)
-	}
-}
-
-func (daemon *Daemon) InitVmCache(cache_policy string) error {
-	daemon.vmCache.daemon = daemon
-
-	if hypervisor.HDriver.SupportLazyMode() {
-		cache_policy = "none"
-	}
-
-	switch cache_policy {
-	case "none", "":
-		return nil
-	case "cache":
-		daemon.vmCache.lo = 10
-		daemon.vmCache.hi = 20
-		daemon.vmCache.cached = make([]*hypervisor.Vm, daemon.vmCache.hi)
-		daemon.vmCache.fill()
-		return nil
-	case "clone":
-		return fmt.Errorf("unimplemented cache policy: clone")
-	default:
-		return fmt.Errorf("unknown cache policy: %s", cache_policy)
-	}
-}
@@ -0,0 +1,599 @@
+package daemon
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	apitypes "github.com/hyperhq/hyper/types"
+	"github.com/hyperhq/runv/hypervisor"
+)
+
+const (
+	DefaultVmFlavors     = "1:128"
+	DefaultVmPoolFlavors = "1:128:10:20"
+	// DefaultVmIdleTimeout is the time in seconds a pooled VM above the
+	// minimum count stays idle before it is killed.
+	DefaultVmIdleTimeout = 600
+
+	defaultVmCpu = 1
+	defaultVmMem = 128
+
+	vmPoolCheckInterval = 30 * time.Second
+)
+
+// VmCacheConfig is the configuration of the VM cache.
+type VmCacheConfig struct {
+	// Policy is one of "none", "cache" and "clone"
+	Policy string
+	// TemplateFlavors is the sizes of the template VMs, "cpu:mem,..."
+	TemplateFlavors string
+	// PoolFlavors is the sizes and counts of the pooled VMs,
+	// "cpu:mem:min:max,..."
+	PoolFlavors string
+	// IdleTimeout is in seconds
+	IdleTimeout int
+}
+
+// vmFlavor is the number of vCPUs and the memory size in MB of a VM.
+type vmFlavor struct {
+	cpu int
+	mem int
+}
+
+func (f vmFlavor) String() string {
+	return fmt.Sprintf("%d:%d", f.cpu, f.mem)
+}
+
+// fits reports whether a VM of the flavor could be hot-added to the size.
+func (f vmFlavor) fits(cpu, mem int) bool {
+	return f.cpu <= cpu && f.mem <= mem
+}
+
+// closerThan reports whether the flavor is closer than o to the sizes they
+// both fit, that is, less resource is to be hot-added.
+func (f vmFlavor) closerThan(o vmFlavor) bool {
+	return f.cpu > o.cpu || (f.cpu == o.cpu && f.mem > o.mem)
+}
+
+// parseVmSizes parses the comma separated list of n colon separated
+// numbers, the first two of which are the cpu and memory and should be
+// positive.
+func parseVmSizes(s string, n int, format string) ([][]int, error) {
+	var result [][]int
+
+	for _, item := range strings.Split(s, ",") {
+		fields := strings.Split(strings.TrimSpace(item), ":")
+		if len(fields) != n {
+			return nil, fmt.Errorf("Invalid VM flavor %q, should be %s", item, format)
+		}
+		sizes := make([]int, n)
+		for i, f := range fields {
+			v, err := strconv.Atoi(f)
+			if err != nil || v < 0 || (i < 2 && v == 0) {
+				return nil, fmt.Errorf("Invalid VM flavor %q, should be %s", item, format)
+			}
+			sizes[i] = v
+		}
+		result = append(result, sizes)
+	}
+	return result, nil
+}
+
+// parseVmFlavors parses the flavors in the form of "cpu:mem,cpu:mem...",
+// the memory is in MB.
+func parseVmFlavors(s string) ([]vmFlavor, error) {
+	if strings.TrimSpace(s) == "" {
+		s = DefaultVmFlavors
+	}
+
+	sizes, err := parseVmSizes(s, 2, "cpu:mem")
+	if err != nil {
+		return nil, err
+	}
+	var flavors []vmFlavor
+	for _, size := range sizes {
+		flavors = append(flavors, vmFlavor{cpu: size[0], mem: size[1]})
+	}
+	return flavors, nil
+}
+
+// vmPool keeps the booted VMs of one flavor, there are at least min VMs
+// idle or booting, and at most max idle ones.
+type vmPool struct {
+	flavor   vmFlavor
+	min, max int
+	idle     []idleVm
+	booting  int
+	closed   bool
+}
+
+type idleVm struct {
+	vm    *hypervisor.Vm
+	since time.Time
+}
+
+// parseVmPools parses the pools in the form of "cpu:mem:min:max,...".
+func parseVmPools(s string) ([]*vmPool, error) {
+	if strings.TrimSpace(s) == "" {
+		s = DefaultVmPoolFlavors
+	}
+
+	sizes, err := parseVmSizes(s, 4, "cpu:mem:min:max")
+	if err != nil {
+		return nil, err
+	}
+	var pools []*vmPool
+	for _, size := range sizes {
+		if size[2] > size[3] {
+			return nil, fmt.Errorf("The min count %d of VM flavor %d:%d is larger than the max count %d", size[2], size[0], size[1], size[3])
+		}
+		pools = append(pools, &vmPool{
+			flavor: vmFlavor{cpu: size[0], mem: size[1]},
+			min:    size[2],
+			max:    size[3],
+		})
+	}
+	return pools, nil
+}
+
+// VmCache prepares the VMs for the new pods. With the "cache" policy it
+// keeps pools of booted VMs which are refilled in the background, with the
+// "clone" policy the VMs are cloned from the template VMs.
+type VmCache struct {
+	daemon *Daemon
+
+	sync.Mutex
+	config      VmCacheConfig
+	policy      string
+	templates   []*vmTemplate
+	pools       []*vmPool
+	idleTimeout time.Duration
+	hits        int64
+	misses      int64
+	kick        chan struct{}
+	stop        chan struct{}
+}
+
+func (c *VmCache) get(cpu, mem int) (*hypervisor.Vm, error) {
+	if cpu <= 0 {
+		cpu = defaultVmCpu
+	}
+	if mem <= 0 {
+		mem = defaultVmMem
+	}
+
+	c.Lock()
+	policy := c.policy
+	c.Unlock()
+
+	switch policy {
+	case "clone":
+		return c.clone(cpu, mem)
+	case "cache":
+		return c.getPooled(cpu, mem)
+	}
+	return c.daemon.StartVm("", cpu, mem, false, false, 0)
+}
+
+// getPooled takes a VM from the pool of the closest flavor, and hot-adds
+// it to the requested size. A new VM is booted if all the suitable pools
+// are empty.
+func (c *VmCache) getPooled(cpu, mem int) (*hypervisor.Vm, error) {
+	var vm *hypervisor.Vm
+
+	c.Lock()
+	var best *vmPool
+	for _, p := range c.pools {
+		if len(p.idle) == 0 || !p.flavor.fits(cpu, mem) {
+			continue
+		}
+		if best == nil || p.flavor.closerThan(best.flavor) {
+			best = p
+		}
+	}
+	if best != nil {
+		n := len(best.idle) - 1
+		vm = best.idle[n].vm
+		best.idle = best.idle[:n]
+		c.hits++
+	} else {
+		c.misses++
+	}
+	c.refill()
+	c.Unlock()
+
+	if vm == nil {
+		glog.V(1).Infof("No cached VM for cpu %d mem %d, boot a new VM", cpu, mem)
+		return c.daemon.StartVm("", cpu, mem, false, false, 0)
+	}
+	glog.V(2).Infof("Get cached Vm: %s", vm.Id)
+
+	err := hotAddCpuMem(vm, cpu, mem)
+	if err != nil {
+		c.daemon.KillVm(vm.Id)
+		vm = nil
+	}
+	return vm, err
+}
+
+// clone creates the VM from the closest template, the VM is booted from
+// scratch if there is no suitable template.
+func (c *VmCache) clone(cpu, mem int) (*hypervisor.Vm, error) {
+	c.Lock()
+	t := pickTemplate(c.templates, cpu, mem)
+	c.Unlock()
+	if t == nil {
+		glog.V(1).Infof("No VM template for cpu %d mem %d, boot a new VM", cpu, mem)
+		return c.daemon.StartVm("", cpu, mem, false, false, 0)
+	}
+
+	vm, err := t.clone(c.daemon)
+	if err != nil {
+		return nil, err
+	}
+
+	err = hotAddCpuMem(vm, cpu, mem)
+	if err != nil {
+		c.daemon.KillVm(vm.Id)
+		vm = nil
+	}
+	return vm, err
+}
+
+// createTemplate creates the template, the template which fails is dropped,
+// the VMs of its flavor are cloned from the smaller templates or booted from
+// scratch.
+func (c *VmCache) createTemplate(t *vmTemplate) {
+	if err := t.create(c.daemon); err == nil {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	for i, tpl := range c.templates {
+		if tpl == t {
+			c.templates = append(c.templates[:i:i], c.templates[i+1:]...)
+			glog.Warningf("VM template %s is dropped", t.flavor)
+			break
+		}
+	}
+}
+
+// put returns an unused VM to the pool of its flavor, it reports false if
+// there is no such pool or the pool is full.
+func (c *VmCache) put(vm *hypervisor.Vm) bool {
+	c.Lock()
+	defer c.Unlock()
+	for _, p := range c.pools {
+		if p.flavor.cpu == vm.Cpu && p.flavor.mem == vm.Mem && !p.closed && len(p.idle) < p.max {
+			glog.V(2).Info("VmCache return one vm")
+			p.idle = append(p.idle, idleVm{vm: vm, since: time.Now()})
+			return true
+		}
+	}
+	return false
+}
+
+// refill wakes up the background routine to fill the pools, the caller
+// must hold the lock of the cache.
+func (c *VmCache) refill() {
+	select {
+	case c.kick <- struct{}{}:
+	default:
+	}
+}
+
+// run fills the pools and evicts the idle VMs in the background until the
+// stop channel is closed.
+func (c *VmCache) run(kick, stop chan struct{}) {
+	ticker := time.NewTicker(vmPoolCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		c.fill()
+		select {
+		case <-kick:
+		case <-ticker.C:
+			c.evict()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// fill boots the VMs to bring every pool up to its min count.
+func (c *VmCache) fill() {
+	for _, p := range c.reserve() {
+		go c.boot(p)
+	}
+}
+
+// reserve admits the VMs to be booted for the pools below their min count,
+// and counts them as booting. The pools are left short when the resource of
+// the host is insufficient, they are filled again later.
+func (c *VmCache) reserve() []*vmPool {
+	var booting []*vmPool
+
+	c.Lock()
+	pools := c.pools
+	c.Unlock()
+
+	for _, p := range pools {
+		for {
+			c.Lock()
+			short := !p.closed && len(p.idle)+p.booting < p.min
+			c.Unlock()
+			if !short {
+				break
+			}
+
+			// the admission sums up the cached VMs, the cache must
+			// not be locked
+			release, err := c.daemon.admit("vmcache-"+p.flavor.String(), allocation{cpus: p.flavor.cpu, mem: p.flavor.mem})
+			if err != nil {
+				glog.V(1).Infof("VmCache can't fill %s: %s", p.flavor, err.Error())
+				break
+			}
+			c.Lock()
+			p.booting++
+			c.Unlock()
+			release()
+			booting = append(booting, p)
+		}
+	}
+	return booting
+}
+
+func (c *VmCache) boot(p *vmPool) {
+	vm, err := c.daemon.StartVm("", p.flavor.cpu, p.flavor.mem, false, true, 0)
+	if err == nil {
+		if err = c.daemon.WaitVmStart(vm); err != nil {
+			c.daemon.KillVm(vm.Id)
+		}
+	}
+
+	c.Lock()
+	p.booting--
+	if err == nil && p.closed {
+		err = fmt.Errorf("the pool is closed")
+		c.daemon.KillVm(vm.Id)
+	}
+	if err == nil {
+		p.idle = append(p.idle, idleVm{vm: vm, since: time.Now()})
+	}
+	c.Unlock()
+
+	if err != nil {
+		glog.Warningf("VmCache fills %s failed: %s", p.flavor, err.Error())
+	}
+}
+
+// evict kills the VMs above the min count of the pools which have been idle
+// longer than the idle timeout.
+func (c *VmCache) evict() {
+	var victims []*hypervisor.Vm
+
+	c.Lock()
+	now := time.Now()
+	for _, p := range c.pools {
+		for len(p.idle) > p.min && now.Sub(p.idle[0].since) > c.idleTimeout {
+			victims = append(victims, p.idle[0].vm)
+			p.idle = p.idle[1:]
+		}
+	}
+	c.Unlock()
+
+	for _, vm := range victims {
+		glog.V(1).Infof("Evict the idle VM %s", vm.Id)
+		c.daemon.KillVm(vm.Id)
+	}
+}
+
+// allocated returns the resource of the idle and the booting VMs in the
+// pools, pods of the allocation is the number of the VMs.
+func (c *VmCache) allocated() allocation {
+	c.Lock()
+	defer c.Unlock()
+
+	var a allocation
+	for _, p := range c.pools {
+		n := len(p.idle) + p.booting
+		a.add(allocation{cpus: n * p.flavor.cpu, mem: n * p.flavor.mem, pods: n})
+	}
+	return a
+}
+
+// hotAddCpuMem hotplugs cpu and memory to the VM to the given size.
+func hotAddCpuMem(vm *hypervisor.Vm, cpu, mem int) error {
+	var needOnline bool = false
+	if vm.Cpu < cpu {
+		needOnline = true
+		glog.Infof("HotAddCpu for Vm %s", vm.Id)
+		if err := vm.AddCpu(cpu); err != nil {
+			glog.Errorf("HotAddCpu failed: %s", err.Error())
+			return err
+		}
+	}
+	if vm.Mem < mem {
+		needOnline = true
+		glog.Infof("HotAddMem for Vm %s", vm.Id)
+		if err := vm.AddMem(mem); err != nil {
+			glog.Errorf("HotAddMem failed: %s", err.Error())
+			return err
+		}
+	}
+	if needOnline {
+		glog.Infof("OnlineCpuMem for Vm %s", vm.Id)
+		return vm.OnlineCpuMem()
+	}
+	return nil
+}
+
+// InitVmCache sets up the VM cache according to the config.
+func (daemon *Daemon) InitVmCache(config *VmCacheConfig) error {
+	c := &daemon.vmCache
+	c.daemon = daemon
+
+	c.Lock()
+	c.config = *config
+	c.Unlock()
+
+	policy := config.Policy
+	if hypervisor.HDriver.SupportLazyMode() {
+		policy = "none"
+	}
+
+	switch policy {
+	case "none", "":
+		c.Lock()
+		c.policy = "none"
+		c.Unlock()
+		return nil
+	case "cache":
+		pools, err := parseVmPools(config.PoolFlavors)
+		if err != nil {
+			return err
+		}
+		timeout := config.IdleTimeout
+		if timeout <= 0 {
+			timeout = DefaultVmIdleTimeout
+		}
+
+		c.Lock()
+		c.policy = policy
+		c.pools = pools
+		c.idleTimeout = time.Duration(timeout) * time.Second
+		c.kick = make(chan struct{}, 1)
+		c.stop = make(chan struct{})
+		go c.run(c.kick, c.stop)
+		c.Unlock()
+		return nil
+	case "clone":
+		flavors, err := parseVmFlavors(config.TemplateFlavors)
+		if err != nil {
+			return err
+		}
+		var templates []*vmTemplate
+		for _, f := range flavors {
+			templates = append(templates, newVmTemplate(f))
+		}
+
+		c.Lock()
+		c.policy = policy
+		c.templates = templates
+		c.Unlock()
+
+		// the VMs are booted from scratch until the templates are ready
+		for _, t := range templates {
+			go c.createTemplate(t)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown cache policy: %s", policy)
+	}
+}
+
+// ReloadVmCache replaces the VM cache with a new one of the config, the
+// pooled VMs and the templates of the old config are destroyed, the VMs
+// already taken by the pods are not affected. It returns false if the
+// config is not changed. The current cache is kept if the config is
+// invalid.
+func (daemon *Daemon) ReloadVmCache(config *VmCacheConfig) (bool, error) {
+	c := &daemon.vmCache
+
+	c.Lock()
+	current := c.config
+	c.Unlock()
+	if current == *config {
+		return false, nil
+	}
+
+	var err error
+	switch config.Policy {
+	case "none", "":
+	case "cache":
+		_, err = parseVmPools(config.PoolFlavors)
+	case "clone":
+		_, err = parseVmFlavors(config.TemplateFlavors)
+	default:
+		err = fmt.Errorf("unknown cache policy: %s", config.Policy)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	daemon.DestroyVmCache()
+	c.Lock()
+	c.pools = nil
+	c.templates = nil
+	c.Unlock()
+	return true, daemon.InitVmCache(config)
+}
+
+// DestroyVmCache stops refilling the pools, kills the pooled VMs and
+// removes the VM templates.
+func (daemon *Daemon) DestroyVmCache() {
+	c := &daemon.vmCache
+	var victims []*hypervisor.Vm
+
+	c.Lock()
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+	for _, p := range c.pools {
+		p.closed = true
+		for _, v := range p.idle {
+			victims = append(victims, v.vm)
+		}
+		p.idle = nil
+	}
+	templates := c.templates
+	c.Unlock()
+
+	for _, vm := range victims {
+		daemon.KillVm(vm.Id)
+	}
+	for _, t := range templates {
+		t.destroy()
+	}
+}
+
+// VmCacheInfo returns the cache policy, the status of the VM pools and the
+// VM templates.
+func (daemon *Daemon) VmCacheInfo() (string, *apitypes.VmPoolInfo, []apitypes.VmTemplateInfo) {
+	c := &daemon.vmCache
+
+	c.Lock()
+	defer c.Unlock()
+
+	templates := []apitypes.VmTemplateInfo{}
+	for _, t := range c.templates {
+		templates = append(templates, t.info())
+	}
+
+	if c.policy != "cache" {
+		return c.policy, nil, templates
+	}
+	pool := &apitypes.VmPoolInfo{
+		Hits:        c.hits,
+		Misses:      c.misses,
+		IdleTimeout: int(c.idleTimeout / time.Second),
+		Flavors:     []apitypes.VmPoolFlavorInfo{},
+	}
+	for _, p := range c.pools {
+		pool.Flavors = append(pool.Flavors, apitypes.VmPoolFlavorInfo{
+			Cpu:     p.flavor.cpu,
+			Memory:  p.flavor.mem,
+			Min:     p.min,
+			Max:     p.max,
+			Idle:    len(p.idle),
+			Booting: p.booting,
+		})
+	}
+	return c.policy, pool, templates
+}
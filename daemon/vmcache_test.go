@@ -0,0 +1,180 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperhq/hyper/daemon/fakedriver"
+	"github.com/hyperhq/runv/hypervisor"
+)
+
+func TestParseVmFlavors(t *testing.T) {
+	flavors, err := parseVmFlavors("1:128, 2:512")
+	if err != nil {
+		t.Fatalf("failed to parse flavors: %v", err)
+	}
+	if len(flavors) != 2 || flavors[0] != (vmFlavor{1, 128}) || flavors[1] != (vmFlavor{2, 512}) {
+		t.Fatalf("unexpected flavors %v", flavors)
+	}
+
+	flavors, err = parseVmFlavors("")
+	if err != nil || len(flavors) != 1 || flavors[0] != (vmFlavor{1, 128}) {
+		t.Fatalf("unexpected default flavors %v, %v", flavors, err)
+	}
+
+	for _, s := range []string{"1", "a:128", "1:0", "1:128,,2:256"} {
+		if _, err := parseVmFlavors(s); err == nil {
+			t.Errorf("expect error for %q", s)
+		}
+	}
+}
+
+func TestParseVmPools(t *testing.T) {
+	pools, err := parseVmPools("1:128:2:4,2:512:1:1")
+	if err != nil {
+		t.Fatalf("failed to parse pools: %v", err)
+	}
+	if len(pools) != 2 || pools[1].flavor != (vmFlavor{2, 512}) || pools[1].min != 1 || pools[1].max != 1 {
+		t.Fatalf("unexpected pools %v", pools)
+	}
+
+	for _, s := range []string{"1:128", "1:128:4:2", "1:128:x:2"} {
+		if _, err := parseVmPools(s); err == nil {
+			t.Errorf("expect error for %q", s)
+		}
+	}
+}
+
+func newTestVmCache(pools string) (*VmCache, error) {
+	d := &Daemon{VmList: NewVmList()}
+	c := &d.vmCache
+	c.daemon = d
+	c.policy = "cache"
+	c.idleTimeout = time.Minute
+
+	p, err := parseVmPools(pools)
+	if err != nil {
+		return nil, err
+	}
+	c.pools = p
+	return c, nil
+}
+
+func TestVmPoolGet(t *testing.T) {
+	c, err := newTestVmCache("1:128:0:2,2:512:0:2")
+	if err != nil {
+		t.Fatalf("failed to create the cache: %v", err)
+	}
+	c.put(&hypervisor.Vm{Id: "vm-small", Cpu: 1, Mem: 128})
+	c.put(&hypervisor.Vm{Id: "vm-large", Cpu: 2, Mem: 512})
+
+	vm, err := c.get(2, 1024)
+	if err != nil || vm.Id != "vm-large" {
+		t.Fatalf("expect the VM of the closest flavor, got %v, %v", vm, err)
+	}
+	vm, err = c.get(1, 256)
+	if err != nil || vm.Id != "vm-small" {
+		t.Fatalf("expect the small VM, got %v, %v", vm, err)
+	}
+
+	policy, info, _ := c.daemon.VmCacheInfo()
+	if policy != "cache" || info == nil || info.Hits != 2 || info.Misses != 0 {
+		t.Fatalf("unexpected pool info %s %#v", policy, info)
+	}
+}
+
+func TestVmPoolEvict(t *testing.T) {
+	c, err := newTestVmCache("1:128:1:3")
+	if err != nil {
+		t.Fatalf("failed to create the cache: %v", err)
+	}
+	for _, id := range []string{"vm-1", "vm-2", "vm-3", "vm-4"} {
+		c.put(&hypervisor.Vm{Id: id, Cpu: 1, Mem: 128})
+	}
+	p := c.pools[0]
+	if len(p.idle) != 3 {
+		t.Fatalf("expect the pool is full with 3 VMs, got %d", len(p.idle))
+	}
+
+	p.idle[0].since = time.Now().Add(-2 * time.Minute)
+	p.idle[1].since = time.Now().Add(-2 * time.Minute)
+	c.evict()
+	if len(p.idle) != 1 || p.idle[0].vm.Id != "vm-3" {
+		t.Fatalf("expect only vm-3 is kept, got %v", p.idle)
+	}
+
+	// the VMs of the min count are never evicted
+	p.idle[0].since = time.Now().Add(-2 * time.Minute)
+	c.evict()
+	if len(p.idle) != 1 {
+		t.Fatalf("the last VM should not be evicted")
+	}
+}
+
+func TestRecycleVm(t *testing.T) {
+	c, err := newTestVmCache("1:128:0:1")
+	if err != nil {
+		t.Fatalf("failed to create the cache: %v", err)
+	}
+	d := c.daemon
+	d.PodList = NewPodList()
+	for _, id := range []string{"vm-1", "vm-2"} {
+		d.AddVm(&hypervisor.Vm{Id: id, Cpu: 1, Mem: 128})
+	}
+
+	if _, _, err := d.recycleVm("vm-1"); err != nil {
+		t.Fatal(err)
+	}
+	if p := c.pools[0]; len(p.idle) != 1 || p.idle[0].vm.Id != "vm-1" {
+		t.Fatalf("expect vm-1 to be returned to the pool, got %v", p.idle)
+	}
+	if _, ok := d.VmList.Get("vm-1"); !ok {
+		t.Fatal("the pooled VM should be kept")
+	}
+
+	// the pool is full
+	if _, _, err := d.recycleVm("vm-2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.VmList.Get("vm-2"); ok {
+		t.Fatal("expect vm-2 to be killed")
+	}
+
+	p := newTestPod("pod-vm")
+	p.status.Vm = "vm-1"
+	d.PodList.Put(p)
+	if !d.vmInUse("vm-1") || d.vmInUse("vm-2") {
+		t.Fatal("expect only vm-1 to be in use")
+	}
+}
+
+func TestReloadVmCache(t *testing.T) {
+	oldDriver := hypervisor.HDriver
+	hypervisor.HDriver = fakedriver.InitDriver()
+	defer func() { hypervisor.HDriver = oldDriver }()
+
+	c, err := newTestVmCache("1:128:0:2")
+	if err != nil {
+		t.Fatalf("failed to create the cache: %v", err)
+	}
+	c.config = VmCacheConfig{Policy: "cache", PoolFlavors: "1:128:0:2"}
+	c.put(&hypervisor.Vm{Id: "vm-1", Cpu: 1, Mem: 128})
+
+	if changed, err := c.daemon.ReloadVmCache(&VmCacheConfig{Policy: "cache", PoolFlavors: "1:128:0:2"}); changed || err != nil {
+		t.Fatalf("expect the same config to be ignored, got %v, %v", changed, err)
+	}
+	if _, err := c.daemon.ReloadVmCache(&VmCacheConfig{Policy: "cache", PoolFlavors: "1:128:4:2"}); err == nil {
+		t.Fatal("expect error for the invalid pools")
+	}
+	if len(c.pools) != 1 || len(c.pools[0].idle) != 1 {
+		t.Fatal("the cache is changed by the invalid config")
+	}
+
+	if changed, err := c.daemon.ReloadVmCache(&VmCacheConfig{Policy: "none"}); !changed || err != nil {
+		t.Fatalf("failed to reload the cache: %v, %v", changed, err)
+	}
+	policy, info, _ := c.daemon.VmCacheInfo()
+	if policy != "none" || info != nil || c.pools != nil {
+		t.Fatalf("expect the pools to be destroyed, got %s %#v", policy, info)
+	}
+}
@@ -0,0 +1,107 @@
+package daemon
+
+import (
+	"sync"
+
+	"github.com/hyperhq/runv/hypervisor"
+	"github.com/hyperhq/runv/hypervisor/pod"
+)
+
+// VmList is the registry of the VMs of the daemon. Besides the running VMs
+// it keeps the IDs reserved for the VMs being launched, so that two VMs
+// never get the same ID.
+type VmList struct {
+	vms      map[string]*hypervisor.Vm
+	reserved map[string]bool
+	mu       sync.RWMutex
+}
+
+func NewVmList() *VmList {
+	return &VmList{
+		vms:      make(map[string]*hypervisor.Vm),
+		reserved: make(map[string]bool),
+	}
+}
+
+func (vl *VmList) Get(id string) (*hypervisor.Vm, bool) {
+	vl.mu.RLock()
+	defer vl.mu.RUnlock()
+
+	vm, ok := vl.vms[id]
+	return vm, ok
+}
+
+// Put registers the VM, and drops the reservation of its ID if any.
+func (vl *VmList) Put(vm *hypervisor.Vm) {
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+
+	vl.vms[vm.Id] = vm
+	delete(vl.reserved, vm.Id)
+}
+
+func (vl *VmList) Delete(id string) {
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+
+	delete(vl.vms, id)
+	delete(vl.reserved, id)
+}
+
+// Reserve reserves the ID for a VM to be launched, it fails if the ID is
+// already used or reserved.
+func (vl *VmList) Reserve(id string) bool {
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+
+	if _, ok := vl.vms[id]; ok || vl.reserved[id] {
+		return false
+	}
+	vl.reserved[id] = true
+	return true
+}
+
+// Release drops the reservation of an ID whose VM failed to launch.
+func (vl *VmList) Release(id string) {
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+
+	delete(vl.reserved, id)
+}
+
+// NewId generates and reserves an unused VM ID with the given prefix.
+func (vl *VmList) NewId(prefix string) string {
+	for {
+		id := prefix + pod.RandStr(10, "alpha")
+		if vl.Reserve(id) {
+			return id
+		}
+	}
+}
+
+func (vl *VmList) Len() int {
+	vl.mu.RLock()
+	defer vl.mu.RUnlock()
+
+	return len(vl.vms)
+}
+
+type VmOp func(*hypervisor.Vm) error
+
+// Foreach calls fn on every VM in the list without holding the lock of the
+// list, so fn is free to add or remove VMs.
+func (vl *VmList) Foreach(fn VmOp) error {
+	vl.mu.RLock()
+	vms := make([]*hypervisor.Vm, 0, len(vl.vms))
+	for _, vm := range vl.vms {
+		vms = append(vms, vm)
+	}
+	vl.mu.RUnlock()
+
+	for _, vm := range vms {
+		if err := fn(vm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package daemon
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/hyperhq/runv/hypervisor"
+)
+
+func TestVmListReserve(t *testing.T) {
+	vl := NewVmList()
+
+	if !vl.Reserve("vm-a") {
+		t.Fatalf("failed to reserve vm-a")
+	}
+	if vl.Reserve("vm-a") {
+		t.Fatalf("vm-a should not be reserved twice")
+	}
+	vl.Put(&hypervisor.Vm{Id: "vm-a"})
+	if vl.Reserve("vm-a") {
+		t.Fatalf("the ID of a registered VM should not be reserved")
+	}
+	vl.Delete("vm-a")
+	if !vl.Reserve("vm-a") {
+		t.Fatalf("failed to reserve vm-a after it is deleted")
+	}
+	vl.Release("vm-a")
+	if !vl.Reserve("vm-a") {
+		t.Fatalf("failed to reserve vm-a after it is released")
+	}
+}
+
+func TestVmListConcurrent(t *testing.T) {
+	vl := NewVmList()
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		ids = make(map[string]bool)
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				id := vl.NewId("vm-")
+				mu.Lock()
+				if ids[id] {
+					t.Errorf("duplicated VM ID %s", id)
+				}
+				ids[id] = true
+				mu.Unlock()
+
+				vl.Put(&hypervisor.Vm{Id: id})
+				if _, ok := vl.Get(id); !ok {
+					t.Errorf("can not get VM %s", id)
+				}
+				if j%2 == 0 {
+					vl.Delete(id)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				vl.Len()
+				vl.Foreach(func(vm *hypervisor.Vm) error {
+					return nil
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := vl.Len(); n != 8*25 {
+		t.Fatalf("expect %d VMs, got %d", 8*25, n)
+	}
+}
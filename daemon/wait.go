@@ -0,0 +1,158 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	apitypes "github.com/hyperhq/hyper/types"
+	"github.com/hyperhq/runv/hypervisor/types"
+)
+
+func podPhase(status uint) string {
+	switch status {
+	case types.S_POD_CREATED:
+		return "Pending"
+	case types.S_POD_RUNNING:
+		return "Running"
+	case types.S_POD_SUCCEEDED:
+		return "Succeeded"
+	case types.S_POD_FAILED:
+		return "Failed"
+	}
+	return "Unknown"
+}
+
+// phaseStatus is the reverse of podPhase, the phases of the containers are
+// in lower case.
+func phaseStatus(phase string) (uint, bool) {
+	for _, status := range []uint{types.S_POD_CREATED, types.S_POD_RUNNING, types.S_POD_SUCCEEDED, types.S_POD_FAILED} {
+		if strings.EqualFold(podPhase(status), phase) {
+			return status, true
+		}
+	}
+	return 0, false
+}
+
+func (p *Pod) exitStatus() *apitypes.PodExit {
+	result := &apitypes.PodExit{
+		PodID:      p.id,
+		PodName:    p.status.Name,
+		Phase:      podPhase(p.status.Status),
+		Containers: []apitypes.ContainerExit{},
+	}
+	for _, c := range p.status.Containers {
+		result.Containers = append(result.Containers, apitypes.ContainerExit{
+			Name:        c.Name,
+			ContainerID: c.Id,
+			Phase:       strings.ToLower(podPhase(uint(c.Status))),
+			ExitCode:    c.ExitCode,
+		})
+	}
+	return result
+}
+
+// wait blocks until the pod finishes, a timeout of zero means waiting
+// forever. A pod which is created but not started yet is waited for until
+// it is started and finishes, a pod which has finished returns immediately.
+func (p *Pod) wait(timeout int) error {
+	d := time.Duration(timeout) * time.Second
+	if timeout <= 0 {
+		d = time.Duration(1<<63 - 1)
+	}
+	if !p.waitFinished(d) {
+		return fmt.Errorf("Timeout after waiting pod %s for %d seconds", p.id, timeout)
+	}
+
+	p.RLock()
+	defer p.RUnlock()
+	if p.status.Status == types.S_POD_CREATED {
+		return fmt.Errorf("Pod %s is removed or failed to start", p.id)
+	}
+	return nil
+}
+
+func podExitKey(podId string) string {
+	return fmt.Sprintf("exit-%s", podId)
+}
+
+// SavePodExit saves the exit status of the pod, so that the exit codes of
+// its containers survive the restart of hyperd.
+func (daemon *Daemon) SavePodExit(p *Pod) error {
+	data, err := json.Marshal(p.exitStatus())
+	if err != nil {
+		return err
+	}
+	return daemon.db.Put([]byte(podExitKey(p.id)), data, nil)
+}
+
+func (daemon *Daemon) GetPodExit(podId string) (*apitypes.PodExit, error) {
+	data, err := daemon.db.Get([]byte(podExitKey(podId)), nil)
+	if err != nil {
+		return nil, err
+	}
+	var result apitypes.PodExit
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (daemon *Daemon) DeletePodExit(podId string) error {
+	return daemon.db.Delete([]byte(podExitKey(podId)), nil)
+}
+
+// loadPodExit restores the saved exit status of the pod loaded from the db,
+// the pod without saved exit status is left as created.
+func (daemon *Daemon) loadPodExit(p *Pod) {
+	result, err := daemon.GetPodExit(p.id)
+	if err != nil {
+		return
+	}
+
+	status, ok := phaseStatus(result.Phase)
+	if !ok || status == types.S_POD_CREATED || status == types.S_POD_RUNNING {
+		return
+	}
+	p.status.Status = status
+	for _, c := range p.status.Containers {
+		c.Status = uint32(status)
+		for _, e := range result.Containers {
+			if s, ok := phaseStatus(e.Phase); ok && e.Name == c.Name {
+				c.Status = uint32(s)
+				c.ExitCode = e.ExitCode
+			}
+		}
+	}
+	p.notifyFinished()
+}
+
+// WaitPod waits for the pod to reach a terminal state and returns the exit
+// codes of its containers.
+func (daemon *Daemon) WaitPod(podName string, timeout int) (*apitypes.PodExit, error) {
+	pod, err := daemon.ResolvePod(podName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pod.wait(timeout); err != nil {
+		return nil, err
+	}
+	return pod.exitStatus(), nil
+}
+
+// WaitContainer waits for the pod of the container to reach a terminal state
+// and returns the exit code of the container.
+func (daemon *Daemon) WaitContainer(name string, timeout int) (*apitypes.ContainerExit, error) {
+	pod, idx, err := daemon.ResolveContainer(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pod.wait(timeout); err != nil {
+		return nil, err
+	}
+	result := pod.exitStatus().Containers[idx]
+	return &result, nil
+}
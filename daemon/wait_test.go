@@ -0,0 +1,114 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperhq/runv/hypervisor"
+	"github.com/hyperhq/runv/hypervisor/types"
+)
+
+func TestPodWait(t *testing.T) {
+	p := &Pod{
+		id: "pod-test",
+		status: &hypervisor.PodStatus{
+			Status: types.S_POD_RUNNING,
+			Containers: []*hypervisor.Container{
+				{Id: "c1", Name: "/c1", Status: types.S_POD_RUNNING},
+			},
+		},
+		finished: make(chan struct{}),
+	}
+
+	if err := p.wait(1); err == nil {
+		t.Fatalf("expect timeout waiting a running pod")
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		p.status.Status = types.S_POD_FAILED
+		p.status.Containers[0].Status = types.S_POD_FAILED
+		p.status.Containers[0].ExitCode = 2
+		p.notifyFinished()
+	}()
+	if err := p.wait(0); err != nil {
+		t.Fatalf("failed to wait the pod: %v", err)
+	}
+
+	result := p.exitStatus()
+	if result.Phase != "Failed" || len(result.Containers) != 1 ||
+		result.Containers[0].ExitCode != 2 || result.Containers[0].Phase != "failed" {
+		t.Fatalf("unexpected exit status %#v", result)
+	}
+
+	// a finished pod returns immediately
+	if err := p.wait(1); err != nil {
+		t.Fatalf("failed to wait the finished pod: %v", err)
+	}
+}
+
+func TestPodWaitNotStarted(t *testing.T) {
+	p := newTestPod("pod-created")
+	p.status.Status = types.S_POD_CREATED
+	p.status.SetContainerStatus(types.S_POD_CREATED)
+	p.finished = make(chan struct{})
+
+	if err := p.wait(1); err == nil {
+		t.Fatalf("expect the created pod to be waited for")
+	}
+
+	// the waiters keep waiting when the pod is started
+	p.resetFinished()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		p.status.Status = types.S_POD_SUCCEEDED
+		p.status.SetContainerStatus(types.S_POD_SUCCEEDED)
+		p.notifyFinished()
+	}()
+	if err := p.wait(0); err != nil {
+		t.Fatalf("failed to wait the pod: %v", err)
+	}
+
+	removed := newTestPod("pod-removed")
+	removed.status.Status = types.S_POD_CREATED
+	removed.finished = make(chan struct{})
+	go removed.notifyFinished()
+	if err := removed.wait(0); err == nil {
+		t.Fatalf("expect an error waiting the removed pod")
+	}
+}
+
+func TestPodExitPersisted(t *testing.T) {
+	d, cleanup := newTestDBDaemon(t)
+	defer cleanup()
+
+	p := newTestPod("pod-exit")
+	p.status.Status = types.S_POD_FAILED
+	p.status.Containers[0].Status = types.S_POD_FAILED
+	p.status.Containers[0].ExitCode = 3
+	if err := d.SavePodExit(p); err != nil {
+		t.Fatal(err)
+	}
+
+	// the pod loaded after hyperd restarts
+	loaded := newTestPod("pod-exit")
+	loaded.status.Status = types.S_POD_CREATED
+	loaded.status.SetContainerStatus(types.S_POD_CREATED)
+	loaded.finished = make(chan struct{})
+	d.loadPodExit(loaded)
+
+	if err := loaded.wait(1); err != nil {
+		t.Fatalf("failed to wait the loaded pod: %v", err)
+	}
+	result := loaded.exitStatus()
+	if result.Phase != "Failed" || result.Containers[0].Phase != "failed" || result.Containers[0].ExitCode != 3 {
+		t.Fatalf("unexpected exit status %#v", result)
+	}
+
+	if err := d.DeletePodExit(p.id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.GetPodExit(p.id); err == nil {
+		t.Fatalf("expect the exit status to be deleted")
+	}
+}
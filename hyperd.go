@@ -5,18 +5,22 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"sort"
 	"strings"
 	"syscall"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/Unknwon/goconfig"
 	"github.com/docker/docker/opts"
 	"github.com/docker/docker/pkg/reexec"
 	"github.com/golang/glog"
 	"github.com/hyperhq/hyper/daemon"
+	"github.com/hyperhq/hyper/daemon/driverloader"
+	// the fake driver simulates the VMs in hyperd, for testing only
+	_ "github.com/hyperhq/hyper/daemon/fakedriver"
 	"github.com/hyperhq/hyper/daemon/graphdriver/vbox"
 	"github.com/hyperhq/hyper/server"
 	"github.com/hyperhq/hyper/utils"
-	"github.com/hyperhq/runv/driverloader"
 	"github.com/hyperhq/runv/hypervisor"
 
 	runvutils "github.com/hyperhq/runv/lib/utils"
@@ -29,6 +33,9 @@ type Options struct {
 	Hosts              string
 	Mirrors            string
 	InsecureRegistries string
+	// LogLevelFlagged is set if the log level is given by --v, which
+	// overrides the LogLevel of the config file
+	LogLevelFlagged bool
 }
 
 func main() {
@@ -76,6 +83,11 @@ func main() {
 		Mirrors:            *flMirrors,
 		InsecureRegistries: *flInsecureRegistries,
 	}
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "v" {
+			opt.LogLevelFlagged = true
+		}
+	})
 
 	mainDaemon(opt)
 }
@@ -137,8 +149,18 @@ func mainDaemon(opt *Options) {
 		}
 	}
 
+	if !opt.LogLevelFlagged {
+		level, _ := cfg.GetValue(goconfig.DEFAULT_SECTION, "LogLevel")
+		if err := setLogLevel(level); err != nil {
+			glog.Errorf(err.Error())
+			return
+		}
+	}
+
 	storageDriver, _ := cfg.GetValue(goconfig.DEFAULT_SECTION, "StorageDriver")
-	daemon.InitDockerCfg(strings.Split(opt.Mirrors, ","), strings.Split(opt.InsecureRegistries, ","), storageDriver, hyperRoot)
+	mirrors, _ := cfg.GetValue(goconfig.DEFAULT_SECTION, "RegistryMirrors")
+	insecureRegistries, _ := cfg.GetValue(goconfig.DEFAULT_SECTION, "InsecureRegistries")
+	daemon.InitDockerCfg(splitList(opt.Mirrors, mirrors), splitList(opt.InsecureRegistries, insecureRegistries), storageDriver, hyperRoot)
 	d, err := daemon.NewDaemon(cfg)
 	if err != nil {
 		glog.Errorf("The hyperd create failed, %s", err.Error())
@@ -148,31 +170,9 @@ func mainDaemon(opt *Options) {
 	vbox.Register(d)
 
 	serverConfig := &server.Config{}
-
-	defaultHost := "unix:///var/run/hyper.sock"
-	Hosts := []string{defaultHost}
-
-	if opt.Hosts != "" {
-		Hosts = append(Hosts, opt.Hosts)
-	}
-	if d.Host != "" {
-		Hosts = append(Hosts, d.Host)
-	}
-
-	for i := 0; i < len(Hosts); i++ {
-		var err error
-		if Hosts[i], err = opts.ParseHost(defaultHost, Hosts[i]); err != nil {
-			glog.Errorf("error parsing -H %s : %v", Hosts[i], err)
-			return
-		}
-
-		protoAddr := Hosts[i]
-		protoAddrParts := strings.SplitN(protoAddr, "://", 2)
-		if len(protoAddrParts) != 2 {
-			glog.Errorf("bad format %s, expected PROTO://ADDR", protoAddr)
-			return
-		}
-		serverConfig.Addrs = append(serverConfig.Addrs, server.Addr{Proto: protoAddrParts[0], Addr: protoAddrParts[1]})
+	if serverConfig.Addrs, err = parseHosts(opt.Hosts, d.GetHost()); err != nil {
+		glog.Errorf(err.Error())
+		return
 	}
 
 	api, err := server.New(serverConfig)
@@ -189,10 +189,9 @@ func mainDaemon(opt *Options) {
 		glog.Warningf("%s", err.Error())
 		glog.Errorf("Please specify the correct and available hypervisor, such as 'kvm', 'qemu-kvm',  'libvirt', 'xen', 'qemu', 'vbox' or ''")
 		return
-	} else {
-		d.Hypervisor = driver
-		glog.Infof("The hypervisor's driver is %s", driver)
 	}
+	d.Hypervisor = driver
+	glog.Infof("The hypervisor's driver is %s", driver)
 
 	disableIptables := cfg.MustBool(goconfig.DEFAULT_SECTION, "DisableIptables", false)
 	if err = hypervisor.InitNetwork(d.BridgeIface, d.BridgeIP, disableIptables || opt.DisableIptables); err != nil {
@@ -216,8 +215,8 @@ func mainDaemon(opt *Options) {
 
 	stopAll := make(chan os.Signal, 1)
 	signal.Notify(stopAll, syscall.SIGINT, syscall.SIGTERM)
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, syscall.SIGHUP)
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
 
 	glog.V(0).Infof("Hyper daemon: %s %s",
 		utils.VERSION,
@@ -229,26 +228,227 @@ func mainDaemon(opt *Options) {
 		return
 	}
 
-	vmCachePolicy, _ := cfg.GetValue(goconfig.DEFAULT_SECTION, "VmCachePolicy")
-	d.InitVmCache(vmCachePolicy)
+	if _, err := d.PruneOrphans(); err != nil {
+		glog.Warningf("Fail to prune the orphaned resources: %s", err.Error())
+	}
+
+	if err := d.InitVmCache(vmCacheConfig(cfg)); err != nil {
+		glog.Warningf("Fail to init the VM cache: %s", err.Error())
+	}
+
+	admissionCfg := &daemon.AdmissionConfig{
+		MaxCpus:        cfg.MustInt(goconfig.DEFAULT_SECTION, "MaxCpus", 0),
+		MaxMemory:      cfg.MustInt(goconfig.DEFAULT_SECTION, "MaxMemory", 0),
+		ReservedMemory: cfg.MustInt(goconfig.DEFAULT_SECTION, "ReservedMemory", 0),
+		MaxPods:        cfg.MustInt(goconfig.DEFAULT_SECTION, "MaxPods", daemon.DefaultMaxPods),
+	}
+	if err := d.InitAdmission(admissionCfg); err != nil {
+		glog.Errorf("Fail to init the admission control: %s", err.Error())
+		return
+	}
 
 	// Daemon is fully initialized and handling API traffic
 	// Wait for serve API job to complete
-	select {
-	case errAPI := <-serveAPIWait:
-		// If we have an error here it is unique to API (as daemonErr would have
-		// exited the daemon process above)
-		if errAPI != nil {
-			glog.Warningf("Shutting down due to ServeAPI error: %v", errAPI)
+wait:
+	for {
+		select {
+		case errAPI := <-serveAPIWait:
+			// If we have an error here it is unique to API (as daemonErr would have
+			// exited the daemon process above)
+			if errAPI != nil {
+				glog.Warningf("Shutting down due to ServeAPI error: %v", errAPI)
+			}
+			break wait
+		case <-reload:
+			cfg = reloadConfig(config, cfg, opt, d, api)
+		case <-stopAll:
+			// keep the VMs running when hyperd exits, they are reconnected
+			// after it is restarted or upgraded
+			if cfg.MustBool(goconfig.DEFAULT_SECTION, "LiveRestore", false) {
+				d.DestroyAndKeepVm()
+			} else {
+				d.DestroyAllVm()
+			}
+			break wait
 		}
-		break
-	case <-stop:
-		d.DestroyAndKeepVm()
-		break
-	case <-stopAll:
-		d.DestroyAllVm()
-		break
 	}
 	api.Close()
 	d.Shutdown()
 }
+
+// reloadableKeys are the settings of the default section which are applied
+// by reloadConfig, the other settings need hyperd to be restarted.
+var reloadableKeys = map[string]bool{
+	"Logger":             true,
+	"VmCachePolicy":      true,
+	"VmTemplateFlavors":  true,
+	"VmPoolFlavors":      true,
+	"VmPoolIdleTimeout":  true,
+	"RegistryMirrors":    true,
+	"InsecureRegistries": true,
+	"Host":               true,
+	"LogLevel":           true,
+	"LiveRestore":        true,
+	"EnableMetrics":      true,
+}
+
+// reloadConfig re-reads the config file on SIGHUP and applies the settings
+// which are safe to change at runtime, the running pods are not affected.
+// The changes of the other settings are reported. It returns the config in
+// use, which is the old one if the file can't be read.
+func reloadConfig(file string, old *goconfig.ConfigFile, opt *Options, d *daemon.Daemon, api *server.Server) *goconfig.ConfigFile {
+	cfg, err := goconfig.LoadConfigFile(file)
+	if err != nil {
+		glog.Errorf("Reload config file (%s) failed, %s", file, err.Error())
+		return old
+	}
+	glog.Infof("Reload the config file %s", file)
+
+	for _, key := range restartKeys(old, cfg) {
+		glog.Warningf("The change of %s takes effect after hyperd is restarted", key)
+	}
+
+	if !opt.LogLevelFlagged {
+		level, _ := cfg.GetValue(goconfig.DEFAULT_SECTION, "LogLevel")
+		if err := setLogLevel(level); err != nil {
+			glog.Errorf(err.Error())
+		}
+	}
+
+	defaultLog, _ := cfg.GetValue(goconfig.DEFAULT_SECTION, "Logger")
+	defaultLogCfg, _ := cfg.GetSection("Log")
+	d.DefaultLogCfg(defaultLog, defaultLogCfg)
+
+	mirrors, _ := cfg.GetValue(goconfig.DEFAULT_SECTION, "RegistryMirrors")
+	insecureRegistries, _ := cfg.GetValue(goconfig.DEFAULT_SECTION, "InsecureRegistries")
+	if err := daemon.ReloadRegistry(splitList(opt.Mirrors, mirrors), splitList(opt.InsecureRegistries, insecureRegistries)); err != nil {
+		glog.Errorf("Fail to reload the registries: %s", err.Error())
+	}
+
+	host, _ := cfg.GetValue(goconfig.DEFAULT_SECTION, "Host")
+	if addrs, err := parseHosts(opt.Hosts, host); err != nil {
+		glog.Errorf(err.Error())
+	} else if err := api.UpdateAddrs(addrs); err != nil {
+		glog.Errorf("Fail to listen on the hosts: %s", err.Error())
+	} else {
+		d.SetHost(host)
+	}
+
+	d.EnableMetrics(cfg.MustBool(goconfig.DEFAULT_SECTION, "EnableMetrics", false))
+
+	if changed, err := d.ReloadVmCache(vmCacheConfig(cfg)); err != nil {
+		glog.Errorf("Fail to reload the VM cache: %s", err.Error())
+	} else if changed {
+		glog.Infof("The VM cache is reloaded")
+	}
+
+	return cfg
+}
+
+// restartKeys returns the settings which are changed but can't be applied
+// at runtime, the keys of the sections other than the default one are in
+// the form of "section.key".
+func restartKeys(old, cfg *goconfig.ConfigFile) []string {
+	var keys []string
+
+	sections := map[string]bool{}
+	for _, section := range append(old.GetSectionList(), cfg.GetSectionList()...) {
+		sections[section] = true
+	}
+	for section := range sections {
+		if section == "Log" {
+			continue
+		}
+		oldValues, _ := old.GetSection(section)
+		values, _ := cfg.GetSection(section)
+		for _, pair := range []struct{ a, b map[string]string }{{oldValues, values}, {values, oldValues}} {
+			for key, value := range pair.a {
+				name := section + "." + key
+				if section == goconfig.DEFAULT_SECTION {
+					if reloadableKeys[key] {
+						continue
+					}
+					name = key
+				}
+				if v, ok := pair.b[key]; (!ok || v != value) && !contains(keys, name) {
+					keys = append(keys, name)
+				}
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func vmCacheConfig(cfg *goconfig.ConfigFile) *daemon.VmCacheConfig {
+	vmCacheCfg := &daemon.VmCacheConfig{}
+	vmCacheCfg.Policy, _ = cfg.GetValue(goconfig.DEFAULT_SECTION, "VmCachePolicy")
+	vmCacheCfg.TemplateFlavors, _ = cfg.GetValue(goconfig.DEFAULT_SECTION, "VmTemplateFlavors")
+	vmCacheCfg.PoolFlavors, _ = cfg.GetValue(goconfig.DEFAULT_SECTION, "VmPoolFlavors")
+	vmCacheCfg.IdleTimeout = cfg.MustInt(goconfig.DEFAULT_SECTION, "VmPoolIdleTimeout", daemon.DefaultVmIdleTimeout)
+	return vmCacheCfg
+}
+
+var defaultHost = "unix:///var/run/hyper.sock"
+
+// parseHosts returns the addresses the API listens on, the default unix
+// socket is always listened on.
+func parseHosts(hosts ...string) ([]server.Addr, error) {
+	var addrs []server.Addr
+
+	for _, host := range append([]string{defaultHost}, hosts...) {
+		if host == "" {
+			continue
+		}
+		protoAddr, err := opts.ParseHost(defaultHost, host)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing -H %s : %v", host, err)
+		}
+
+		protoAddrParts := strings.SplitN(protoAddr, "://", 2)
+		if len(protoAddrParts) != 2 {
+			return nil, fmt.Errorf("bad format %s, expected PROTO://ADDR", protoAddr)
+		}
+		addrs = append(addrs, server.Addr{Proto: protoAddrParts[0], Addr: protoAddrParts[1]})
+	}
+	return addrs, nil
+}
+
+// splitList merges the comma separated lists, the empty items are dropped.
+func splitList(lists ...string) []string {
+	var result []string
+	for _, list := range lists {
+		for _, item := range strings.Split(list, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
+
+// setLogLevel sets the verbosity of the V logs, the logs of the docker
+// components are in debug level if it is 3 or higher.
+func setLogLevel(level string) error {
+	if level == "" {
+		level = "0"
+	}
+	if err := flag.Set("v", level); err != nil {
+		return fmt.Errorf("Invalid LogLevel %q: %v", level, err)
+	}
+	if glog.V(3) {
+		logrus.SetLevel(logrus.DebugLevel)
+	} else {
+		logrus.SetLevel(logrus.InfoLevel)
+	}
+	return nil
+}
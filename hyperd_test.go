@@ -0,0 +1,131 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/Unknwon/goconfig"
+	"github.com/hyperhq/hyper/daemon"
+	"github.com/hyperhq/hyper/daemon/fakedriver"
+	"github.com/hyperhq/hyper/server"
+	"github.com/hyperhq/runv/hypervisor"
+)
+
+func loadConfig(t *testing.T, dir, name, content string) *goconfig.ConfigFile {
+	file := path.Join(dir, name)
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := goconfig.LoadConfigFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestRestartKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hyperd-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	old := loadConfig(t, dir, "old", `Kernel=/var/lib/hyper/kernel
+Logger=none
+Bridge=hyper0
+[Log]
+max-size=1m
+[Extra]
+key=a
+`)
+	cfg := loadConfig(t, dir, "new", `Kernel=/opt/kernel
+Logger=json-file
+BridgeIP=192.168.123.1/24
+[Log]
+max-size=2m
+[Extra]
+key=b
+`)
+
+	keys := restartKeys(old, cfg)
+	expected := []string{"Bridge", "BridgeIP", "Extra.key", "Kernel"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("expect the keys %v, got %v", expected, keys)
+	}
+	if keys := restartKeys(cfg, cfg); len(keys) != 0 {
+		t.Fatalf("expect no key of the same config, got %v", keys)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hyperd-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldHost, oldDriver := defaultHost, hypervisor.HDriver
+	defer func() { defaultHost, hypervisor.HDriver = oldHost, oldDriver }()
+	hypervisor.HDriver = fakedriver.InitDriver()
+	defaultHost = "unix://" + path.Join(dir, "hyper.sock")
+
+	addrs, err := parseHosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	api, err := server.New(&server.Config{Addrs: addrs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer api.Close()
+
+	d := &daemon.Daemon{}
+	old := loadConfig(t, dir, "old", "Logger=none\n")
+	d.DefaultLogCfg("none", nil)
+
+	// the settings are read by the API requests while they are reloaded
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				d.GetDefaultLog()
+				d.GetHost()
+			}
+		}
+	}()
+
+	host := "unix://" + path.Join(dir, "extra.sock")
+	file := path.Join(dir, "new")
+	loadConfig(t, dir, "new", "Logger=json-file\nHost="+host+"\n[Log]\nmax-size=1m\n")
+	cfg := reloadConfig(file, old, &Options{LogLevelFlagged: true}, d, api)
+	close(stop)
+	wg.Wait()
+
+	if v, _ := cfg.GetValue(goconfig.DEFAULT_SECTION, "Logger"); v != "json-file" {
+		t.Fatalf("expect the new config to be returned, got Logger %q", v)
+	}
+	if log := d.GetDefaultLog(); log.Type != "json-file" || log.Config["max-size"] != "1m" {
+		t.Fatalf("unexpected default log config %#v", log)
+	}
+	if d.GetHost() != host {
+		t.Fatalf("expect the host %s, got %s", host, d.GetHost())
+	}
+	if _, err := os.Stat(path.Join(dir, "extra.sock")); err != nil {
+		t.Fatalf("expect the API to listen on the new host: %v", err)
+	}
+
+	// the old config is kept if the file can't be read
+	if got := reloadConfig(path.Join(dir, "missing"), cfg, &Options{LogLevelFlagged: true}, d, api); got != cfg {
+		t.Fatal("expect the config in use to be kept")
+	}
+}
@@ -0,0 +1,181 @@
+// Package selector implements the label selectors in the same syntax as
+// kubernetes, e.g. "app=web,tier!=db", "env in (prod,qa)", "!canary".
+package selector
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	opEquals    = "="
+	opNotEquals = "!="
+	opIn        = "in"
+	opNotIn     = "notin"
+	opExists    = "exists"
+	opNotExists = "!"
+)
+
+var setRequirement = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+
+type requirement struct {
+	key    string
+	op     string
+	values []string
+}
+
+func (r *requirement) matches(labels map[string]string) bool {
+	value, ok := labels[r.key]
+
+	switch r.op {
+	case opExists:
+		return ok
+	case opNotExists:
+		return !ok
+	case opEquals:
+		return ok && value == r.values[0]
+	case opNotEquals:
+		return !ok || value != r.values[0]
+	case opIn, opNotIn:
+		found := false
+		for _, v := range r.values {
+			if ok && value == v {
+				found = true
+				break
+			}
+		}
+		return found == (r.op == opIn)
+	}
+	return false
+}
+
+func (r *requirement) String() string {
+	switch r.op {
+	case opExists:
+		return r.key
+	case opNotExists:
+		return "!" + r.key
+	case opIn, opNotIn:
+		return fmt.Sprintf("%s %s (%s)", r.key, r.op, strings.Join(r.values, ","))
+	}
+	return r.key + r.op + r.values[0]
+}
+
+// Selector is a list of requirements on the labels, it matches the labels
+// meeting all the requirements. The empty selector matches everything.
+type Selector []requirement
+
+// Matches returns whether the labels meet the selector.
+func (s Selector) Matches(labels map[string]string) bool {
+	for i := range s {
+		if !s[i].matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// Empty returns whether the selector has no requirement.
+func (s Selector) Empty() bool {
+	return len(s) == 0
+}
+
+func (s Selector) String() string {
+	reqs := make([]string, 0, len(s))
+	for i := range s {
+		reqs = append(reqs, s[i].String())
+	}
+	return strings.Join(reqs, ",")
+}
+
+// Parse parses the selector string, the requirements are separated by
+// commas, which are not in the value set of the "in" and "notin".
+func Parse(selector string) (Selector, error) {
+	s := Selector{}
+
+	for _, part := range split(selector) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		r, err := parseRequirement(part)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, *r)
+	}
+	return s, nil
+}
+
+func split(selector string) []string {
+	var (
+		parts []string
+		depth int
+		start int
+	)
+
+	for i, c := range selector {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, selector[start:])
+}
+
+func parseRequirement(s string) (*requirement, error) {
+	if m := setRequirement.FindStringSubmatch(s); m != nil {
+		r := &requirement{key: m[1], op: m[2]}
+		for _, v := range strings.Split(m[3], ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				r.values = append(r.values, v)
+			}
+		}
+		if len(r.values) == 0 {
+			return nil, fmt.Errorf("Invalid selector %q: empty value set", s)
+		}
+		sort.Strings(r.values)
+		return r, validKey(r.key, s)
+	}
+
+	if strings.HasPrefix(s, "!") {
+		r := &requirement{key: strings.TrimSpace(s[1:]), op: opNotExists}
+		return r, validKey(r.key, s)
+	}
+
+	for _, op := range []string{"!=", "==", "="} {
+		if i := strings.Index(s, op); i >= 0 {
+			r := &requirement{
+				key:    strings.TrimSpace(s[:i]),
+				op:     opEquals,
+				values: []string{strings.TrimSpace(s[i+len(op):])},
+			}
+			if op == "!=" {
+				r.op = opNotEquals
+			}
+			if strings.ContainsAny(r.values[0], "=! ") {
+				return nil, fmt.Errorf("Invalid selector %q: invalid value", s)
+			}
+			return r, validKey(r.key, s)
+		}
+	}
+
+	r := &requirement{key: s, op: opExists}
+	return r, validKey(r.key, s)
+}
+
+func validKey(key, s string) error {
+	if key == "" || strings.ContainsAny(key, "=!(), \t") {
+		return fmt.Errorf("Invalid selector %q: invalid key %q", s, key)
+	}
+	return nil
+}
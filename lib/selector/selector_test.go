@@ -0,0 +1,58 @@
+package selector
+
+import (
+	"testing"
+)
+
+func TestSelectorMatches(t *testing.T) {
+	labels := map[string]string{"app": "web", "tier": "frontend", "env": "qa"}
+
+	cases := []struct {
+		selector string
+		match    bool
+	}{
+		{"", true},
+		{"app=web", true},
+		{"app==web", true},
+		{"app=db", false},
+		{"app=web,tier!=db", true},
+		{"app=web, tier!=frontend", false},
+		{"missing!=x", true},
+		{"env in (prod, qa)", true},
+		{"env in (prod)", false},
+		{"env notin (prod,staging)", true},
+		{"missing notin (a)", true},
+		{"missing in (a)", false},
+		{"app", true},
+		{"!app", false},
+		{"!canary,app=web,env in (a,qa)", true},
+	}
+	for _, c := range cases {
+		s, err := Parse(c.selector)
+		if err != nil {
+			t.Errorf("failed to parse %q: %v", c.selector, err)
+			continue
+		}
+		if s.Matches(labels) != c.match {
+			t.Errorf("%q matches %v, expect %v", c.selector, !c.match, c.match)
+		}
+	}
+}
+
+func TestSelectorParseError(t *testing.T) {
+	for _, s := range []string{"=web", "app=web=x", "env in ()", "!", "a b", "env in (a,b"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("expect %q to be invalid", s)
+		}
+	}
+}
+
+func TestSelectorString(t *testing.T) {
+	s, err := Parse("app = web,env in (qa, prod),!canary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if str := s.String(); str != "app=web,env in (prod,qa),!canary" {
+		t.Errorf("unexpected string %q", str)
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/hyperhq/hyper/daemon/metrics"
+	"github.com/hyperhq/hyper/server/httputils"
+	"golang.org/x/net/context"
+)
+
+var (
+	apiRequests = metrics.NewCounter("hyperd_api_requests_total",
+		"The API requests handled, by the route and whether the handler succeeded.", "method", "route", "result")
+	apiRequestDuration = metrics.NewHistogram("hyperd_api_request_duration_seconds",
+		"The time to handle the API requests, the streaming ones last as long as the streams.", metrics.DefBuckets, "method", "route")
+)
+
+func init() {
+	metrics.Register(apiRequests, apiRequestDuration)
+}
+
+// instrumentHandler counts the requests of the route and their durations.
+func instrumentHandler(method, route string, handler httputils.APIFunc) httputils.APIFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+		start := time.Now()
+		err := handler(ctx, w, r, vars)
+		apiRequestDuration.Since(start, method, route)
+
+		result := "success"
+		if err != nil {
+			result = "error"
+		}
+		apiRequests.Inc(method, route, result)
+		return err
+	}
+}
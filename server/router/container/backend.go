@@ -2,6 +2,7 @@ package container
 
 import (
 	"io"
+	"syscall"
 
 	"github.com/docker/engine-api/types"
 	"github.com/hyperhq/hyper/daemon"
@@ -18,4 +19,10 @@ type Backend interface {
 	CmdAttach(in io.ReadCloser, out io.WriteCloser, key, id, tag string) error
 	CmdCommitImage(name string, cfg *types.ContainerCommitConfig) (*engine.Env, error)
 	CmdTtyResize(podId, tag string, h, w int) error
+	CmdKillContainer(name string, sig syscall.Signal) error
+	CmdWaitContainer(name string, timeout int) (interface{}, error)
+	CmdContainerChanges(name string) (interface{}, error)
+	CmdContainerTop(name string) (interface{}, error)
+	CmdContainerArchive(name, path string, w io.Writer) error
+	CmdContainerExtract(name, path string, content io.Reader) error
 }
@@ -32,14 +32,20 @@ func (r *containerRouter) initRoutes() {
 		local.NewGetRoute("/container/info", r.getContainerInfo),
 		local.NewGetRoute("/container/logs", r.getContainerLogs),
 		local.NewGetRoute("/exitcode", r.getExitCode),
+		local.NewGetRoute("/container/archive", r.getContainerArchive),
+		local.NewGetRoute("/container/changes", r.getContainerChanges),
+		local.NewGetRoute("/container/top", r.getContainerTop),
 		// POST
 		local.NewPostRoute("/container/create", r.postContainerCreate),
 		local.NewPostRoute("/container/rename", r.postContainerRename),
 		local.NewPostRoute("/container/commit", r.postContainerCommit),
+		local.NewPostRoute("/container/kill", r.postContainerKill),
+		local.NewPostRoute("/container/wait", r.postContainerWait),
 		local.NewPostRoute("/exec", r.postContainerExec),
 		local.NewPostRoute("/attach", r.postContainerAttach),
 		local.NewPostRoute("/tty/resize", r.postTtyResize),
 		// PUT
+		local.NewPutRoute("/container/archive", r.putContainerArchive),
 		// DELETE
 	}
 }
@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/docker/docker/builder/dockerfile"
 	"github.com/docker/docker/pkg/ioutils"
+	"github.com/docker/docker/pkg/signal"
 	"github.com/docker/docker/runconfig"
 	"github.com/docker/docker/utils"
 	"github.com/docker/engine-api/types"
@@ -182,3 +185,93 @@ func (c *containerRouter) postContainerRename(ctx context.Context, w http.Respon
 
 	return env.WriteJSON(w, http.StatusOK)
 }
+
+func (c *containerRouter) postContainerKill(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	sig := syscall.SIGKILL
+	if s := r.Form.Get("signal"); s != "" {
+		var err error
+		if sig, err = signal.ParseSignal(s); err != nil {
+			return err
+		}
+	}
+
+	if err := c.backend.CmdKillContainer(r.Form.Get("container"), sig); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (c *containerRouter) postContainerWait(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	timeout := 0
+	if t := r.Form.Get("timeout"); t != "" {
+		var err error
+		if timeout, err = strconv.Atoi(t); err != nil || timeout < 0 {
+			return fmt.Errorf("Invalid wait timeout: %s", t)
+		}
+	}
+
+	data, err := c.backend.CmdWaitContainer(r.Form.Get("container"), timeout)
+	if err != nil {
+		return err
+	}
+
+	return httputils.WriteJSON(w, http.StatusOK, data)
+}
+
+func (c *containerRouter) getContainerChanges(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	data, err := c.backend.CmdContainerChanges(r.Form.Get("container"))
+	if err != nil {
+		return err
+	}
+
+	return httputils.WriteJSON(w, http.StatusOK, data)
+}
+
+func (c *containerRouter) getContainerTop(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	data, err := c.backend.CmdContainerTop(r.Form.Get("container"))
+	if err != nil {
+		return err
+	}
+
+	return httputils.WriteJSON(w, http.StatusOK, data)
+}
+
+func (c *containerRouter) getContainerArchive(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	return c.backend.CmdContainerArchive(r.Form.Get("container"), r.Form.Get("path"), w)
+}
+
+func (c *containerRouter) putContainerArchive(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	if err := c.backend.CmdContainerExtract(r.Form.Get("container"), r.Form.Get("path"), r.Body); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
@@ -2,7 +2,9 @@ package pod
 
 import (
 	"io"
+	"syscall"
 
+	"github.com/docker/engine-api/types"
 	"github.com/hyperhq/hyper/engine"
 )
 
@@ -12,12 +14,16 @@ type Backend interface {
 	CmdGetPodInfo(podName string) (interface{}, error)
 	CmdGetPodStats(podId string) (interface{}, error)
 	CmdCreatePod(podArgs string, autoremove bool) (*engine.Env, error)
-	CmdSetPodLabels(podId string, override bool, labels map[string]string) (*engine.Env, error)
+	CmdSetPodLabels(podId string, override bool, labels map[string]string, remove []string) (*engine.Env, error)
+	CmdSetPodAnnotations(podId string, override bool, annotations map[string]string, remove []string) (*engine.Env, error)
+	CmdCommitPod(podId, prefix string, cfg *types.ContainerCommitConfig) (interface{}, error)
+	CmdUpdatePod(podId string, cpu, mem int) (*engine.Env, error)
 	CmdStartPod(in io.ReadCloser, out io.WriteCloser, podId, vmId, tag string) (*engine.Env, error)
 	CmdPausePod(podId string) error
 	CmdUnpausePod(podId string) error
-	CmdList(item, podId, vmId string, auxiliary bool) (*engine.Env, error)
-	CmdStopPod(podId, stopVm string) (*engine.Env, error)
+	CmdList(item, podId, vmId, selector string, auxiliary bool) (*engine.Env, error)
+	CmdStopPod(podId, stopVm string, sig syscall.Signal, timeout int) (*engine.Env, error)
+	CmdWaitPod(podName string, timeout int) (interface{}, error)
 	CmdCleanPod(podId string) (*engine.Env, error)
 	CmdCreateVm(cpu, mem int, async bool) (*engine.Env, error)
 	CmdKillVm(vmId string) (*engine.Env, error)
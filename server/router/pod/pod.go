@@ -25,8 +25,12 @@ func NewRouter(b Backend) router.Router {
 		// POST
 		local.NewPostRoute("/pod/create", r.postPodCreate),
 		local.NewPostRoute("/pod/labels", r.postPodLabels),
+		local.NewPostRoute("/pod/annotations", r.postPodAnnotations),
+		local.NewPostRoute("/pod/update", r.postPodUpdate),
+		local.NewPostRoute("/pod/commit", r.postPodCommit),
 		local.NewPostRoute("/pod/start", r.postPodStart),
 		local.NewPostRoute("/pod/stop", r.postPodStop),
+		local.NewPostRoute("/pod/wait", r.postPodWait),
 		local.NewPostRoute("/pod/pause", r.postPodPause),
 		local.NewPostRoute("/pod/unpause", r.postPodUnpause),
 		local.NewPostRoute("/vm/create", r.postVmCreate),
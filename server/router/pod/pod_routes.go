@@ -7,7 +7,12 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"syscall"
+	"time"
 
+	"github.com/docker/docker/pkg/ioutils"
+	"github.com/docker/docker/pkg/signal"
+	"github.com/docker/engine-api/types"
 	"github.com/golang/glog"
 	"github.com/hyperhq/hyper/server/httputils"
 	"golang.org/x/net/context"
@@ -26,17 +31,55 @@ func (p *podRouter) getPodInfo(ctx context.Context, w http.ResponseWriter, r *ht
 	return httputils.WriteJSON(w, http.StatusOK, data)
 }
 
+// statsInterval is the interval of the samples in the stats stream.
+const statsInterval = time.Second
+
 func (p *podRouter) getPodStats(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := httputils.ParseForm(r); err != nil {
 		return err
 	}
 
-	data, err := p.backend.CmdGetPodStats(r.Form.Get("podId"))
+	podId := r.Form.Get("podId")
+	data, err := p.backend.CmdGetPodStats(podId)
 	if err != nil {
 		return err
 	}
+	if !httputils.BoolValue(r, "stream") {
+		return httputils.WriteJSON(w, http.StatusOK, data)
+	}
 
-	return httputils.WriteJSON(w, http.StatusOK, data)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	output := ioutils.NewWriteFlusher(w)
+	defer output.Close()
+
+	enc := json.NewEncoder(output)
+
+	var closeNotify <-chan bool
+	if closeNotifier, ok := w.(http.CloseNotifier); ok {
+		closeNotify = closeNotifier.CloseNotify()
+	}
+
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+	for {
+		if err := enc.Encode(data); err != nil {
+			return err
+		}
+
+		select {
+		case <-ticker.C:
+		case <-closeNotify:
+			return nil
+		}
+
+		// the stream ends once the pod stops
+		if data, err = p.backend.CmdGetPodStats(podId); err != nil {
+			glog.V(1).Infof("Stop the stats stream of pod %s: %s", podId, err.Error())
+			return nil
+		}
+	}
 }
 
 func (p *podRouter) getList(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
@@ -48,10 +91,11 @@ func (p *podRouter) getList(ctx context.Context, w http.ResponseWriter, r *http.
 	auxiliary := httputils.BoolValue(r, "auxiliary")
 	pod := r.Form.Get("pod")
 	vm := r.Form.Get("vm")
+	selector := r.Form.Get("selector")
 
-	glog.V(1).Infof("List type is %s, specified pod: [%s], specified vm: [%s], list auxiliary pod: %v", item, pod, vm, auxiliary)
+	glog.V(1).Infof("List type is %s, specified pod: [%s], specified vm: [%s], selector: [%s], list auxiliary pod: %v", item, pod, vm, selector, auxiliary)
 
-	env, err := p.backend.CmdList(item, pod, vm, auxiliary)
+	env, err := p.backend.CmdList(item, pod, vm, selector, auxiliary)
 	if err != nil {
 		return err
 	}
@@ -89,9 +133,32 @@ func (p *podRouter) postPodLabels(ctx context.Context, w http.ResponseWriter, r
 	}
 
 	podId := r.Form.Get("podId")
-	labels := make(map[string]string)
+	labels, remove, err := metadataChanges(r, "labels")
+	if err != nil {
+		return err
+	}
 
-	if err := json.Unmarshal([]byte(r.Form.Get("labels")), &labels); err != nil {
+	override := false
+	if r.Form.Get("override") == "true" || r.Form.Get("override") == "yes" {
+		override = true
+	}
+
+	env, err := p.backend.CmdSetPodLabels(podId, override, labels, remove)
+	if err != nil {
+		return err
+	}
+
+	return env.WriteJSON(w, http.StatusCreated)
+}
+
+func (p *podRouter) postPodAnnotations(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	podId := r.Form.Get("podId")
+	annotations, remove, err := metadataChanges(r, "annotations")
+	if err != nil {
 		return err
 	}
 
@@ -100,7 +167,7 @@ func (p *podRouter) postPodLabels(ctx context.Context, w http.ResponseWriter, r
 		override = true
 	}
 
-	env, err := p.backend.CmdSetPodLabels(podId, override, labels)
+	env, err := p.backend.CmdSetPodAnnotations(podId, override, annotations, remove)
 	if err != nil {
 		return err
 	}
@@ -108,6 +175,74 @@ func (p *podRouter) postPodLabels(ctx context.Context, w http.ResponseWriter, r
 	return env.WriteJSON(w, http.StatusCreated)
 }
 
+// metadataChanges parses the labels or annotations to set, and the keys to
+// remove, both are json in the form.
+func metadataChanges(r *http.Request, name string) (map[string]string, []string, error) {
+	set := make(map[string]string)
+	if value := r.Form.Get(name); value != "" {
+		if err := json.Unmarshal([]byte(value), &set); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	remove := []string{}
+	if value := r.Form.Get("remove"); value != "" {
+		if err := json.Unmarshal([]byte(value), &remove); err != nil {
+			return nil, nil, err
+		}
+	}
+	return set, remove, nil
+}
+
+func (p *podRouter) postPodUpdate(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	var (
+		cpu = 0
+		mem = 0
+		err error
+	)
+	if value := r.Form.Get("cpu"); value != "" {
+		if cpu, err = strconv.Atoi(value); err != nil || cpu < 0 {
+			return fmt.Errorf("Invalid cpu: %s", value)
+		}
+	}
+	if value := r.Form.Get("memory"); value != "" {
+		if mem, err = strconv.Atoi(value); err != nil || mem < 0 {
+			return fmt.Errorf("Invalid memory: %s", value)
+		}
+	}
+
+	env, err := p.backend.CmdUpdatePod(r.Form.Get("podId"), cpu, mem)
+	if err != nil {
+		return err
+	}
+
+	return env.WriteJSON(w, http.StatusOK)
+}
+
+func (p *podRouter) postPodCommit(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	cfg := &types.ContainerCommitConfig{
+		Pause:   httputils.BoolValue(r, "pause"),
+		Tag:     r.Form.Get("tag"),
+		Author:  r.Form.Get("author"),
+		Comment: r.Form.Get("comment"),
+	}
+
+	data, err := p.backend.CmdCommitPod(r.Form.Get("podId"), r.Form.Get("prefix"), cfg)
+	if err != nil {
+		return err
+	}
+
+	return httputils.WriteJSON(w, http.StatusOK, data)
+}
+
 func (p *podRouter) postPodStart(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := httputils.ParseForm(r); err != nil {
 		return err
@@ -150,7 +285,24 @@ func (p *podRouter) postPodStop(ctx context.Context, w http.ResponseWriter, r *h
 	podId := r.Form.Get("podId")
 	stopVm := r.Form.Get("stopVm")
 
-	env, err := p.backend.CmdStopPod(podId, stopVm)
+	sig := syscall.SIGTERM
+	if s := r.Form.Get("signal"); s != "" {
+		var err error
+		if sig, err = signal.ParseSignal(s); err != nil {
+			return err
+		}
+	}
+
+	// a negative timeout lets the daemon decide the grace period
+	timeout := -1
+	if t := r.Form.Get("timeout"); t != "" {
+		var err error
+		if timeout, err = strconv.Atoi(t); err != nil || timeout < 0 {
+			return fmt.Errorf("Invalid stop timeout: %s", t)
+		}
+	}
+
+	env, err := p.backend.CmdStopPod(podId, stopVm, sig, timeout)
 	if err != nil {
 		return err
 	}
@@ -158,6 +310,27 @@ func (p *podRouter) postPodStop(ctx context.Context, w http.ResponseWriter, r *h
 	return env.WriteJSON(w, http.StatusOK)
 }
 
+func (p *podRouter) postPodWait(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	timeout := 0
+	if t := r.Form.Get("timeout"); t != "" {
+		var err error
+		if timeout, err = strconv.Atoi(t); err != nil || timeout < 0 {
+			return fmt.Errorf("Invalid wait timeout: %s", t)
+		}
+	}
+
+	data, err := p.backend.CmdWaitPod(r.Form.Get("podName"), timeout)
+	if err != nil {
+		return err
+	}
+
+	return httputils.WriteJSON(w, http.StatusOK, data)
+}
+
 func (p *podRouter) postPodPause(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := httputils.ParseForm(r); err != nil {
 		return err
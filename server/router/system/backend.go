@@ -1,8 +1,12 @@
 package system
 
 import (
+	"io"
+
 	"github.com/docker/engine-api/types"
+	"github.com/hyperhq/hyper/daemon/events"
 	"github.com/hyperhq/hyper/engine"
+	hypertypes "github.com/hyperhq/hyper/types"
 )
 
 // Backend is the methods that need to be implemented to provide
@@ -11,4 +15,8 @@ type Backend interface {
 	CmdSystemInfo() (*engine.Env, error)
 	CmdSystemVersion() *engine.Env
 	CmdAuthenticateToRegistry(authConfig *types.AuthConfig) (string, error)
+	CmdSubscribeToEvents(since, sinceNano, until, untilNano int64, ef *events.Filter) ([]hypertypes.Event, chan interface{})
+	CmdUnsubscribeFromEvents(chan interface{})
+	CmdSystemPrune(dryRun bool) (interface{}, error)
+	CmdMetrics(w io.Writer) error
 }
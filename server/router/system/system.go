@@ -22,8 +22,11 @@ func NewRouter(b Backend) router.Router {
 	r.routes = []router.Route{
 		local.NewGetRoute("/_ping", pingHandler),
 		local.NewGetRoute("/info", r.getInfo),
+		local.NewGetRoute("/events", r.getEvents),
 		local.NewGetRoute("/version", r.getVersion),
+		local.NewGetRoute("/metrics", r.getMetrics),
 		local.NewPostRoute("/auth", r.postAuth),
+		local.NewPostRoute("/system/prune", r.postPrune),
 	}
 
 	return r
@@ -1,11 +1,19 @@
 package system
 
 import (
+	"bytes"
 	"encoding/json"
 	"net/http"
+	"time"
 
+	"github.com/docker/docker/pkg/ioutils"
 	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/filters"
+	timetypes "github.com/docker/engine-api/types/time"
+	"github.com/golang/glog"
+	"github.com/hyperhq/hyper/daemon/events"
 	"github.com/hyperhq/hyper/server/httputils"
+	hypertypes "github.com/hyperhq/hyper/types"
 	"golang.org/x/net/context"
 )
 
@@ -28,6 +36,83 @@ func (s *systemRouter) getVersion(ctx context.Context, w http.ResponseWriter, r
 	return env.WriteJSON(w, http.StatusOK)
 }
 
+func (s *systemRouter) getEvents(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+	since, sinceNano, err := timetypes.ParseTimestamps(r.Form.Get("since"), -1)
+	if err != nil {
+		return err
+	}
+	until, untilNano, err := timetypes.ParseTimestamps(r.Form.Get("until"), -1)
+	if err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(0)
+	timer.Stop()
+	if until > 0 || untilNano > 0 {
+		dur := time.Unix(until, untilNano).Sub(time.Now())
+		timer = time.NewTimer(dur)
+	}
+
+	args, err := filters.FromParam(r.Form.Get("filters"))
+	if err != nil {
+		return err
+	}
+	ef, err := events.NewFilter(args)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// This is to ensure that the HTTP status code is sent immediately,
+	// so that it will not block the receiver.
+	w.WriteHeader(http.StatusOK)
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
+	output := ioutils.NewWriteFlusher(w)
+	defer output.Close()
+
+	enc := json.NewEncoder(output)
+
+	buffered, l := s.backend.CmdSubscribeToEvents(since, sinceNano, until, untilNano, ef)
+	defer s.backend.CmdUnsubscribeFromEvents(l)
+
+	for _, ev := range buffered {
+		if err := enc.Encode(ev); err != nil {
+			return err
+		}
+	}
+
+	var closeNotify <-chan bool
+	if closeNotifier, ok := w.(http.CloseNotifier); ok {
+		closeNotify = closeNotifier.CloseNotify()
+	}
+
+	for {
+		select {
+		case ev := <-l:
+			jev, ok := ev.(hypertypes.Event)
+			if !ok {
+				glog.Warningf("unexpected event message: %q", ev)
+				continue
+			}
+			if err := enc.Encode(jev); err != nil {
+				return err
+			}
+		case <-timer.C:
+			return nil
+		case <-closeNotify:
+			glog.V(1).Info("Client disconnected, stop sending events")
+			return nil
+		}
+	}
+}
+
 func (s *systemRouter) postAuth(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	var config *types.AuthConfig
 	err := json.NewDecoder(r.Body).Decode(&config)
@@ -42,3 +127,29 @@ func (s *systemRouter) postAuth(ctx context.Context, w http.ResponseWriter, r *h
 
 	return httputils.WriteJSON(w, http.StatusOK, &types.AuthResponse{Status: status})
 }
+
+func (s *systemRouter) postPrune(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	report, err := s.backend.CmdSystemPrune(httputils.BoolValue(r, "dryrun"))
+	if err != nil {
+		return err
+	}
+
+	return httputils.WriteJSON(w, http.StatusOK, report)
+}
+
+func (s *systemRouter) getMetrics(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	// the metrics are buffered, so that an error could still be reported
+	var buf bytes.Buffer
+	if err := s.backend.CmdMetrics(&buf); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	_, err := buf.WriteTo(w)
+	return err
+}
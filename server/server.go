@@ -2,9 +2,11 @@ package server
 
 import (
 	"crypto/tls"
+	"fmt"
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 
 	ddaemon "github.com/docker/docker/daemon"
 	"github.com/docker/docker/pkg/authorization"
@@ -44,7 +46,11 @@ type Config struct {
 // Server contains instance details for the server
 type Server struct {
 	cfg           *Config
+	mu            sync.Mutex
 	servers       []*HTTPServer
+	addrs         map[*HTTPServer]Addr
+	serving       int
+	chErrors      chan error
 	routers       []router.Router
 	authZPlugins  []authorization.Plugin
 	routerSwapper *routerSwapper
@@ -60,7 +66,8 @@ type Addr struct {
 // It allocates resources which will be needed for ServeAPI(ports, unix-sockets).
 func New(cfg *Config) (*Server, error) {
 	s := &Server{
-		cfg: cfg,
+		cfg:   cfg,
+		addrs: make(map[*HTTPServer]Addr),
 	}
 	for _, addr := range cfg.Addrs {
 		srv, err := s.newServer(addr.Proto, addr.Addr)
@@ -68,6 +75,9 @@ func New(cfg *Config) (*Server, error) {
 			return nil, err
 		}
 		glog.V(3).Infof("Server created for HTTP on %s (%s)", addr.Proto, addr.Addr)
+		for _, srv := range srv {
+			s.addrs[srv] = addr
+		}
 		s.servers = append(s.servers, srv...)
 	}
 	return s, nil
@@ -75,6 +85,8 @@ func New(cfg *Config) (*Server, error) {
 
 // Close closes servers and thus stop receiving requests
 func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	for _, srv := range s.servers {
 		if err := srv.Close(); err != nil {
 			glog.Error(err)
@@ -84,29 +96,112 @@ func (s *Server) Close() {
 
 // serveAPI loops through all initialized servers and spawns goroutine
 // with Server method for each. It sets createMux() as Handler also.
+// It returns when any server fails, or all of them are closed.
 func (s *Server) serveAPI() error {
 	s.initRouterSwapper()
 
-	var chErrors = make(chan error, len(s.servers))
+	s.mu.Lock()
+	s.chErrors = make(chan error, 1)
+	if len(s.servers) == 0 {
+		s.chErrors <- nil
+	}
 	for _, srv := range s.servers {
-		srv.srv.Handler = s.routerSwapper
-		go func(srv *HTTPServer) {
-			var err error
-			glog.V(3).Infof("API listen on %s", srv.l.Addr())
-			if err = srv.Serve(); err != nil && strings.Contains(err.Error(), "use of closed network connection") {
-				err = nil
+		s.serve(srv)
+	}
+	chErrors := s.chErrors
+	s.mu.Unlock()
+
+	return <-chErrors
+}
+
+// serve starts the server in a goroutine, the caller must hold s.mu.
+func (s *Server) serve(srv *HTTPServer) {
+	srv.srv.Handler = s.routerSwapper
+	s.serving++
+	go func() {
+		var err error
+		glog.V(3).Infof("API listen on %s", srv.l.Addr())
+		if err = srv.Serve(); err != nil && strings.Contains(err.Error(), "use of closed network connection") {
+			err = nil
+		}
+
+		s.mu.Lock()
+		s.serving--
+		if err != nil || s.serving == 0 {
+			select {
+			case s.chErrors <- err:
+			default:
 			}
-			chErrors <- err
-		}(srv)
+		}
+		s.mu.Unlock()
+	}()
+}
+
+// UpdateAddrs makes the server listen on the addrs, the servers of the new
+// addrs are started and the ones of the removed addrs are closed, the
+// others are kept as they are. Nothing is changed if any new addr can't be
+// listened on.
+func (s *Server) UpdateAddrs(addrs []Addr) error {
+	if len(addrs) == 0 {
+		return fmt.Errorf("No address to listen on")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := map[Addr]bool{}
+	for _, srv := range s.servers {
+		current[s.addrs[srv]] = true
 	}
 
-	for i := 0; i < len(s.servers); i++ {
-		err := <-chErrors
+	keep := map[Addr]bool{}
+	var added []*HTTPServer
+	for _, addr := range addrs {
+		if keep[addr] {
+			continue
+		}
+		keep[addr] = true
+		if current[addr] {
+			continue
+		}
+		srv, err := s.newServer(addr.Proto, addr.Addr)
 		if err != nil {
+			for _, srv := range added {
+				srv.Close()
+				delete(s.addrs, srv)
+			}
 			return err
 		}
+		glog.Infof("Start listening on %s (%s)", addr.Proto, addr.Addr)
+		for _, srv := range srv {
+			s.addrs[srv] = addr
+		}
+		added = append(added, srv...)
 	}
 
+	// start the new servers before closing the removed ones, so that the
+	// API is never considered to be stopped
+	var servers []*HTTPServer
+	for _, srv := range added {
+		if s.chErrors != nil {
+			s.serve(srv)
+		}
+		servers = append(servers, srv)
+	}
+	for _, srv := range s.servers {
+		addr := s.addrs[srv]
+		if keep[addr] {
+			servers = append(servers, srv)
+			continue
+		}
+		glog.Infof("Stop listening on %s (%s)", addr.Proto, addr.Addr)
+		if err := srv.Close(); err != nil {
+			glog.Error(err)
+		}
+		delete(s.addrs, srv)
+	}
+	s.servers = servers
+	s.cfg.Addrs = addrs
 	return nil
 }
 
@@ -199,7 +294,7 @@ func (s *Server) createMux() *mux.Router {
 	glog.V(3).Infof("Registering routers")
 	for _, apiRouter := range s.routers {
 		for _, r := range apiRouter.Routes() {
-			f := s.makeHTTPHandler(r.Handler())
+			f := s.makeHTTPHandler(instrumentHandler(r.Method(), r.Path(), r.Handler()))
 
 			glog.V(3).Infof("Registering %s, %s", r.Method(), r.Path())
 			m.Path(versionMatcher + r.Path()).Methods(r.Method()).Handler(f)
@@ -1,9 +1,14 @@
 package server
 
 import (
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/docker/docker/api/server/httputils"
 
@@ -32,3 +37,52 @@ func TestMiddlewares(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestUpdateAddrs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hyper-server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldAddr := Addr{Proto: "unix", Addr: filepath.Join(dir, "old.sock")}
+	newAddr := Addr{Proto: "unix", Addr: filepath.Join(dir, "new.sock")}
+	srv, err := New(&Config{Addrs: []Addr{oldAddr}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wait := make(chan error, 1)
+	go srv.Wait(wait)
+
+	if err := srv.UpdateAddrs([]Addr{newAddr}); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.UpdateAddrs([]Addr{newAddr, {Proto: "bad", Addr: "bad"}}); err == nil {
+		t.Fatal("expect error for the invalid addr")
+	}
+
+	if _, err := net.Dial("unix", oldAddr.Addr); err == nil {
+		t.Error("the removed addr is still listened on")
+	}
+	conn, err := net.Dial("unix", newAddr.Addr)
+	if err != nil {
+		t.Fatalf("the new addr is not listened on: %v", err)
+	}
+	conn.Close()
+
+	select {
+	case err := <-wait:
+		t.Fatalf("the server stops while updating the addrs: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	srv.Close()
+	select {
+	case err := <-wait:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the server does not stop after it is closed")
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"sync"
 	"syscall"
 
+	"github.com/docker/docker/pkg/archive"
 	"github.com/golang/glog"
 	"github.com/hyperhq/hyper/utils"
 )
@@ -61,6 +62,16 @@ func MountContainerToSharedDir(containerId, rootDir, sharedDir, mountLabel strin
 	return mountPoint, nil
 }
 
+// Changes returns the changes in the diff dir of the container against the
+// diff dirs of its parent layers.
+func Changes(containerId, rootDir string) ([]archive.Change, error) {
+	layers, err := getParentDiffPaths(containerId, rootDir)
+	if err != nil {
+		return nil, err
+	}
+	return archive.Changes(layers, path.Join(rootDir, "diff", containerId))
+}
+
 func getParentDiffPaths(id, rootPath string) ([]string, error) {
 	parentIds, err := getParentIds(path.Join(rootPath, "layers", id))
 	if err != nil {
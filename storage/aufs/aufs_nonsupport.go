@@ -2,6 +2,12 @@
 
 package aufs
 
+import (
+	"fmt"
+
+	"github.com/docker/docker/pkg/archive"
+)
+
 func MountContainerToSharedDir(containerId, rootDir, sharedDir, mountLabel string) (string, error) {
 	return "", nil
 }
@@ -9,3 +15,7 @@ func MountContainerToSharedDir(containerId, rootDir, sharedDir, mountLabel strin
 func AttachFiles(containerId, fromFile, toDir, rootDir, perm, uid, gid string) error {
 	return nil
 }
+
+func Changes(containerId, rootDir string) ([]archive.Change, error) {
+	return nil, fmt.Errorf("Unsupported, the changes of container %s are not supported in current arch", containerId)
+}
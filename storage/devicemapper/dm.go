@@ -13,6 +13,7 @@ import (
 	"strings"
 	"syscall"
 
+	"github.com/docker/docker/pkg/archive"
 	"github.com/golang/glog"
 	"github.com/hyperhq/hyper/storage"
 )
@@ -111,6 +112,53 @@ func InjectFile(src io.Reader, containerId, devPrefix, target, rootPath string,
 	return storage.WriteFile(src, targetFile, perm, uid, gid)
 }
 
+// Changes returns the changes of the device of the container against the
+// device of its init layer. The devices are activated if they are not,
+// and are mounted read only without replaying the journal, so that the
+// container running in the VM is not affected.
+func Changes(containerId, initId, devPrefix, rootPath string) ([]archive.Change, error) {
+	var rootfs []string
+	for _, id := range []string{containerId, initId} {
+		devName := fmt.Sprintf("%s-%s", devPrefix, id)
+		devFullName := fmt.Sprintf("/dev/mapper/%s", devName)
+		if _, err := os.Stat(devFullName); err != nil && os.IsNotExist(err) {
+			if err := CreateNewDevice(id, devPrefix, rootPath); err != nil {
+				return nil, err
+			}
+			defer removeDevice(devName)
+		}
+
+		mnt, err := ioutil.TempDir("", "hyper-changes-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(mnt)
+
+		fstype, err := ProbeFsType(devFullName)
+		if err != nil {
+			return nil, err
+		}
+		options := "noload"
+		if fstype == "xfs" {
+			options = "nouuid,norecovery"
+		}
+		if err := syscall.Mount(devFullName, mnt, fstype, syscall.MS_RDONLY, options); err != nil {
+			return nil, fmt.Errorf("Error mounting '%s' on '%s': %s", devFullName, mnt, err)
+		}
+		defer syscall.Unmount(mnt, syscall.MNT_DETACH)
+
+		rootfs = append(rootfs, path.Join(mnt, "rootfs"))
+	}
+
+	return archive.ChangesDirs(rootfs[0], rootfs[1])
+}
+
+func removeDevice(devName string) {
+	if res, err := exec.Command("dmsetup", "remove", devName).CombinedOutput(); err != nil {
+		glog.Warningf("Failed to remove device %s: %s", devName, res)
+	}
+}
+
 func ProbeFsType(device string) (string, error) {
 	// The daemon will only be run on Linux platform, so 'file -s' command
 	// will be used to test the type of filesystem which the device located.
@@ -7,6 +7,8 @@ import (
 	"io"
 	"os/exec"
 	"strings"
+
+	"github.com/docker/docker/pkg/archive"
 )
 
 // For device mapper, we do not need to mount the container to sharedDir.
@@ -19,6 +21,10 @@ func InjectFile(src io.Reader, containerId, devPrefix, target, rootPath string,
 	return fmt.Errorf("Unsupported, inject file to %s is not supported in current arch", target)
 }
 
+func Changes(containerId, initId, devPrefix, rootPath string) ([]archive.Change, error) {
+	return nil, fmt.Errorf("Unsupported, the changes of container %s are not supported in current arch", containerId)
+}
+
 func CreateNewDevice(containerId, devPrefix, rootPath string) error {
 	return nil
 }
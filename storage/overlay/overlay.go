@@ -9,6 +9,7 @@ import (
 	"path"
 	"syscall"
 
+	"github.com/docker/docker/pkg/archive"
 	"github.com/hyperhq/hyper/utils"
 )
 
@@ -36,3 +37,30 @@ func MountContainerToSharedDir(containerId, rootDir, sharedDir, mountLabel strin
 	}
 	return mountPoint, nil
 }
+
+// Changes returns the changes in the upper dir of the container against the
+// lower dir. The deleted files are the whiteouts in the upper dir, which
+// are the character devices of 0/0.
+func Changes(containerId, rootDir string) ([]archive.Change, error) {
+	upperDir := path.Join(rootDir, containerId, "upper")
+	lowerId, err := ioutil.ReadFile(path.Join(rootDir, containerId) + "/lower-id")
+	if err != nil {
+		return nil, err
+	}
+	lowerDir := path.Join(rootDir, string(lowerId), "root")
+
+	changes, err := archive.Changes([]string{lowerDir}, upperDir)
+	if err != nil {
+		return nil, err
+	}
+	for i := range changes {
+		fi, err := os.Lstat(path.Join(upperDir, changes[i].Path))
+		if err != nil {
+			continue
+		}
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok && fi.Mode()&os.ModeCharDevice != 0 && st.Rdev == 0 {
+			changes[i].Kind = archive.ChangeDelete
+		}
+	}
+	return changes, nil
+}
@@ -259,3 +259,40 @@ func TestAttachFiles(t *testing.T) {
 		t.Fatalf("Error during removing files and dirs: %s\n", err.Error())
 	}
 }
+
+func TestChanges(t *testing.T) {
+	if os.Getgid() != 0 {
+		t.Errorf("This test case should be run in root group")
+		return
+	}
+	if err := InitDir(); err != nil {
+		t.Fatalf("Error during creating the temp directory: %s\n", err.Error())
+	}
+	defer Cleanup()
+	if err := InitFile(); err != nil {
+		t.Fatalf("Error during creating the test file: %s\n", err.Error())
+	}
+
+	// file 2 is changed, 3 is deleted and 4 is added in the container
+	if err := ioutil.WriteFile(path.Join(upperDir, "2"), []byte("2\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mknod(path.Join(upperDir, "3"), syscall.S_IFCHR, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(upperDir, "4"), []byte("4\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := Changes(containerId, tempDir)
+	if err != nil {
+		t.Fatalf("Error during getting the changes: %s\n", err.Error())
+	}
+	var result []string
+	for _, c := range changes {
+		result = append(result, c.String())
+	}
+	if strings.Join(result, ",") != "C /2,D /3,A /4" {
+		t.Fatalf("Unexpected changes %v", result)
+	}
+}
@@ -0,0 +1,17 @@
+package types
+
+import "encoding/json"
+
+// CommittedImage is the image committed from a container of the pod.
+type CommittedImage struct {
+	Container string `json:"container"`
+	Image     string `json:"image"`
+	ID        string `json:"id"`
+}
+
+// PodCommitResult is the images committed from the containers of a pod,
+// and the pod spec to recreate the pod with them.
+type PodCommitResult struct {
+	Images []CommittedImage `json:"images"`
+	Spec   json.RawMessage  `json:"spec"`
+}
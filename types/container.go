@@ -20,6 +20,41 @@ type VolumeMount struct {
 	MountPath string `json:"mountPath"`
 }
 
+type ExecAction struct {
+	Command []string `json:"command"`
+}
+
+type TCPSocketAction struct {
+	Port int `json:"port"`
+}
+
+type HTTPGetAction struct {
+	Path   string `json:"path"`
+	Port   int    `json:"port"`
+	Host   string `json:"host"`
+	Scheme string `json:"scheme"`
+}
+
+// Probe describes a health check to be performed against a container,
+// exactly one of Exec, TCPSocket and HTTPGet should be set. The times
+// are in seconds.
+type Probe struct {
+	Exec             *ExecAction      `json:"exec,omitempty"`
+	TCPSocket        *TCPSocketAction `json:"tcpSocket,omitempty"`
+	HTTPGet          *HTTPGetAction   `json:"httpGet,omitempty"`
+	InitialDelay     int              `json:"initialDelay"`
+	Interval         int              `json:"interval"`
+	Timeout          int              `json:"timeout"`
+	FailureThreshold int              `json:"failureThreshold"`
+}
+
+type ProbeStatus struct {
+	Result        string `json:"result"`
+	Failures      int    `json:"failures"`
+	Message       string `json:"message"`
+	LastProbeTime string `json:"lastProbeTime"`
+}
+
 type WaitingStatus struct {
 	Reason string `json:"reason"`
 }
@@ -37,12 +72,16 @@ type TermStatus struct {
 }
 
 type ContainerStatus struct {
-	Name        string        `json:"name"`
-	ContainerID string        `json:"containerID"`
-	Phase       string        `json:"phase"`
-	Waiting     WaitingStatus `json:"waiting"`
-	Running     RunningStatus `json:"running"`
-	Terminated  TermStatus    `json:"terminated"`
+	Name         string        `json:"name"`
+	ContainerID  string        `json:"containerID"`
+	Phase        string        `json:"phase"`
+	RestartCount int           `json:"restartCount"`
+	Ready        bool          `json:"ready"`
+	Liveness     *ProbeStatus  `json:"liveness,omitempty"`
+	Readiness    *ProbeStatus  `json:"readiness,omitempty"`
+	Waiting      WaitingStatus `json:"waiting"`
+	Running      RunningStatus `json:"running"`
+	Terminated   TermStatus    `json:"terminated"`
 }
 
 type ContainerInfo struct {
@@ -0,0 +1,24 @@
+package types
+
+const (
+	// EventTypePod is the event type that pods generate
+	EventTypePod = "pod"
+	// EventTypeContainer is the event type that containers generate
+	EventTypeContainer = "container"
+	// EventTypeVm is the event type that vms generate
+	EventTypeVm = "vm"
+)
+
+// Event JSON Data Structure
+type Event struct {
+	Type        string            `json:"type"`
+	Action      string            `json:"action"`
+	PodID       string            `json:"podID,omitempty"`
+	PodName     string            `json:"podName,omitempty"`
+	ContainerID string            `json:"containerID,omitempty"`
+	VmID        string            `json:"vmID,omitempty"`
+	ExitCode    int               `json:"exitCode"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Time        int64             `json:"time"`
+	TimeNano    int64             `json:"timeNano"`
+}
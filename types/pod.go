@@ -14,6 +14,8 @@ type Container struct {
 	Volume          []VolumeMount    `json:"volumeMounts"`
 	Tty             bool             `json:"tty"`
 	ImagePullPolicy string           `json:"imagePullPolicy"`
+	LivenessProbe   *Probe           `json:"livenessProbe,omitempty"`
+	ReadinessProbe  *Probe           `json:"readinessProbe,omitempty"`
 }
 
 type RBDVolumeSource struct {
@@ -34,11 +36,12 @@ type PodVolume struct {
 }
 
 type PodSpec struct {
-	Volumes    []PodVolume       `json:"volumes"`
-	Containers []Container       `json:"containers"`
-	Labels     map[string]string `json:"labels"`
-	Vcpu       int               `json:"vcpu"`
-	Memory     int               `json:"memory"`
+	Volumes     []PodVolume       `json:"volumes"`
+	Containers  []Container       `json:"containers"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Vcpu        int               `json:"vcpu"`
+	Memory      int               `json:"memory"`
 }
 
 type PodStatus struct {
@@ -58,3 +61,17 @@ type PodInfo struct {
 	Spec       PodSpec   `json:"spec"`
 	Status     PodStatus `json:"status"`
 }
+
+type ContainerExit struct {
+	Name        string `json:"name"`
+	ContainerID string `json:"containerID"`
+	Phase       string `json:"phase"`
+	ExitCode    int    `json:"exitCode"`
+}
+
+type PodExit struct {
+	PodID      string          `json:"podID"`
+	PodName    string          `json:"podName"`
+	Phase      string          `json:"phase"`
+	Containers []ContainerExit `json:"containers"`
+}
@@ -0,0 +1,12 @@
+package types
+
+// PruneReport is the orphaned resources found by the prune, they are
+// removed unless it is a dry run.
+type PruneReport struct {
+	DryRun     bool     `json:"dryRun"`
+	Dirs       []string `json:"dirs"`
+	Volumes    []string `json:"volumes"`
+	Containers []string `json:"containers"`
+	Keys       []string `json:"keys"`
+	Errors     []string `json:"errors,omitempty"`
+}
@@ -0,0 +1,14 @@
+package types
+
+// ResourceInfo is the resource allocated to the running pods and the cached
+// VMs on the host, against the capacity of the admission control. A zero
+// capacity means unlimited.
+type ResourceInfo struct {
+	Cpus      int `json:"cpus"`
+	MaxCpus   int `json:"maxCpus"`
+	Memory    int `json:"memory"`
+	MaxMemory int `json:"maxMemory"`
+	Pods      int `json:"pods"`
+	MaxPods   int `json:"maxPods"`
+	CachedVms int `json:"cachedVms"`
+}
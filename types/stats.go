@@ -0,0 +1,93 @@
+package types
+
+import "time"
+
+// PodStats is the resource usage of the containers of a pod, the json keys
+// are the ones of the stats reported by runv. All the counters are
+// cumulative, the rates are computed from two samples.
+type PodStats struct {
+	PodID      string           `json:"podId"`
+	PodName    string           `json:"podName"`
+	Timestamp  time.Time        `json:"timestamp"`
+	Containers []ContainerStats `json:"containersStats"`
+}
+
+// ContainerStats is the resource usage of a container. The containers of a
+// pod share the network of the VM, so the network counters are the ones of
+// the pod.
+type ContainerStats struct {
+	ContainerID string       `json:"containerId"`
+	Name        string       `json:"name"`
+	Timestamp   time.Time    `json:"timestamp"`
+	Cpu         CpuStats     `json:"cpu"`
+	Memory      MemoryStats  `json:"memory"`
+	Blkio       BlkioStats   `json:"blkio"`
+	Network     NetworkStats `json:"network"`
+}
+
+// CpuStats is the cpu time used, in nanoseconds.
+type CpuStats struct {
+	Usage CpuUsage `json:"usage"`
+}
+
+type CpuUsage struct {
+	Total  uint64   `json:"total"`
+	PerCpu []uint64 `json:"perCpu,omitempty"`
+	User   uint64   `json:"user"`
+	System uint64   `json:"system"`
+}
+
+// MemoryStats is the memory used, in bytes.
+type MemoryStats struct {
+	Usage      uint64 `json:"usage"`
+	WorkingSet uint64 `json:"workingSet"`
+	Failcnt    uint64 `json:"failcnt"`
+}
+
+// BlkioStats is the block I/O per device, the stat of the entries is keyed
+// by the operation, e.g. Read and Write.
+type BlkioStats struct {
+	IoServiceBytesRecursive []BlkioStatEntry `json:"ioServiceBytesRecursive"`
+	IoServicedRecursive     []BlkioStatEntry `json:"ioServicedRecursive"`
+}
+
+type BlkioStatEntry struct {
+	Device string            `json:"device,omitempty"`
+	Major  uint64            `json:"major"`
+	Minor  uint64            `json:"minor"`
+	Stat   map[string]uint64 `json:"stat"`
+}
+
+type NetworkStats struct {
+	Interfaces []InterfaceStats `json:"interfaces"`
+}
+
+type InterfaceStats struct {
+	Name      string `json:"name"`
+	RxBytes   uint64 `json:"rxBytes"`
+	RxPackets uint64 `json:"rxPackets"`
+	RxErrors  uint64 `json:"rxErrors"`
+	RxDropped uint64 `json:"rxDropped"`
+	TxBytes   uint64 `json:"txBytes"`
+	TxPackets uint64 `json:"txPackets"`
+	TxErrors  uint64 `json:"txErrors"`
+	TxDropped uint64 `json:"txDropped"`
+}
+
+// Bytes returns the bytes read and written on all the devices.
+func (s *BlkioStats) Bytes() (read, write uint64) {
+	for _, entry := range s.IoServiceBytesRecursive {
+		read += entry.Stat["Read"]
+		write += entry.Stat["Write"]
+	}
+	return read, write
+}
+
+// Bytes returns the bytes received and sent on all the interfaces.
+func (s *NetworkStats) Bytes() (rx, tx uint64) {
+	for _, i := range s.Interfaces {
+		rx += i.RxBytes
+		tx += i.TxBytes
+	}
+	return rx, tx
+}
@@ -0,0 +1,24 @@
+package types
+
+// ContainerProcess is a process in the container, the pids are the ones in
+// the pid namespace of the container.
+type ContainerProcess struct {
+	Pid        int     `json:"pid"`
+	Ppid       int     `json:"ppid"`
+	Uid        int     `json:"uid"`
+	User       string  `json:"user"`
+	State      string  `json:"state"`
+	CpuPercent float64 `json:"cpuPercent"`
+	CpuTime    float64 `json:"cpuTime"`
+	Rss        uint64  `json:"rss"`
+	StartTime  int64   `json:"startTime"`
+	Command    string  `json:"command"`
+	Args       string  `json:"args"`
+}
+
+// ContainerTop is the process table of a container, CpuTime is in seconds,
+// Rss is in bytes and StartTime is the unix time in the guest clock.
+type ContainerTop struct {
+	Container string             `json:"container"`
+	Processes []ContainerProcess `json:"processes"`
+}
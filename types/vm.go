@@ -0,0 +1,28 @@
+package types
+
+// VmTemplateInfo is the status of a template VM of the "clone" cache policy.
+type VmTemplateInfo struct {
+	Cpu     int    `json:"cpu"`
+	Memory  int    `json:"memory"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	Created string `json:"created,omitempty"`
+	Clones  int    `json:"clones"`
+}
+
+// VmPoolInfo is the statistics of the VM pools of the "cache" policy.
+type VmPoolInfo struct {
+	Hits        int64              `json:"hits"`
+	Misses      int64              `json:"misses"`
+	IdleTimeout int                `json:"idleTimeout"`
+	Flavors     []VmPoolFlavorInfo `json:"flavors"`
+}
+
+type VmPoolFlavorInfo struct {
+	Cpu     int `json:"cpu"`
+	Memory  int `json:"memory"`
+	Min     int `json:"min"`
+	Max     int `json:"max"`
+	Idle    int `json:"idle"`
+	Booting int `json:"booting"`
+}